@@ -0,0 +1,97 @@
+package arith
+
+import (
+	"errors"
+	"fmt"
+	"math"
+)
+
+// Mode decides what an arithmetic operation does when its result doesn't fit in
+// 64 bits. Bitwise operations and shifts work on the bits and never overflow,
+// except for shifts to the left in Big mode.
+type Mode int
+
+const (
+	Wrapping   Mode = iota // the result wraps around, like in Go
+	Checked                // the operation fails
+	Saturating             // the result is the largest or smallest int64
+	Big                    // integers have any size, an operation fails like in checked mode and is redone with math/big
+)
+
+// ErrOverflow is the cause of the error of an operation that failed in checked mode
+var ErrOverflow = errors.New("integer overflow")
+
+var modeNames = map[Mode]string{
+	Wrapping:   "wrapping",
+	Checked:    "checked",
+	Saturating: "saturating",
+	Big:        "big",
+}
+
+func (m Mode) String() string {
+	if name, ok := modeNames[m]; ok {
+		return name
+	}
+	return fmt.Sprintf("mode(%d)", int(m))
+}
+
+// ParseMode returns the mode called name
+func ParseMode(name string) (Mode, error) {
+	for m, n := range modeNames {
+		if n == name {
+			return m, nil
+		}
+	}
+	return Wrapping, fmt.Errorf("unknown arithmetic mode %q, expected wrapping, checked, saturating or big", name)
+}
+
+// result returns the wrapped result of an operation, or the saturated one if the
+// operation overflowed towards the positive numbers when positive is true. ok is
+// false if the operation overflowed in checked mode.
+func (m Mode) result(wrapped int64, overflow bool, positive bool) (res int64, ok bool) {
+	if !overflow {
+		return wrapped, true
+	}
+
+	switch m {
+	case Checked, Big:
+		return 0, false
+	case Saturating:
+		if positive {
+			return math.MaxInt64, true
+		}
+		return math.MinInt64, true
+	}
+
+	return wrapped, true
+}
+
+// Add returns a + b
+func (m Mode) Add(a int64, b int64) (int64, bool) {
+	s := a + b
+	return m.result(s, (a >= 0) == (b >= 0) && (s >= 0) != (a >= 0), a >= 0)
+}
+
+// Sub returns a - b
+func (m Mode) Sub(a int64, b int64) (int64, bool) {
+	s := a - b
+	return m.result(s, (a >= 0) != (b >= 0) && (s >= 0) != (a >= 0), a >= 0)
+}
+
+// Mul returns a * b
+func (m Mode) Mul(a int64, b int64) (int64, bool) {
+	p := a * b
+	overflow := a != 0 && (p/a != b || (a == -1 && b == math.MinInt64))
+	return m.result(p, overflow, (a < 0) == (b < 0))
+}
+
+// Neg returns -a
+func (m Mode) Neg(a int64) (int64, bool) {
+	return m.result(-a, a == math.MinInt64, true)
+}
+
+// Div returns a / b truncated towards zero, b must not be zero. The smallest
+// int64 divided by -1 is the only division that overflows.
+func (m Mode) Div(a int64, b int64) (int64, bool) {
+	return m.result(a/b, a == math.MinInt64 && b == -1, true)
+}
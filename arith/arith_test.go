@@ -0,0 +1,197 @@
+package arith
+
+import (
+	"math"
+	"testing"
+)
+
+func TestOperations(t *testing.T) {
+	const max, min = math.MaxInt64, math.MinInt64
+
+	tests := []struct {
+		op   string
+		a, b int64
+		// the results in wrapping and saturating mode, checked mode fails if they differ
+		wrapped, saturated int64
+	}{
+		{"+", 1, 2, 3, 3},
+		{"+", max, 1, min, max},
+		{"+", min, -1, max, min},
+		{"+", max, min, -1, -1},
+		{"-", 1, 2, -1, -1},
+		{"-", min, 1, max, min},
+		{"-", 0, min, min, max},
+		{"-", -1, min, max, max},
+		{"*", 3, -4, -12, -12},
+		{"*", max, 2, -2, max},
+		{"*", min, -1, min, max},
+		{"*", -1, min, min, max},
+		{"*", min, 1, min, min},
+		{"*", 1 << 32, -(1 << 31), min, min},
+		{"*", 1 << 32, 1 << 31, min, max},
+		{"*", 0, min, 0, 0},
+		{"-x", 5, 0, -5, -5},
+		{"-x", min, 0, min, max},
+		{"/", 7, -2, -3, -3},
+		{"/", min, -1, min, max},
+	}
+
+	for i, tt := range tests {
+		for _, m := range []Mode{Wrapping, Checked, Saturating, Big} {
+			var res int64
+			var ok bool
+
+			switch tt.op {
+			case "+":
+				res, ok = m.Add(tt.a, tt.b)
+			case "-":
+				res, ok = m.Sub(tt.a, tt.b)
+			case "*":
+				res, ok = m.Mul(tt.a, tt.b)
+			case "-x":
+				res, ok = m.Neg(tt.a)
+			case "/":
+				res, ok = m.Div(tt.a, tt.b)
+			}
+
+			expected, expectOk := tt.wrapped, true
+			switch {
+			case m == Saturating:
+				expected = tt.saturated
+			case (m == Checked || m == Big) && tt.wrapped != tt.saturated:
+				expected, expectOk = 0, false
+			}
+
+			if res != expected || ok != expectOk {
+				t.Fatalf("tests[%d] - %d %s %d in %s mode wrong. expected=%d, %v, got=%d, %v", i, tt.a, tt.op, tt.b, m, expected, expectOk, res, ok)
+			}
+		}
+	}
+}
+
+func TestParseMode(t *testing.T) {
+	for _, m := range []Mode{Wrapping, Checked, Saturating, Big} {
+		if got, err := ParseMode(m.String()); err != nil || got != m {
+			t.Fatalf("ParseMode(%q) wrong. got=%v, err=%v", m.String(), got, err)
+		}
+	}
+
+	if _, err := ParseMode("trapping"); err == nil {
+		t.Fatalf("expected an error for an unknown mode")
+	}
+}
+
+func TestIntOperations(t *testing.T) {
+	i8, u8 := Int{Bits: 8}, Int{Bits: 8, Unsigned: true}
+	i32, u32 := Int{Bits: 32}, Int{Bits: 32, Unsigned: true}
+	u64 := Int{Bits: 64, Unsigned: true}
+
+	tests := []struct {
+		typ                Int
+		op                 string
+		a, b               int64
+		wrapped, saturated int64
+	}{
+		{i8, "+", 100, 27, 127, 127},
+		{i8, "+", 100, 28, -128, 127},
+		{i8, "-", -100, 29, 127, -128},
+		{i8, "*", 16, 8, -128, 127},
+		{i8, "*", -16, 8, -128, -128},
+		{i8, "/", -128, -1, -128, 127},
+		{i8, "-x", -128, 0, -128, 127},
+		{u8, "+", 200, 56, 0, 255},
+		{u8, "-", 1, 2, 255, 0},
+		{u8, "*", 16, 16, 0, 255},
+		{u8, "-x", 1, 0, 255, 0},
+		{u8, "/", 255, 2, 127, 127},
+		{i32, "*", 65536, 32768, -2147483648, 2147483647},
+		{u32, "*", 4294967295, 4294967295, 1, 4294967295},
+		{u32, "+", 4294967295, 0, 4294967295, 4294967295},
+		{u64, "+", -1, 1, 0, -1},
+		{u64, "-", 0, 1, -1, 0},
+		{u64, "*", 1 << 32, 1 << 32, 0, -1},
+		{u64, "/", -1, 2, 1<<63 - 1, 1<<63 - 1},
+		{u64, "-x", 0, 0, 0, 0},
+		{u64, "-x", 1, 0, -1, 0},
+		{Int{}, "+", 9223372036854775807, 1, -9223372036854775808, 9223372036854775807},
+	}
+
+	for i, tt := range tests {
+		for _, m := range []Mode{Wrapping, Checked, Saturating} {
+			var res int64
+			var ok bool
+
+			switch tt.op {
+			case "+":
+				res, ok = tt.typ.Add(m, tt.a, tt.b)
+			case "-":
+				res, ok = tt.typ.Sub(m, tt.a, tt.b)
+			case "*":
+				res, ok = tt.typ.Mul(m, tt.a, tt.b)
+			case "-x":
+				res, ok = tt.typ.Neg(m, tt.a)
+			case "/":
+				res, ok = tt.typ.Div(m, tt.a, tt.b)
+			}
+
+			expected, expectOk := tt.wrapped, true
+			switch {
+			case m == Saturating:
+				expected = tt.saturated
+			case m == Checked && tt.wrapped != tt.saturated:
+				expected, expectOk = 0, false
+			}
+
+			if res != expected || ok != expectOk {
+				t.Fatalf("tests[%d] - %d %s %d as %s in %s mode wrong. expected=%d, %v, got=%d, %v", i, tt.a, tt.op, tt.b, tt.typ, m, expected, expectOk, res, ok)
+			}
+		}
+	}
+}
+
+func TestIntBits(t *testing.T) {
+	i8, u8 := Int{Bits: 8}, Int{Bits: 8, Unsigned: true}
+	u64 := Int{Bits: 64, Unsigned: true}
+
+	tests := []struct {
+		got, expected int64
+	}{
+		{i8.Wrap(200), -56},
+		{u8.Wrap(-1), 255},
+		{i8.Not(0), -1},
+		{u8.Not(0), 255},
+		{i8.Shl(1, 7), -128},
+		{u8.Shl(255, 4), 240},
+		{i8.Shr(-128, 7), -1},
+		{u8.Shr(128, 7), 1},
+		{i8.Ushr(-128, 7), 1},
+		{u64.Shr(-1, 63), 1},
+		{u8.Rem(255, 7), 3},
+		{u64.Rem(-1, 10), 5},
+	}
+
+	for i, tt := range tests {
+		if tt.got != tt.expected {
+			t.Fatalf("tests[%d] - wrong result. expected=%d, got=%d", i, tt.expected, tt.got)
+		}
+	}
+
+	if !u64.Less(1, -1) || i8.Less(1, -1) {
+		t.Fatalf("comparison wrong")
+	}
+
+	if s := u64.Format(-1); s != "18446744073709551615" {
+		t.Fatalf("Format wrong. got=%s", s)
+	}
+
+	for _, name := range []string{"int8", "uint16", "int32", "uint64", "int64"} {
+		typ, err := ParseInt(name)
+		if err != nil || typ.String() != name {
+			t.Fatalf("ParseInt(%q) wrong. got=%v, err=%v", name, typ, err)
+		}
+	}
+
+	if _, err := ParseInt("int12"); err == nil {
+		t.Fatalf("expected an error for an unknown integer type")
+	}
+}
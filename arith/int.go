@@ -0,0 +1,283 @@
+package arith
+
+import (
+	"fmt"
+	"math"
+	"math/big"
+	"math/bits"
+	"strconv"
+)
+
+// Int is the integer type of a program, the zero value is int64. A value of any
+// type is kept in an int64: signed values are sign-extended and unsigned ones are
+// zero-extended, except that a uint64 is kept as its bits. The operations of an
+// Int expect and return values kept like this.
+type Int struct {
+	Bits     int // 8, 16, 32 or 64, 0 means 64
+	Unsigned bool
+}
+
+// ParseInt returns the integer type called name, int8 to int64 or uint8 to uint64
+func ParseInt(name string) (Int, error) {
+	for _, size := range []int{8, 16, 32, 64} {
+		for _, t := range []Int{{Bits: size}, {Bits: size, Unsigned: true}} {
+			if t.String() == name {
+				return t, nil
+			}
+		}
+	}
+	return Int{}, fmt.Errorf("unknown integer type %q, expected int8, int16, int32, int64 or their unsigned versions like uint8", name)
+}
+
+func (t Int) String() string {
+	if t.Unsigned {
+		return fmt.Sprintf("uint%d", t.size())
+	}
+	return fmt.Sprintf("int%d", t.size())
+}
+
+// Is64 reports whether t is int64, whose operations are the ones of Mode
+func (t Int) Is64() bool {
+	return t.size() == 64 && !t.Unsigned
+}
+
+func (t Int) size() uint {
+	if t.Bits == 0 {
+		return 64
+	}
+	return uint(t.Bits)
+}
+
+// min and max return the range of t for sizes below 64 bits
+func (t Int) min() int64 {
+	if t.Unsigned {
+		return 0
+	}
+	return -1 << (t.size() - 1)
+}
+
+func (t Int) max() int64 {
+	if t.Unsigned {
+		return 1<<t.size() - 1
+	}
+	return 1<<(t.size()-1) - 1
+}
+
+// Wrap returns the value of t with the lowest bits of x
+func (t Int) Wrap(x int64) int64 {
+	shift := 64 - t.size()
+	if t.Unsigned {
+		return int64(uint64(x) << shift >> shift)
+	}
+	return x << shift >> shift
+}
+
+// contains reports whether the int64 x is a value of t, for uint64 x must not be
+// negative
+func (t Int) contains(x int64) bool {
+	switch {
+	case t.Is64():
+		return true
+	case t.size() == 64:
+		return x >= 0
+	}
+	return x >= t.min() && x <= t.max()
+}
+
+// Convert returns the int64 x as a value of t, x has to fit unless it wraps around
+// or saturates in mode m
+func (t Int) Convert(m Mode, x int64) (int64, bool) {
+	if t.size() == 64 {
+		if t.Unsigned && x < 0 {
+			return unsignedResult(m, uint64(x), true, false)
+		}
+		return x, true
+	}
+	return t.result(m, x)
+}
+
+// result returns the exact result x of an operation on values of t below 64 bits
+// in mode m
+func (t Int) result(m Mode, x int64) (int64, bool) {
+	if x >= t.min() && x <= t.max() {
+		return x, true
+	}
+
+	switch m {
+	case Checked, Big:
+		return 0, false
+	case Saturating:
+		if x > t.max() {
+			return t.max(), true
+		}
+		return t.min(), true
+	}
+
+	return t.Wrap(x), true
+}
+
+// unsignedResult is result for the operations on uint64, the wrapped result is
+// also the exact one if there is no overflow
+func unsignedResult(m Mode, wrapped uint64, overflow bool, positive bool) (int64, bool) {
+	if !overflow {
+		return int64(wrapped), true
+	}
+
+	switch m {
+	case Checked, Big:
+		return 0, false
+	case Saturating:
+		if positive {
+			return -1, true // all bits set is the largest uint64
+		}
+		return 0, true
+	}
+
+	return int64(wrapped), true
+}
+
+// Add returns a + b in mode m
+func (t Int) Add(m Mode, a int64, b int64) (int64, bool) {
+	switch {
+	case t.Is64():
+		return m.Add(a, b)
+	case t.size() == 64:
+		s, carry := bits.Add64(uint64(a), uint64(b), 0)
+		return unsignedResult(m, s, carry != 0, true)
+	}
+	return t.result(m, a+b)
+}
+
+// Sub returns a - b in mode m
+func (t Int) Sub(m Mode, a int64, b int64) (int64, bool) {
+	switch {
+	case t.Is64():
+		return m.Sub(a, b)
+	case t.size() == 64:
+		d, borrow := bits.Sub64(uint64(a), uint64(b), 0)
+		return unsignedResult(m, d, borrow != 0, false)
+	}
+	return t.result(m, a-b)
+}
+
+// Mul returns a * b in mode m
+func (t Int) Mul(m Mode, a int64, b int64) (int64, bool) {
+	switch {
+	case t.Is64():
+		return m.Mul(a, b)
+	case t.Unsigned:
+		hi, lo := bits.Mul64(uint64(a), uint64(b))
+		if t.size() == 64 {
+			return unsignedResult(m, lo, hi != 0, true)
+		}
+		// the product of two values of 32 bits or less fits into a uint64, but
+		// not necessarily into an int64
+		if lo > uint64(t.max()) && m == Wrapping {
+			return t.Wrap(int64(lo)), true
+		}
+		if lo > uint64(t.max()) {
+			return t.result(m, math.MaxInt64)
+		}
+		return int64(lo), true
+	}
+	// the product of two values of 32 bits or less fits into an int64
+	return t.result(m, a*b)
+}
+
+// Div returns a / b truncated towards zero in mode m, b must not be zero
+func (t Int) Div(m Mode, a int64, b int64) (int64, bool) {
+	switch {
+	case t.Is64():
+		return m.Div(a, b)
+	case t.Unsigned:
+		return int64(uint64(a) / uint64(b)), true
+	}
+	return t.result(m, a/b)
+}
+
+// Rem returns the remainder of a / b, which has the sign of a. b must not be zero.
+func (t Int) Rem(a int64, b int64) int64 {
+	if t.Unsigned {
+		return int64(uint64(a) % uint64(b))
+	}
+	return a % b
+}
+
+// Neg returns -a in mode m
+func (t Int) Neg(m Mode, a int64) (int64, bool) {
+	switch {
+	case t.Is64():
+		return m.Neg(a)
+	case t.size() == 64:
+		return unsignedResult(m, -uint64(a), a != 0, false)
+	}
+	return t.result(m, -a)
+}
+
+// Not returns the bitwise complement of a
+func (t Int) Not(a int64) int64 {
+	return t.Wrap(^a)
+}
+
+// Shl returns a shifted to the left by n bits, the bits shifted out are lost
+func (t Int) Shl(a int64, n uint64) int64 {
+	return t.Wrap(a << n)
+}
+
+// Shr returns a shifted to the right by n bits, keeping the sign of signed values
+func (t Int) Shr(a int64, n uint64) int64 {
+	if t.Unsigned {
+		return int64(uint64(a) >> n)
+	}
+	return a >> n
+}
+
+// Ushr returns a shifted to the right by n bits, filling in zero bits
+func (t Int) Ushr(a int64, n uint64) int64 {
+	shift := 64 - t.size()
+	return t.Wrap(int64(uint64(a) << shift >> shift >> n))
+}
+
+// OnesCount returns the number of one bits of a
+func (t Int) OnesCount(a int64) int {
+	return bits.OnesCount64(uint64(a) << (64 - t.size()))
+}
+
+// Less reports whether a < b
+func (t Int) Less(a int64, b int64) bool {
+	if t.Unsigned {
+		return uint64(a) < uint64(b)
+	}
+	return a < b
+}
+
+// Format returns a in decimal
+func (t Int) Format(a int64) string {
+	if t.Unsigned {
+		return strconv.FormatUint(uint64(a), 10)
+	}
+	return strconv.FormatInt(a, 10)
+}
+
+// FromBig returns n as a value of t, ok is false if it doesn't fit
+func (t Int) FromBig(n *big.Int) (x int64, ok bool) {
+	if t.Unsigned && t.size() == 64 {
+		if n.Sign() < 0 || !n.IsUint64() {
+			return 0, false
+		}
+		return int64(n.Uint64()), true
+	}
+
+	if !n.IsInt64() || !t.contains(n.Int64()) {
+		return 0, false
+	}
+	return n.Int64(), true
+}
+
+// ToBig returns the value a of t as a big.Int
+func (t Int) ToBig(a int64) *big.Int {
+	if t.Unsigned {
+		return new(big.Int).SetUint64(uint64(a))
+	}
+	return big.NewInt(a)
+}
@@ -1,6 +1,8 @@
 package ast
 
 import (
+	"math/big"
+
 	"github.com/simplang/token"
 )
 
@@ -9,87 +11,206 @@ type Expression interface {
 	Print(indent int)
 }
 
-// Integer => int64
+// Integer => int64, or an integer of any size if the parser accepts big integers
 type Integer struct {
-	Token token.Token
-	Value int64
+	Token token.Token `json:"token"`
+	Value int64       `json:"value"`
+	Big   *big.Int    `json:"big,omitempty"` // the value if it doesn't fit into 64 bits, Value is 0 then
+}
+
+// BigValue returns the value of i as a big.Int
+func (i *Integer) BigValue() *big.Int {
+	if i.Big != nil {
+		return i.Big
+	}
+	return big.NewInt(i.Value)
+}
+
+// Boolean => "true" | "false"
+type Boolean struct {
+	Token token.Token `json:"token"`
+	Value bool        `json:"value"`
+}
+
+// String => '"' {char} '"'
+type String struct {
+	Token token.Token `json:"token"`
+	Value string      `json:"value"`
 }
 
 // Ident => identifier
 type Ident struct {
-	Token token.Token
-	Name  string
+	Token token.Token `json:"token"`
+	Name  string      `json:"name"`
 }
 
-// Program => function {function}
+// Program => {import} function {function}
 type Program struct {
-	Token     token.Token
-	Functions []*Function
+	Token     token.Token `json:"token"`
+	Imports   []*Import   `json:"imports,omitempty"`
+	Functions []*Function `json:"functions"`
+}
+
+// Import => "import" string
+// The path is relative to the directory of the importing file.
+type Import struct {
+	Token token.Token `json:"token"`
+	Path  string      `json:"path"`
 }
 
-// Function => "let" ident {ident}+ "in" expr "end"
+// Function => "let" ident {pattern} "=" expr "end"
+// A function without parameters is a constant. A function defined by several
+// clauses (see Clause) has no Body, its Params only name the arguments.
 type Function struct {
-	Token  token.Token
-	Name   *Ident
-	Params []*Ident
-	Body   Expression
+	Token   token.Token `json:"token"`
+	Name    *Ident      `json:"name"`
+	Params  []*Ident    `json:"params"`
+	Body    Expression  `json:"body"`
+	Clauses []*Clause   `json:"clauses,omitempty"`
+}
+
+// Clause => "let" ident {pattern}+ "=" expr "end"
+// pattern => ident | integer
+// Consecutive definitions of the same function are its clauses. A call evaluates
+// the body of the first clause whose integer patterns equal the arguments.
+type Clause struct {
+	Token    token.Token  `json:"token"`
+	Patterns []Expression `json:"patterns"`
+	Body     Expression   `json:"body"`
 }
 
 // FunctionCall => ident arg {arg}
 // arg => "(" expr ")"
 type FunctionCall struct {
-	Token  token.Token
-	Name   string
-	Params []Expression
+	Token  token.Token  `json:"token"`
+	Name   string       `json:"name"`
+	Params []Expression `json:"params"`
+}
+
+// CallExpression => "(" expr ")" arg {arg}
+// Calls the function the expression evaluates to.
+type CallExpression struct {
+	Token    token.Token  `json:"token"`
+	Function Expression   `json:"function"`
+	Params   []Expression `json:"params"`
+}
+
+// Lambda => "fn" ident {ident} "->" expr "end"
+// An anonymous function, it sees the variables of its definition.
+type Lambda struct {
+	Token  token.Token `json:"token"`
+	Params []*Ident    `json:"params"`
+	Body   Expression  `json:"body"`
+}
+
+// ArrayLiteral => "[" [expr {"," expr}] "]"
+// All elements have the same type.
+type ArrayLiteral struct {
+	Token    token.Token  `json:"token"`
+	Elements []Expression `json:"elements"`
+}
+
+// ArrayExpression => "array" arg arg
+// Builds an array whose length is the first argument, the second argument is a
+// function that is called with every index and returns the element there.
+type ArrayExpression struct {
+	Token    token.Token `json:"token"`
+	Length   Expression  `json:"length"`
+	Function Expression  `json:"function"`
+}
+
+// IndexExpression => expr "[" expr "]"
+// Indices start at 0.
+type IndexExpression struct {
+	Token token.Token `json:"token"`
+	Array Expression  `json:"array"`
+	Index Expression  `json:"index"`
+}
+
+// LengthExpression => "len" arg
+type LengthExpression struct {
+	Token token.Token `json:"token"`
+	Array Expression  `json:"array"`
 }
 
 // IfExpression => "if" expr "then" expr "else" expr "end"
 type IfExpression struct {
-	Token       token.Token
-	Condition   Expression
-	Consequence Expression
-	Alternative Expression
+	Token       token.Token `json:"token"`
+	Condition   Expression  `json:"condition"`
+	Consequence Expression  `json:"consequence"`
+	Alternative Expression  `json:"alternative"`
+}
+
+// CondExpression => "if" branch {"elif" branch}+ "else" expr "end"
+// An if without any elif is an IfExpression.
+type CondExpression struct {
+	Token       token.Token   `json:"token"`
+	Branches    []*CondBranch `json:"branches"`
+	Alternative Expression    `json:"alternative"`
+}
+
+// CondBranch => expr "then" expr
+type CondBranch struct {
+	Token       token.Token `json:"token"`
+	Condition   Expression  `json:"condition"`
+	Consequence Expression  `json:"consequence"`
 }
 
 // UnaryExpression => op expr
 type UnaryExpression struct {
-	Token    token.Token
-	Operator token.TokenType
-	Operand  Expression
+	Token    token.Token     `json:"token"`
+	Operator token.TokenType `json:"operator"`
+	Operand  Expression      `json:"operand"`
 }
 
 // BinaryExpression => expr op expr
 type BinaryExpression struct {
-	Token    token.Token
-	Left     Expression
-	Operator token.TokenType
-	Right    Expression
+	Token    token.Token     `json:"token"`
+	Left     Expression      `json:"left"`
+	Operator token.TokenType `json:"operator"`
+	Right    Expression      `json:"right"`
 }
 
-// Binding => ident "=" expr
+// Binding => ident {ident} "=" expr
+// A binding with parameters in a let defines a local function, its Expr is the
+// Function. The function is visible in its own body and after the binding.
 type Binding struct {
-	Token token.Token
-	Ident *Ident
-	Expr  Expression
+	Token token.Token `json:"token"`
+	Ident *Ident      `json:"ident"`
+	Expr  Expression  `json:"expr"`
 }
 
 // LetExpression => "let" bindings "in" expr "end"
 type LetExpression struct {
-	Token    token.Token
-	Bindings []*Binding
-	Expr     Expression
+	Token    token.Token `json:"token"`
+	Bindings []*Binding  `json:"bindings"`
+	Expr     Expression  `json:"expr"`
 }
 
 // LoopExpression => "loop" bindings "in" expr "end"
 type LoopExpression struct {
-	Token    token.Token
-	Bindings []*Binding
-	Expr     Expression
+	Token    token.Token `json:"token"`
+	Bindings []*Binding  `json:"bindings"`
+	Expr     Expression  `json:"expr"`
+}
+
+// PrintExpression => "print" arg {arg}
+// Writes the arguments separated by spaces on a line, its value is the last argument.
+type PrintExpression struct {
+	Token token.Token  `json:"token"`
+	Args  []Expression `json:"args"`
+}
+
+// Sequence => expr ";" expr {";" expr}
+// Evaluates the expressions in order, its value is the one of the last expression.
+type Sequence struct {
+	Token token.Token  `json:"token"`
+	Exprs []Expression `json:"exprs"`
 }
 
 // Recur => "recur" arg {arg}
 // arg = "(" expr ")"
 type Recur struct {
-	Token token.Token
-	Args  []Expression
+	Token token.Token  `json:"token"`
+	Args  []Expression `json:"args"`
 }
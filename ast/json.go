@@ -0,0 +1,878 @@
+package ast
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+
+	"github.com/simplang/token"
+)
+
+// Every node is encoded as a JSON object with a "kind" field naming its type,
+// followed by its fields. Tokens are stored in full so positions survive a
+// round trip, e.g.
+// {"kind":"Integer","token":{"type":"INT","literal":"4","line":1,"column":8},"value":4}
+
+// kinds maps the "kind" discriminator to a constructor of the matching node
+var kinds = map[string]func() Expression{
+	"Integer":          func() Expression { return &Integer{} },
+	"Boolean":          func() Expression { return &Boolean{} },
+	"String":           func() Expression { return &String{} },
+	"Ident":            func() Expression { return &Ident{} },
+	"Program":          func() Expression { return &Program{} },
+	"Import":           func() Expression { return &Import{} },
+	"Function":         func() Expression { return &Function{} },
+	"Clause":           func() Expression { return &Clause{} },
+	"FunctionCall":     func() Expression { return &FunctionCall{} },
+	"CallExpression":   func() Expression { return &CallExpression{} },
+	"Lambda":           func() Expression { return &Lambda{} },
+	"ArrayLiteral":     func() Expression { return &ArrayLiteral{} },
+	"ArrayExpression":  func() Expression { return &ArrayExpression{} },
+	"IndexExpression":  func() Expression { return &IndexExpression{} },
+	"LengthExpression": func() Expression { return &LengthExpression{} },
+	"IfExpression":     func() Expression { return &IfExpression{} },
+	"CondExpression":   func() Expression { return &CondExpression{} },
+	"CondBranch":       func() Expression { return &CondBranch{} },
+	"UnaryExpression":  func() Expression { return &UnaryExpression{} },
+	"BinaryExpression": func() Expression { return &BinaryExpression{} },
+	"Binding":          func() Expression { return &Binding{} },
+	"LetExpression":    func() Expression { return &LetExpression{} },
+	"LoopExpression":   func() Expression { return &LoopExpression{} },
+	"PrintExpression":  func() Expression { return &PrintExpression{} },
+	"Sequence":         func() Expression { return &Sequence{} },
+	"Recur":            func() Expression { return &Recur{} },
+}
+
+// MarshalExpression encodes any node, including nil, as JSON
+func MarshalExpression(e Expression) ([]byte, error) {
+	return json.Marshal(e)
+}
+
+// UnmarshalExpression decodes a node that was encoded with MarshalExpression.
+// The concrete type is picked by the "kind" field.
+func UnmarshalExpression(data []byte) (Expression, error) {
+	var head struct {
+		Kind string `json:"kind"`
+	}
+
+	// a missing field decodes to a nil RawMessage, same as an explicit null
+	if data == nil || bytes.Equal(bytes.TrimSpace(data), []byte("null")) {
+		return nil, nil
+	}
+
+	if err := json.Unmarshal(data, &head); err != nil {
+		return nil, err
+	}
+
+	newNode, ok := kinds[head.Kind]
+	if !ok {
+		return nil, fmt.Errorf("unknown node kind %q", head.Kind)
+	}
+
+	e := newNode()
+	if err := json.Unmarshal(data, e); err != nil {
+		return nil, err
+	}
+
+	return e, nil
+}
+
+// marshalNode encodes v (an alias of a node type without the MarshalJSON method)
+// and puts the kind discriminator in front of its fields
+func marshalNode(kind string, v interface{}) ([]byte, error) {
+	fields, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, `{"kind":%q`, kind)
+	if len(fields) > 2 {
+		buf.WriteByte(',')
+		buf.Write(fields[1:])
+	} else {
+		buf.WriteByte('}')
+	}
+
+	return buf.Bytes(), nil
+}
+
+// checkKind makes sure the encoded node has the kind we are decoding into
+func checkKind(kind string, got string) error {
+	if got != kind {
+		return fmt.Errorf("expected node of kind %q, got %q", kind, got)
+	}
+	return nil
+}
+
+func unmarshalExpressions(raw []json.RawMessage) ([]Expression, error) {
+	if raw == nil {
+		return nil, nil
+	}
+
+	res := make([]Expression, len(raw))
+	for i, r := range raw {
+		e, err := UnmarshalExpression(r)
+		if err != nil {
+			return nil, err
+		}
+		res[i] = e
+	}
+
+	return res, nil
+}
+
+// MarshalJSON for Integer
+func (i *Integer) MarshalJSON() ([]byte, error) {
+	type alias Integer
+	return marshalNode("Integer", (*alias)(i))
+}
+
+// UnmarshalJSON for Integer
+func (i *Integer) UnmarshalJSON(data []byte) error {
+	type alias Integer
+	var v struct {
+		Kind string `json:"kind"`
+		*alias
+	}
+	v.alias = (*alias)(i)
+
+	if err := json.Unmarshal(data, &v); err != nil {
+		return err
+	}
+	return checkKind("Integer", v.Kind)
+}
+
+// MarshalJSON for Boolean
+func (b *Boolean) MarshalJSON() ([]byte, error) {
+	type alias Boolean
+	return marshalNode("Boolean", (*alias)(b))
+}
+
+// UnmarshalJSON for Boolean
+func (b *Boolean) UnmarshalJSON(data []byte) error {
+	type alias Boolean
+	var v struct {
+		Kind string `json:"kind"`
+		*alias
+	}
+	v.alias = (*alias)(b)
+
+	if err := json.Unmarshal(data, &v); err != nil {
+		return err
+	}
+	return checkKind("Boolean", v.Kind)
+}
+
+// MarshalJSON for String
+func (s *String) MarshalJSON() ([]byte, error) {
+	type alias String
+	return marshalNode("String", (*alias)(s))
+}
+
+// UnmarshalJSON for String
+func (s *String) UnmarshalJSON(data []byte) error {
+	type alias String
+	var v struct {
+		Kind string `json:"kind"`
+		*alias
+	}
+	v.alias = (*alias)(s)
+
+	if err := json.Unmarshal(data, &v); err != nil {
+		return err
+	}
+	return checkKind("String", v.Kind)
+}
+
+// MarshalJSON for Ident
+func (i *Ident) MarshalJSON() ([]byte, error) {
+	type alias Ident
+	return marshalNode("Ident", (*alias)(i))
+}
+
+// UnmarshalJSON for Ident
+func (i *Ident) UnmarshalJSON(data []byte) error {
+	type alias Ident
+	var v struct {
+		Kind string `json:"kind"`
+		*alias
+	}
+	v.alias = (*alias)(i)
+
+	if err := json.Unmarshal(data, &v); err != nil {
+		return err
+	}
+	return checkKind("Ident", v.Kind)
+}
+
+// MarshalJSON for Program
+func (p *Program) MarshalJSON() ([]byte, error) {
+	type alias Program
+	return marshalNode("Program", (*alias)(p))
+}
+
+// UnmarshalJSON for Program
+func (p *Program) UnmarshalJSON(data []byte) error {
+	type alias Program
+	var v struct {
+		Kind string `json:"kind"`
+		*alias
+	}
+	v.alias = (*alias)(p)
+
+	if err := json.Unmarshal(data, &v); err != nil {
+		return err
+	}
+	return checkKind("Program", v.Kind)
+}
+
+// MarshalJSON for Import
+func (i *Import) MarshalJSON() ([]byte, error) {
+	type alias Import
+	return marshalNode("Import", (*alias)(i))
+}
+
+// UnmarshalJSON for Import
+func (i *Import) UnmarshalJSON(data []byte) error {
+	type alias Import
+	var v struct {
+		Kind string `json:"kind"`
+		*alias
+	}
+	v.alias = (*alias)(i)
+
+	if err := json.Unmarshal(data, &v); err != nil {
+		return err
+	}
+	return checkKind("Import", v.Kind)
+}
+
+// MarshalJSON for Function
+func (f *Function) MarshalJSON() ([]byte, error) {
+	type alias Function
+	return marshalNode("Function", (*alias)(f))
+}
+
+// UnmarshalJSON for Function
+func (f *Function) UnmarshalJSON(data []byte) error {
+	var v struct {
+		Kind    string          `json:"kind"`
+		Token   token.Token     `json:"token"`
+		Name    *Ident          `json:"name"`
+		Params  []*Ident        `json:"params"`
+		Body    json.RawMessage `json:"body"`
+		Clauses []*Clause       `json:"clauses"`
+	}
+
+	if err := json.Unmarshal(data, &v); err != nil {
+		return err
+	}
+	if err := checkKind("Function", v.Kind); err != nil {
+		return err
+	}
+
+	body, err := UnmarshalExpression(v.Body)
+	if err != nil {
+		return err
+	}
+
+	*f = Function{Token: v.Token, Name: v.Name, Params: v.Params, Body: body, Clauses: v.Clauses}
+	return nil
+}
+
+// MarshalJSON for Clause
+func (c *Clause) MarshalJSON() ([]byte, error) {
+	type alias Clause
+	return marshalNode("Clause", (*alias)(c))
+}
+
+// UnmarshalJSON for Clause
+func (c *Clause) UnmarshalJSON(data []byte) error {
+	var v struct {
+		Kind     string            `json:"kind"`
+		Token    token.Token       `json:"token"`
+		Patterns []json.RawMessage `json:"patterns"`
+		Body     json.RawMessage   `json:"body"`
+	}
+
+	if err := json.Unmarshal(data, &v); err != nil {
+		return err
+	}
+	if err := checkKind("Clause", v.Kind); err != nil {
+		return err
+	}
+
+	patterns, err := unmarshalExpressions(v.Patterns)
+	if err != nil {
+		return err
+	}
+
+	body, err := UnmarshalExpression(v.Body)
+	if err != nil {
+		return err
+	}
+
+	*c = Clause{Token: v.Token, Patterns: patterns, Body: body}
+	return nil
+}
+
+// MarshalJSON for FunctionCall
+func (fc *FunctionCall) MarshalJSON() ([]byte, error) {
+	type alias FunctionCall
+	return marshalNode("FunctionCall", (*alias)(fc))
+}
+
+// UnmarshalJSON for FunctionCall
+func (fc *FunctionCall) UnmarshalJSON(data []byte) error {
+	var v struct {
+		Kind   string            `json:"kind"`
+		Token  token.Token       `json:"token"`
+		Name   string            `json:"name"`
+		Params []json.RawMessage `json:"params"`
+	}
+
+	if err := json.Unmarshal(data, &v); err != nil {
+		return err
+	}
+	if err := checkKind("FunctionCall", v.Kind); err != nil {
+		return err
+	}
+
+	params, err := unmarshalExpressions(v.Params)
+	if err != nil {
+		return err
+	}
+
+	*fc = FunctionCall{Token: v.Token, Name: v.Name, Params: params}
+	return nil
+}
+
+// MarshalJSON for CallExpression
+func (ce *CallExpression) MarshalJSON() ([]byte, error) {
+	type alias CallExpression
+	return marshalNode("CallExpression", (*alias)(ce))
+}
+
+// UnmarshalJSON for CallExpression
+func (ce *CallExpression) UnmarshalJSON(data []byte) error {
+	var v struct {
+		Kind     string            `json:"kind"`
+		Token    token.Token       `json:"token"`
+		Function json.RawMessage   `json:"function"`
+		Params   []json.RawMessage `json:"params"`
+	}
+
+	if err := json.Unmarshal(data, &v); err != nil {
+		return err
+	}
+	if err := checkKind("CallExpression", v.Kind); err != nil {
+		return err
+	}
+
+	function, err := UnmarshalExpression(v.Function)
+	if err != nil {
+		return err
+	}
+
+	params, err := unmarshalExpressions(v.Params)
+	if err != nil {
+		return err
+	}
+
+	*ce = CallExpression{Token: v.Token, Function: function, Params: params}
+	return nil
+}
+
+// MarshalJSON for Lambda
+func (l *Lambda) MarshalJSON() ([]byte, error) {
+	type alias Lambda
+	return marshalNode("Lambda", (*alias)(l))
+}
+
+// UnmarshalJSON for Lambda
+func (l *Lambda) UnmarshalJSON(data []byte) error {
+	var v struct {
+		Kind   string          `json:"kind"`
+		Token  token.Token     `json:"token"`
+		Params []*Ident        `json:"params"`
+		Body   json.RawMessage `json:"body"`
+	}
+
+	if err := json.Unmarshal(data, &v); err != nil {
+		return err
+	}
+	if err := checkKind("Lambda", v.Kind); err != nil {
+		return err
+	}
+
+	body, err := UnmarshalExpression(v.Body)
+	if err != nil {
+		return err
+	}
+
+	*l = Lambda{Token: v.Token, Params: v.Params, Body: body}
+	return nil
+}
+
+// MarshalJSON for ArrayLiteral
+func (al *ArrayLiteral) MarshalJSON() ([]byte, error) {
+	type alias ArrayLiteral
+	return marshalNode("ArrayLiteral", (*alias)(al))
+}
+
+// UnmarshalJSON for ArrayLiteral
+func (al *ArrayLiteral) UnmarshalJSON(data []byte) error {
+	var v struct {
+		Kind     string            `json:"kind"`
+		Token    token.Token       `json:"token"`
+		Elements []json.RawMessage `json:"elements"`
+	}
+
+	if err := json.Unmarshal(data, &v); err != nil {
+		return err
+	}
+	if err := checkKind("ArrayLiteral", v.Kind); err != nil {
+		return err
+	}
+
+	elements, err := unmarshalExpressions(v.Elements)
+	if err != nil {
+		return err
+	}
+
+	*al = ArrayLiteral{Token: v.Token, Elements: elements}
+	return nil
+}
+
+// MarshalJSON for ArrayExpression
+func (ae *ArrayExpression) MarshalJSON() ([]byte, error) {
+	type alias ArrayExpression
+	return marshalNode("ArrayExpression", (*alias)(ae))
+}
+
+// UnmarshalJSON for ArrayExpression
+func (ae *ArrayExpression) UnmarshalJSON(data []byte) error {
+	var v struct {
+		Kind     string          `json:"kind"`
+		Token    token.Token     `json:"token"`
+		Length   json.RawMessage `json:"length"`
+		Function json.RawMessage `json:"function"`
+	}
+
+	if err := json.Unmarshal(data, &v); err != nil {
+		return err
+	}
+	if err := checkKind("ArrayExpression", v.Kind); err != nil {
+		return err
+	}
+
+	exprs, err := unmarshalExpressions([]json.RawMessage{v.Length, v.Function})
+	if err != nil {
+		return err
+	}
+
+	*ae = ArrayExpression{Token: v.Token, Length: exprs[0], Function: exprs[1]}
+	return nil
+}
+
+// MarshalJSON for IndexExpression
+func (ie *IndexExpression) MarshalJSON() ([]byte, error) {
+	type alias IndexExpression
+	return marshalNode("IndexExpression", (*alias)(ie))
+}
+
+// UnmarshalJSON for IndexExpression
+func (ie *IndexExpression) UnmarshalJSON(data []byte) error {
+	var v struct {
+		Kind  string          `json:"kind"`
+		Token token.Token     `json:"token"`
+		Array json.RawMessage `json:"array"`
+		Index json.RawMessage `json:"index"`
+	}
+
+	if err := json.Unmarshal(data, &v); err != nil {
+		return err
+	}
+	if err := checkKind("IndexExpression", v.Kind); err != nil {
+		return err
+	}
+
+	exprs, err := unmarshalExpressions([]json.RawMessage{v.Array, v.Index})
+	if err != nil {
+		return err
+	}
+
+	*ie = IndexExpression{Token: v.Token, Array: exprs[0], Index: exprs[1]}
+	return nil
+}
+
+// MarshalJSON for LengthExpression
+func (le *LengthExpression) MarshalJSON() ([]byte, error) {
+	type alias LengthExpression
+	return marshalNode("LengthExpression", (*alias)(le))
+}
+
+// UnmarshalJSON for LengthExpression
+func (le *LengthExpression) UnmarshalJSON(data []byte) error {
+	var v struct {
+		Kind  string          `json:"kind"`
+		Token token.Token     `json:"token"`
+		Array json.RawMessage `json:"array"`
+	}
+
+	if err := json.Unmarshal(data, &v); err != nil {
+		return err
+	}
+	if err := checkKind("LengthExpression", v.Kind); err != nil {
+		return err
+	}
+
+	array, err := UnmarshalExpression(v.Array)
+	if err != nil {
+		return err
+	}
+
+	*le = LengthExpression{Token: v.Token, Array: array}
+	return nil
+}
+
+// MarshalJSON for IfExpression
+func (ie *IfExpression) MarshalJSON() ([]byte, error) {
+	type alias IfExpression
+	return marshalNode("IfExpression", (*alias)(ie))
+}
+
+// UnmarshalJSON for IfExpression
+func (ie *IfExpression) UnmarshalJSON(data []byte) error {
+	var v struct {
+		Kind        string          `json:"kind"`
+		Token       token.Token     `json:"token"`
+		Condition   json.RawMessage `json:"condition"`
+		Consequence json.RawMessage `json:"consequence"`
+		Alternative json.RawMessage `json:"alternative"`
+	}
+
+	if err := json.Unmarshal(data, &v); err != nil {
+		return err
+	}
+	if err := checkKind("IfExpression", v.Kind); err != nil {
+		return err
+	}
+
+	exprs, err := unmarshalExpressions([]json.RawMessage{v.Condition, v.Consequence, v.Alternative})
+	if err != nil {
+		return err
+	}
+
+	*ie = IfExpression{Token: v.Token, Condition: exprs[0], Consequence: exprs[1], Alternative: exprs[2]}
+	return nil
+}
+
+// MarshalJSON for CondExpression
+func (ce *CondExpression) MarshalJSON() ([]byte, error) {
+	type alias CondExpression
+	return marshalNode("CondExpression", (*alias)(ce))
+}
+
+// UnmarshalJSON for CondExpression
+func (ce *CondExpression) UnmarshalJSON(data []byte) error {
+	var v struct {
+		Kind        string          `json:"kind"`
+		Token       token.Token     `json:"token"`
+		Branches    []*CondBranch   `json:"branches"`
+		Alternative json.RawMessage `json:"alternative"`
+	}
+
+	if err := json.Unmarshal(data, &v); err != nil {
+		return err
+	}
+	if err := checkKind("CondExpression", v.Kind); err != nil {
+		return err
+	}
+
+	alt, err := UnmarshalExpression(v.Alternative)
+	if err != nil {
+		return err
+	}
+
+	*ce = CondExpression{Token: v.Token, Branches: v.Branches, Alternative: alt}
+	return nil
+}
+
+// MarshalJSON for CondBranch
+func (cb *CondBranch) MarshalJSON() ([]byte, error) {
+	type alias CondBranch
+	return marshalNode("CondBranch", (*alias)(cb))
+}
+
+// UnmarshalJSON for CondBranch
+func (cb *CondBranch) UnmarshalJSON(data []byte) error {
+	var v struct {
+		Kind        string          `json:"kind"`
+		Token       token.Token     `json:"token"`
+		Condition   json.RawMessage `json:"condition"`
+		Consequence json.RawMessage `json:"consequence"`
+	}
+
+	if err := json.Unmarshal(data, &v); err != nil {
+		return err
+	}
+	if err := checkKind("CondBranch", v.Kind); err != nil {
+		return err
+	}
+
+	exprs, err := unmarshalExpressions([]json.RawMessage{v.Condition, v.Consequence})
+	if err != nil {
+		return err
+	}
+
+	*cb = CondBranch{Token: v.Token, Condition: exprs[0], Consequence: exprs[1]}
+	return nil
+}
+
+// MarshalJSON for UnaryExpression
+func (ue *UnaryExpression) MarshalJSON() ([]byte, error) {
+	type alias UnaryExpression
+	return marshalNode("UnaryExpression", (*alias)(ue))
+}
+
+// UnmarshalJSON for UnaryExpression
+func (ue *UnaryExpression) UnmarshalJSON(data []byte) error {
+	var v struct {
+		Kind     string          `json:"kind"`
+		Token    token.Token     `json:"token"`
+		Operator token.TokenType `json:"operator"`
+		Operand  json.RawMessage `json:"operand"`
+	}
+
+	if err := json.Unmarshal(data, &v); err != nil {
+		return err
+	}
+	if err := checkKind("UnaryExpression", v.Kind); err != nil {
+		return err
+	}
+
+	operand, err := UnmarshalExpression(v.Operand)
+	if err != nil {
+		return err
+	}
+
+	*ue = UnaryExpression{Token: v.Token, Operator: v.Operator, Operand: operand}
+	return nil
+}
+
+// MarshalJSON for BinaryExpression
+func (be *BinaryExpression) MarshalJSON() ([]byte, error) {
+	type alias BinaryExpression
+	return marshalNode("BinaryExpression", (*alias)(be))
+}
+
+// UnmarshalJSON for BinaryExpression
+func (be *BinaryExpression) UnmarshalJSON(data []byte) error {
+	var v struct {
+		Kind     string          `json:"kind"`
+		Token    token.Token     `json:"token"`
+		Left     json.RawMessage `json:"left"`
+		Operator token.TokenType `json:"operator"`
+		Right    json.RawMessage `json:"right"`
+	}
+
+	if err := json.Unmarshal(data, &v); err != nil {
+		return err
+	}
+	if err := checkKind("BinaryExpression", v.Kind); err != nil {
+		return err
+	}
+
+	exprs, err := unmarshalExpressions([]json.RawMessage{v.Left, v.Right})
+	if err != nil {
+		return err
+	}
+
+	*be = BinaryExpression{Token: v.Token, Left: exprs[0], Operator: v.Operator, Right: exprs[1]}
+	return nil
+}
+
+// MarshalJSON for Binding
+func (b *Binding) MarshalJSON() ([]byte, error) {
+	type alias Binding
+	return marshalNode("Binding", (*alias)(b))
+}
+
+// UnmarshalJSON for Binding
+func (b *Binding) UnmarshalJSON(data []byte) error {
+	var v struct {
+		Kind  string          `json:"kind"`
+		Token token.Token     `json:"token"`
+		Ident *Ident          `json:"ident"`
+		Expr  json.RawMessage `json:"expr"`
+	}
+
+	if err := json.Unmarshal(data, &v); err != nil {
+		return err
+	}
+	if err := checkKind("Binding", v.Kind); err != nil {
+		return err
+	}
+
+	expr, err := UnmarshalExpression(v.Expr)
+	if err != nil {
+		return err
+	}
+
+	*b = Binding{Token: v.Token, Ident: v.Ident, Expr: expr}
+	return nil
+}
+
+// MarshalJSON for LetExpression
+func (le *LetExpression) MarshalJSON() ([]byte, error) {
+	type alias LetExpression
+	return marshalNode("LetExpression", (*alias)(le))
+}
+
+// UnmarshalJSON for LetExpression
+func (le *LetExpression) UnmarshalJSON(data []byte) error {
+	var v struct {
+		Kind     string          `json:"kind"`
+		Token    token.Token     `json:"token"`
+		Bindings []*Binding      `json:"bindings"`
+		Expr     json.RawMessage `json:"expr"`
+	}
+
+	if err := json.Unmarshal(data, &v); err != nil {
+		return err
+	}
+	if err := checkKind("LetExpression", v.Kind); err != nil {
+		return err
+	}
+
+	expr, err := UnmarshalExpression(v.Expr)
+	if err != nil {
+		return err
+	}
+
+	*le = LetExpression{Token: v.Token, Bindings: v.Bindings, Expr: expr}
+	return nil
+}
+
+// MarshalJSON for LoopExpression
+func (le *LoopExpression) MarshalJSON() ([]byte, error) {
+	type alias LoopExpression
+	return marshalNode("LoopExpression", (*alias)(le))
+}
+
+// UnmarshalJSON for LoopExpression
+func (le *LoopExpression) UnmarshalJSON(data []byte) error {
+	var v struct {
+		Kind     string          `json:"kind"`
+		Token    token.Token     `json:"token"`
+		Bindings []*Binding      `json:"bindings"`
+		Expr     json.RawMessage `json:"expr"`
+	}
+
+	if err := json.Unmarshal(data, &v); err != nil {
+		return err
+	}
+	if err := checkKind("LoopExpression", v.Kind); err != nil {
+		return err
+	}
+
+	expr, err := UnmarshalExpression(v.Expr)
+	if err != nil {
+		return err
+	}
+
+	*le = LoopExpression{Token: v.Token, Bindings: v.Bindings, Expr: expr}
+	return nil
+}
+
+// MarshalJSON for PrintExpression
+func (pe *PrintExpression) MarshalJSON() ([]byte, error) {
+	type alias PrintExpression
+	return marshalNode("PrintExpression", (*alias)(pe))
+}
+
+// UnmarshalJSON for PrintExpression
+func (pe *PrintExpression) UnmarshalJSON(data []byte) error {
+	var v struct {
+		Kind  string            `json:"kind"`
+		Token token.Token       `json:"token"`
+		Args  []json.RawMessage `json:"args"`
+	}
+
+	if err := json.Unmarshal(data, &v); err != nil {
+		return err
+	}
+	if err := checkKind("PrintExpression", v.Kind); err != nil {
+		return err
+	}
+
+	args, err := unmarshalExpressions(v.Args)
+	if err != nil {
+		return err
+	}
+
+	*pe = PrintExpression{Token: v.Token, Args: args}
+	return nil
+}
+
+// MarshalJSON for Sequence
+func (s *Sequence) MarshalJSON() ([]byte, error) {
+	type alias Sequence
+	return marshalNode("Sequence", (*alias)(s))
+}
+
+// UnmarshalJSON for Sequence
+func (s *Sequence) UnmarshalJSON(data []byte) error {
+	var v struct {
+		Kind  string            `json:"kind"`
+		Token token.Token       `json:"token"`
+		Exprs []json.RawMessage `json:"exprs"`
+	}
+
+	if err := json.Unmarshal(data, &v); err != nil {
+		return err
+	}
+	if err := checkKind("Sequence", v.Kind); err != nil {
+		return err
+	}
+
+	exprs, err := unmarshalExpressions(v.Exprs)
+	if err != nil {
+		return err
+	}
+
+	*s = Sequence{Token: v.Token, Exprs: exprs}
+	return nil
+}
+
+// MarshalJSON for Recur
+func (r *Recur) MarshalJSON() ([]byte, error) {
+	type alias Recur
+	return marshalNode("Recur", (*alias)(r))
+}
+
+// UnmarshalJSON for Recur
+func (r *Recur) UnmarshalJSON(data []byte) error {
+	var v struct {
+		Kind  string            `json:"kind"`
+		Token token.Token       `json:"token"`
+		Args  []json.RawMessage `json:"args"`
+	}
+
+	if err := json.Unmarshal(data, &v); err != nil {
+		return err
+	}
+	if err := checkKind("Recur", v.Kind); err != nil {
+		return err
+	}
+
+	args, err := unmarshalExpressions(v.Args)
+	if err != nil {
+		return err
+	}
+
+	*r = Recur{Token: v.Token, Args: args}
+	return nil
+}
@@ -0,0 +1,90 @@
+package ast_test
+
+import (
+	"bytes"
+	"io/ioutil"
+	"reflect"
+	"testing"
+
+	"github.com/simplang/ast"
+	"github.com/simplang/lexer"
+	"github.com/simplang/parser"
+)
+
+// programs are round-tripped in addition to testfile.txt, they use the
+// expressions the testfile doesn't
+var programs = []string{
+	`let sign x = if x == 0 then 0 elif x < 0 then -1 else 1 end end`,
+	`let apply f x = f (x) end
+let main x = apply (fn y -> y + x end) (1) end`,
+	`let main x = len (array (x) (fn i -> [i, x][1] end)) end`,
+}
+
+func TestJSONRoundTrip(t *testing.T) {
+	file, err := ioutil.ReadFile("../testfile.txt")
+	if err != nil {
+		t.Fatalf("could not read testfile: %s", err)
+	}
+
+	for i, src := range append([]string{string(file)}, programs...) {
+		p := parser.New(lexer.New(src))
+		prog := p.ParseProgram()
+		if len(p.Errors()) != 0 {
+			t.Fatalf("sources[%d] - parser errors: %v", i, p.Errors())
+		}
+
+		encoded, err := ast.MarshalExpression(prog)
+		if err != nil {
+			t.Fatalf("sources[%d] - could not encode program: %s", i, err)
+		}
+
+		decoded, err := ast.UnmarshalExpression(encoded)
+		if err != nil {
+			t.Fatalf("sources[%d] - could not decode program: %s", i, err)
+		}
+
+		if !reflect.DeepEqual(decoded, prog) {
+			t.Fatalf("sources[%d] - decoded program differs from parsed program", i)
+		}
+
+		reencoded, err := ast.MarshalExpression(decoded)
+		if err != nil {
+			t.Fatalf("sources[%d] - could not encode decoded program: %s", i, err)
+		}
+
+		if !bytes.Equal(encoded, reencoded) {
+			t.Fatalf("sources[%d] - encoding is not stable.\nfirst=%s\nsecond=%s", i, encoded, reencoded)
+		}
+	}
+}
+
+func TestJSONKind(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected ast.Expression
+	}{
+		{`{"kind":"Integer","token":{"type":"INT","literal":"4","line":1,"column":0},"value":4}`, &ast.Integer{}},
+		{`{"kind":"Ident","token":{"type":"IDENT","literal":"a","line":1,"column":0},"name":"a"}`, &ast.Ident{}},
+		{`{"kind":"Recur","token":{"type":"recur","literal":"recur","line":1,"column":0},"args":[]}`, &ast.Recur{}},
+	}
+
+	for i, tt := range tests {
+		e, err := ast.UnmarshalExpression([]byte(tt.input))
+		if err != nil {
+			t.Fatalf("tests[%d] - unexpected error: %s", i, err)
+		}
+
+		if reflect.TypeOf(e) != reflect.TypeOf(tt.expected) {
+			t.Fatalf("tests[%d] - wrong node type. expected=%T, got=%T", i, tt.expected, e)
+		}
+	}
+
+	if _, err := ast.UnmarshalExpression([]byte(`{"kind":"Nonsense"}`)); err == nil {
+		t.Fatalf("expected an error for an unknown kind")
+	}
+
+	var i ast.Integer
+	if err := i.UnmarshalJSON([]byte(`{"kind":"Ident"}`)); err == nil {
+		t.Fatalf("expected an error for a mismatching kind")
+	}
+}
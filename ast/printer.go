@@ -12,7 +12,21 @@ func printIndent(indent int) {
 // 4
 func (i *Integer) Print(indent int) {
 	printIndent(indent)
-	fmt.Println(i.Value)
+	fmt.Println(i.BigValue())
+}
+
+// Print Boolean
+// true
+func (b *Boolean) Print(indent int) {
+	printIndent(indent)
+	fmt.Println(b.Value)
+}
+
+// Print String
+// "abc"
+func (s *String) Print(indent int) {
+	printIndent(indent)
+	fmt.Printf("%q\n", s.Value)
 }
 
 // Print Identifier
@@ -26,11 +40,21 @@ func (i *Ident) Print(indent int) {
 // function
 //     ...
 func (p *Program) Print(indent int) {
+	for _, i := range p.Imports {
+		i.Print(indent + 1)
+	}
 	for _, f := range p.Functions {
 		f.Print(indent + 1)
 	}
 }
 
+// Print Import
+// import "math.simp"
+func (i *Import) Print(indent int) {
+	printIndent(indent)
+	fmt.Printf("import %q\n", i.Path)
+}
+
 // Print FunctionCall
 // add
 //   3
@@ -43,6 +67,34 @@ func (fc *FunctionCall) Print(indent int) {
 	}
 }
 
+// Print CallExpression
+// call
+//   f
+//   3
+func (ce *CallExpression) Print(indent int) {
+	printIndent(indent)
+	fmt.Println("call")
+	ce.Function.Print(indent + 1)
+	for _, arg := range ce.Params {
+		arg.Print(indent + 1)
+	}
+}
+
+// Print Lambda
+// fn
+//     x
+//   *
+//     x
+//     x
+func (l *Lambda) Print(indent int) {
+	printIndent(indent)
+	fmt.Println("fn")
+	for _, p := range l.Params {
+		p.Print(indent + 2)
+	}
+	l.Body.Print(indent + 1)
+}
+
 // Print Function
 // function
 //     main
@@ -59,7 +111,72 @@ func (f *Function) Print(indent int) {
 		p.Print(indent + 3)
 	}
 
-	f.Body.Print(indent + 1)
+	if len(f.Clauses) == 0 {
+		f.Body.Print(indent + 1)
+		return
+	}
+
+	for _, c := range f.Clauses {
+		c.Print(indent + 1)
+	}
+}
+
+// Print Clause
+// clause
+//     0
+//     b
+//   expression
+func (c *Clause) Print(indent int) {
+	printIndent(indent)
+	fmt.Println("clause")
+	for _, p := range c.Patterns {
+		p.Print(indent + 2)
+	}
+
+	c.Body.Print(indent + 1)
+}
+
+// Print array literal
+// []
+//   1
+//   2
+func (al *ArrayLiteral) Print(indent int) {
+	printIndent(indent)
+	fmt.Println("[]")
+	for _, e := range al.Elements {
+		e.Print(indent + 1)
+	}
+}
+
+// Print array expression
+// array
+//   n
+//   f
+func (ae *ArrayExpression) Print(indent int) {
+	printIndent(indent)
+	fmt.Println("array")
+	ae.Length.Print(indent + 1)
+	ae.Function.Print(indent + 1)
+}
+
+// Print index expression
+// [i]
+//   a
+//   i
+func (ie *IndexExpression) Print(indent int) {
+	printIndent(indent)
+	fmt.Println("[i]")
+	ie.Array.Print(indent + 1)
+	ie.Index.Print(indent + 1)
+}
+
+// Print length expression
+// len
+//   a
+func (le *LengthExpression) Print(indent int) {
+	printIndent(indent)
+	fmt.Println("len")
+	le.Array.Print(indent + 1)
 }
 
 // Print if expression
@@ -75,6 +192,32 @@ func (ie *IfExpression) Print(indent int) {
 	ie.Alternative.Print(indent + 1)
 }
 
+// Print cond expression
+// cond
+//     1 (condition)
+//     2 (consequence)
+//     3 (condition)
+//     4 (consequence)
+//   5 (alternative)
+func (ce *CondExpression) Print(indent int) {
+	printIndent(indent)
+	fmt.Println("cond")
+
+	for _, b := range ce.Branches {
+		b.Print(indent + 2)
+	}
+
+	ce.Alternative.Print(indent + 1)
+}
+
+// Print cond branch
+// 1 (condition)
+// 2 (consequence)
+func (cb *CondBranch) Print(indent int) {
+	cb.Condition.Print(indent)
+	cb.Consequence.Print(indent)
+}
+
 // Print unasy expression
 // !
 //   7
@@ -138,6 +281,30 @@ func (le *LoopExpression) Print(indent int) {
 	le.Expr.Print(indent + 1)
 }
 
+// Print print expression
+// print
+//   "x ="
+//   x
+func (pe *PrintExpression) Print(indent int) {
+	printIndent(indent)
+	fmt.Println("print")
+	for _, arg := range pe.Args {
+		arg.Print(indent + 1)
+	}
+}
+
+// Print sequence
+// ;
+//   1 (first expression)
+//   2 (result)
+func (s *Sequence) Print(indent int) {
+	printIndent(indent)
+	fmt.Println(";")
+	for _, e := range s.Exprs {
+		e.Print(indent + 1)
+	}
+}
+
 // Print recur
 // recur
 //   1
@@ -0,0 +1,117 @@
+package ast
+
+// Children returns the direct subexpressions of expr in source order
+func Children(expr Expression) []Expression {
+	switch e := expr.(type) {
+	case *Program:
+		res := make([]Expression, len(e.Functions))
+		for i, f := range e.Functions {
+			res[i] = f
+		}
+		return res
+
+	case *Function:
+		if len(e.Clauses) == 0 {
+			return []Expression{e.Body}
+		}
+
+		res := make([]Expression, len(e.Clauses))
+		for i, c := range e.Clauses {
+			res[i] = c
+		}
+		return res
+
+	case *Clause:
+		return append(append([]Expression{}, e.Patterns...), e.Body)
+
+	case *FunctionCall:
+		return e.Params
+
+	case *CallExpression:
+		return append([]Expression{e.Function}, e.Params...)
+
+	case *Lambda:
+		return []Expression{e.Body}
+
+	case *ArrayLiteral:
+		return e.Elements
+
+	case *ArrayExpression:
+		return []Expression{e.Length, e.Function}
+
+	case *IndexExpression:
+		return []Expression{e.Array, e.Index}
+
+	case *LengthExpression:
+		return []Expression{e.Array}
+
+	case *IfExpression:
+		return []Expression{e.Condition, e.Consequence, e.Alternative}
+
+	case *CondExpression:
+		res := make([]Expression, 0, len(e.Branches)+1)
+		for _, b := range e.Branches {
+			res = append(res, b)
+		}
+		return append(res, e.Alternative)
+
+	case *CondBranch:
+		return []Expression{e.Condition, e.Consequence}
+
+	case *UnaryExpression:
+		return []Expression{e.Operand}
+
+	case *BinaryExpression:
+		return []Expression{e.Left, e.Right}
+
+	case *Binding:
+		return []Expression{e.Expr}
+
+	case *LetExpression:
+		return bindingChildren(e.Bindings, e.Expr)
+
+	case *LoopExpression:
+		return bindingChildren(e.Bindings, e.Expr)
+
+	case *PrintExpression:
+		return e.Args
+
+	case *Sequence:
+		return e.Exprs
+
+	case *Recur:
+		return e.Args
+	}
+
+	return nil
+}
+
+func bindingChildren(binds []*Binding, expr Expression) []Expression {
+	res := make([]Expression, 0, len(binds)+1)
+	for _, b := range binds {
+		res = append(res, b)
+	}
+	return append(res, expr)
+}
+
+// Inspect traverses the tree below expr in depth-first order. It calls visit
+// for every node; if visit returns false, the children of that node are skipped.
+func Inspect(expr Expression, visit func(Expression) bool) {
+	if expr == nil || !visit(expr) {
+		return
+	}
+
+	for _, c := range Children(expr) {
+		Inspect(c, visit)
+	}
+}
+
+// Size returns the number of nodes in the tree below and including expr
+func Size(expr Expression) int {
+	n := 0
+	Inspect(expr, func(Expression) bool {
+		n++
+		return true
+	})
+	return n
+}
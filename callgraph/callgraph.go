@@ -0,0 +1,277 @@
+package callgraph
+
+import (
+	"sort"
+
+	"github.com/simplang/ast"
+)
+
+// Graph maps every top-level function to the names of the functions it calls,
+// in order of their first appearance in the body. Callees that are not defined
+// in the program have no entry of their own.
+type Graph map[string][]string
+
+// New builds the call graph of prog from its ast.FunctionCall nodes and the
+// identifiers naming top-level functions, which are constants or function values.
+// Calls and identifiers that refer to a variable are not edges, the calls inside
+// local functions and lambdas belong to the enclosing top-level function.
+func New(prog *ast.Program) Graph {
+	g := Graph{}
+
+	globals := map[string]bool{}
+	for _, f := range prog.Functions {
+		globals[f.Name.Name] = true
+	}
+
+	for _, f := range prog.Functions {
+		c := &collector{globals: globals, bound: map[string]int{}, seen: map[string]bool{}, callees: []string{}}
+		c.collect(f)
+		g[f.Name.Name] = c.callees
+	}
+
+	return g
+}
+
+// collector gathers the callees of one top-level function
+type collector struct {
+	globals map[string]bool
+	bound   map[string]int // variables in scope, with their nesting count
+	seen    map[string]bool
+	callees []string
+}
+
+func (c *collector) collect(expr ast.Expression) {
+	switch e := expr.(type) {
+	case *ast.FunctionCall:
+		if c.bound[e.Name] == 0 {
+			c.add(e.Name)
+		}
+
+	case *ast.Ident:
+		if c.bound[e.Name] == 0 && c.globals[e.Name] {
+			c.add(e.Name)
+		}
+
+	case *ast.Function:
+		c.within(identNames(e.Params), ast.Children(e)...)
+		return
+
+	case *ast.Lambda:
+		c.within(identNames(e.Params), e.Body)
+		return
+
+	case *ast.Clause:
+		names := []string{}
+		for _, p := range e.Patterns {
+			if ident, ok := p.(*ast.Ident); ok {
+				names = append(names, ident.Name)
+			}
+		}
+		// the patterns bind names, they don't refer to anything
+		c.within(names, e.Body)
+		return
+
+	case *ast.LetExpression:
+		c.bindings(e.Bindings, e.Expr)
+		return
+
+	case *ast.LoopExpression:
+		c.bindings(e.Bindings, e.Expr)
+		return
+	}
+
+	for _, child := range ast.Children(expr) {
+		c.collect(child)
+	}
+}
+
+// within collects exprs with names bound
+func (c *collector) within(names []string, exprs ...ast.Expression) {
+	for _, n := range names {
+		c.bound[n]++
+	}
+
+	for _, e := range exprs {
+		c.collect(e)
+	}
+
+	for _, n := range names {
+		c.bound[n]--
+	}
+}
+
+// bindings collects sequential bindings, each binding sees the ones before it and a
+// local function also sees itself
+func (c *collector) bindings(binds []*ast.Binding, body ast.Expression) {
+	for _, b := range binds {
+		if _, ok := b.Expr.(*ast.Function); ok {
+			c.bound[b.Ident.Name]++
+			c.collect(b.Expr)
+			continue
+		}
+
+		c.collect(b.Expr)
+		c.bound[b.Ident.Name]++
+	}
+
+	c.collect(body)
+
+	for _, b := range binds {
+		c.bound[b.Ident.Name]--
+	}
+}
+
+func (c *collector) add(name string) {
+	if !c.seen[name] {
+		c.seen[name] = true
+		c.callees = append(c.callees, name)
+	}
+}
+
+func identNames(idents []*ast.Ident) []string {
+	names := make([]string, len(idents))
+	for i, ident := range idents {
+		names[i] = ident.Name
+	}
+	return names
+}
+
+// IsDefined reports whether name is a function of the program
+func (g Graph) IsDefined(name string) bool {
+	_, ok := g[name]
+	return ok
+}
+
+// IsRecursive reports whether name can (directly or indirectly) call itself
+func (g Graph) IsRecursive(name string) bool {
+	return len(g.RecursiveEdges()[name]) != 0
+}
+
+// RecursiveEdges returns for each function the callees that lie on a cycle
+// with it, i.e. that are in the same strongly connected component.
+// Functions that are not recursive have no entry.
+func (g Graph) RecursiveEdges() map[string]map[string]bool {
+	component := g.components()
+
+	res := map[string]map[string]bool{}
+	for v, callees := range g {
+		for _, w := range callees {
+			if g.IsDefined(w) && component[v] == component[w] {
+				if res[v] == nil {
+					res[v] = map[string]bool{}
+				}
+				res[v][w] = true
+			}
+		}
+	}
+
+	return res
+}
+
+// Order returns all functions such that every function comes after the functions
+// it calls, except for calls within a recursive cycle
+func (g Graph) Order() []string {
+	visited := map[string]bool{}
+	order := make([]string, 0, len(g))
+
+	var visit func(v string)
+	visit = func(v string) {
+		visited[v] = true
+		for _, w := range g[v] {
+			if g.IsDefined(w) && !visited[w] {
+				visit(w)
+			}
+		}
+		order = append(order, v)
+	}
+
+	for _, name := range g.names() {
+		if !visited[name] {
+			visit(name)
+		}
+	}
+
+	return order
+}
+
+// Components returns the strongly connected components of g. Every component comes
+// after the components it calls, the functions of a component are sorted.
+func (g Graph) Components() [][]string {
+	component := g.components()
+
+	res := [][]string{}
+	for _, name := range g.names() {
+		c := component[name]
+		for len(res) <= c {
+			res = append(res, nil)
+		}
+		res[c] = append(res[c], name)
+	}
+
+	return res
+}
+
+// names returns the sorted function names for a deterministic traversal
+func (g Graph) names() []string {
+	names := make([]string, 0, len(g))
+	for name := range g {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// components numbers the strongly connected components of g using Tarjan's algorithm.
+// A component is numbered after all components it calls.
+func (g Graph) components() map[string]int {
+	index := map[string]int{}
+	lowlink := map[string]int{}
+	onStack := map[string]bool{}
+	stack := []string{}
+	component := map[string]int{}
+	components := 0
+
+	var connect func(v string)
+	connect = func(v string) {
+		index[v] = len(index)
+		lowlink[v] = index[v]
+		stack = append(stack, v)
+		onStack[v] = true
+
+		for _, w := range g[v] {
+			if !g.IsDefined(w) {
+				continue
+			}
+
+			if _, visited := index[w]; !visited {
+				connect(w)
+				if lowlink[w] < lowlink[v] {
+					lowlink[v] = lowlink[w]
+				}
+			} else if onStack[w] && index[w] < lowlink[v] {
+				lowlink[v] = index[w]
+			}
+		}
+
+		if lowlink[v] == index[v] {
+			for {
+				w := stack[len(stack)-1]
+				stack = stack[:len(stack)-1]
+				onStack[w] = false
+				component[w] = components
+				if w == v {
+					break
+				}
+			}
+			components++
+		}
+	}
+
+	for _, name := range g.names() {
+		if _, visited := index[name]; !visited {
+			connect(name)
+		}
+	}
+
+	return component
+}
@@ -0,0 +1,75 @@
+package checker
+
+import (
+	"fmt"
+
+	"github.com/simplang/arith"
+	"github.com/simplang/ast"
+	"github.com/simplang/interpreter"
+	"github.com/simplang/token"
+)
+
+// Check looks for suspicious but valid constructs in prog and returns a warning
+// for each of them
+func Check(prog *ast.Program) []string {
+	return CheckWith(prog, arith.Int{})
+}
+
+// CheckWith is like Check for programs computing with integers of type t, which
+// warns about negations if t is unsigned
+func CheckWith(prog *ast.Program, t arith.Int) []string {
+	warnings := []string{}
+
+	if t.Unsigned {
+		ast.Inspect(prog, func(expr ast.Expression) bool {
+			if u, ok := expr.(*ast.UnaryExpression); ok && u.Operator == token.MINUS {
+				warnings = append(warnings, fmt.Sprintf("warning: %s has no negative values, negating wraps around, saturates to 0 or overflows (line %d.%d)", t, u.Token.Line, u.Token.Column))
+			}
+			return true
+		})
+	}
+
+	for _, f := range prog.Functions {
+		warnings = append(warnings, unreachableClauses(f)...)
+
+		if interpreter.LookupBuiltin(f.Name.Name) != nil {
+			warnings = append(warnings, fmt.Sprintf("warning: function '%s' is hidden by the builtin of the same name (line %d.%d)", f.Name.Name, f.Token.Line, f.Token.Column))
+		}
+	}
+
+	return warnings
+}
+
+// unreachableClauses warns about clauses that can never be chosen, because an
+// earlier clause matches every argument they match
+func unreachableClauses(f *ast.Function) []string {
+	warnings := []string{}
+
+	for j, c := range f.Clauses {
+		for i, earlier := range f.Clauses[:j] {
+			if covers(earlier, c) {
+				warnings = append(warnings, fmt.Sprintf("warning: clause %d of function '%s' is unreachable, clause %d always matches first (line %d.%d)", j+1, f.Name.Name, i+1, c.Token.Line, c.Token.Column))
+				break
+			}
+		}
+	}
+
+	return warnings
+}
+
+// covers reports whether a matches all arguments b matches
+func covers(a *ast.Clause, b *ast.Clause) bool {
+	for i, pattern := range a.Patterns {
+		lit, ok := pattern.(*ast.Integer)
+		if !ok {
+			continue
+		}
+
+		other, ok := b.Patterns[i].(*ast.Integer)
+		if !ok || other.BigValue().Cmp(lit.BigValue()) != 0 {
+			return false
+		}
+	}
+
+	return true
+}
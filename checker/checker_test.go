@@ -0,0 +1,68 @@
+package checker
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/simplang/arith"
+	"github.com/simplang/lexer"
+	"github.com/simplang/parser"
+)
+
+func TestUnreachableClauses(t *testing.T) {
+	input := `let f 0 y = y end
+let f x 0 = x end
+let f 0 0 = 1 end
+let f x y = x + y end
+let f 1 2 = 3 end
+let g 0 = 1 end
+let g x = x end
+let g y = 2 * y end`
+
+	p := parser.New(lexer.New(input))
+	prog := p.ParseProgram()
+	if len(p.Errors()) != 0 {
+		t.Fatalf("parser errors: %v", p.Errors())
+	}
+
+	expected := []string{
+		"warning: clause 3 of function 'f' is unreachable, clause 1 always matches first (line 3.1)",
+		"warning: clause 5 of function 'f' is unreachable, clause 4 always matches first (line 5.1)",
+		"warning: clause 3 of function 'g' is unreachable, clause 2 always matches first (line 8.1)",
+	}
+
+	if got := Check(prog); !reflect.DeepEqual(got, expected) {
+		t.Fatalf("warnings wrong. expected=%v, got=%v", expected, got)
+	}
+}
+
+func TestHiddenByBuiltin(t *testing.T) {
+	p := parser.New(lexer.New("let main x = isqrt (x) end\nlet isqrt x = x end"))
+	prog := p.ParseProgram()
+	if len(p.Errors()) != 0 {
+		t.Fatalf("parser errors: %v", p.Errors())
+	}
+
+	expected := []string{"warning: function 'isqrt' is hidden by the builtin of the same name (line 2.1)"}
+
+	if got := Check(prog); !reflect.DeepEqual(got, expected) {
+		t.Fatalf("warnings wrong. expected=%v, got=%v", expected, got)
+	}
+}
+
+func TestUnsignedNegations(t *testing.T) {
+	p := parser.New(lexer.New("let main x = if x - 1 < 0 then -x else x end end"))
+	prog := p.ParseProgram()
+	if len(p.Errors()) != 0 {
+		t.Fatalf("parser errors: %v", p.Errors())
+	}
+
+	expected := []string{"warning: uint16 has no negative values, negating wraps around, saturates to 0 or overflows (line 1.31)"}
+
+	if got := CheckWith(prog, arith.Int{Bits: 16, Unsigned: true}); !reflect.DeepEqual(got, expected) {
+		t.Fatalf("warnings wrong. expected=%v, got=%v", expected, got)
+	}
+	if got := CheckWith(prog, arith.Int{Bits: 16}); len(got) != 0 {
+		t.Fatalf("expected no warnings for int16, got %v", got)
+	}
+}
@@ -0,0 +1,646 @@
+package checker
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/simplang/ast"
+	"github.com/simplang/callgraph"
+	"github.com/simplang/interpreter"
+	"github.com/simplang/token"
+)
+
+// Type is the static type of an expression
+type Type interface {
+	String() string
+}
+
+type basic string
+
+func (b basic) String() string {
+	return string(b)
+}
+
+// the basic types
+var (
+	Int    Type = basic("int")
+	Bool   Type = basic("bool")
+	String Type = basic("string")
+)
+
+// Func is the type of a function taking Params and returning Result
+type Func struct {
+	Params []Type
+	Result Type
+}
+
+func (f *Func) String() string {
+	params := make([]string, len(f.Params))
+	for i, p := range f.Params {
+		params[i] = p.String()
+	}
+	return fmt.Sprintf("fn(%s) -> %s", strings.Join(params, ", "), f.Result)
+}
+
+// Array is the type of an array whose elements have the type Elem
+type Array struct {
+	Elem Type
+}
+
+func (a *Array) String() string {
+	return fmt.Sprintf("[%s]", a.Elem)
+}
+
+// Var is a type which is not known yet. Once it is unified with another type,
+// it stands for that type.
+type Var struct {
+	id       int
+	instance Type
+}
+
+func (v *Var) String() string {
+	if v.instance != nil {
+		return v.instance.String()
+	}
+	return fmt.Sprintf("t%d", v.id)
+}
+
+// Resolve returns the type t stands for, a variable which has been unified with
+// another type is replaced by that type
+func Resolve(t Type) Type {
+	return prune(t)
+}
+
+// prune returns the type t stands for, following the instances of variables
+func prune(t Type) Type {
+	for {
+		v, ok := t.(*Var)
+		if !ok || v.instance == nil {
+			return t
+		}
+		t = v.instance
+	}
+}
+
+// scheme is the type of a top-level function. The vars can be instantiated with
+// different types at every use, so e.g. "let id x = x end" works for ints and bools.
+type scheme struct {
+	vars []*Var
+	t    Type
+}
+
+// scope binds the variables visible at some point, innermost first
+type scope struct {
+	name  string
+	t     Type
+	outer *scope
+}
+
+func (s *scope) bind(name string, t Type) *scope {
+	return &scope{name: name, t: t, outer: s}
+}
+
+func (s *scope) lookup(name string) (Type, bool) {
+	for ; s != nil; s = s.outer {
+		if s.name == name {
+			return s.t, true
+		}
+	}
+	return nil, false
+}
+
+type typeChecker struct {
+	globals map[string]*scheme
+	loops   [][]Type // binding types of the enclosing loops, innermost last
+	vars    int
+	errors  []string
+	builtin func(name string) *interpreter.Builtin
+}
+
+// Types infers the type of every top-level function of prog. The parameters and
+// bindings need no annotations, their types follow from their uses. It returns the
+// types by function name along with the type errors found.
+//
+// Functions are checked callees first. Each group of mutually recursive functions
+// gets one type per function, which is then generalized, so top-level functions
+// can be used with different types. Local functions and lambdas can't.
+//
+// Conditions and the operands of !, && and || are bools. Programs written when
+// they were ints have to compare them with 0, "if x then" becomes "if x != 0 then",
+// and functions used as conditions return true or false instead of 1 or 0.
+func Types(prog *ast.Program) (map[string]Type, []string) {
+	return TypesWith(prog, interpreter.LookupBuiltin)
+}
+
+// TypesWith is like Types, but the builtins are the ones returned by builtin
+// instead of the registered ones
+func TypesWith(prog *ast.Program, builtin func(name string) *interpreter.Builtin) (map[string]Type, []string) {
+	c := &typeChecker{globals: map[string]*scheme{}, errors: []string{}, builtin: builtin}
+
+	functions := map[string]*ast.Function{}
+	for _, f := range prog.Functions {
+		functions[f.Name.Name] = f
+	}
+
+	for _, component := range callgraph.New(prog).Components() {
+		c.checkComponent(component, functions)
+	}
+
+	if main, ok := functions["main"]; ok {
+		c.checkMain(main)
+	}
+
+	types := map[string]Type{}
+	for name, s := range c.globals {
+		types[name] = s.t
+	}
+
+	return types, c.errors
+}
+
+// checkComponent infers the types of a group of mutually recursive functions
+func (c *typeChecker) checkComponent(names []string, functions map[string]*ast.Function) {
+	types := make([]*Func, len(names))
+	for i, name := range names {
+		types[i] = c.function(len(functions[name].Params))
+
+		// uses inside the group are monomorphic
+		c.globals[name] = &scheme{t: functionType(types[i])}
+	}
+
+	for i, name := range names {
+		f := functions[name]
+
+		if len(f.Clauses) == 0 {
+			var sc *scope
+			for j, p := range f.Params {
+				sc = sc.bind(p.Name, types[i].Params[j])
+			}
+			c.expect(c.infer(f.Body, sc), types[i].Result, &f.Token, fmt.Sprintf("body of '%s'", name))
+			continue
+		}
+
+		for _, clause := range f.Clauses {
+			var sc *scope
+			for j, pattern := range clause.Patterns {
+				switch p := pattern.(type) {
+				case *ast.Ident:
+					sc = sc.bind(p.Name, types[i].Params[j])
+				case *ast.Integer:
+					c.expect(types[i].Params[j], Int, &p.Token, fmt.Sprintf("parameter %d of '%s'", j+1, name))
+				}
+			}
+			c.expect(c.infer(clause.Body, sc), types[i].Result, &clause.Token, fmt.Sprintf("body of '%s'", name))
+		}
+	}
+
+	for _, name := range names {
+		t := c.globals[name].t
+		c.globals[name] = &scheme{vars: freeVars(t, nil), t: t}
+	}
+}
+
+// checkMain makes sure main can be called with integers from the command line
+func (c *typeChecker) checkMain(main *ast.Function) {
+	t := c.globals["main"].t
+
+	f, ok := prune(t).(*Func)
+	if !ok {
+		c.expect(t, Int, &main.Token, "main")
+		return
+	}
+
+	for i, p := range f.Params {
+		c.expect(p, Int, &main.Token, fmt.Sprintf("parameter %d of main", i+1))
+	}
+	c.expect(f.Result, Int, &main.Token, "result of main")
+}
+
+// function returns a function type with n parameters whose types are not known yet
+func (c *typeChecker) function(n int) *Func {
+	f := &Func{Params: make([]Type, n), Result: c.fresh()}
+	for i := range f.Params {
+		f.Params[i] = c.fresh()
+	}
+	return f
+}
+
+// functionType is the type of a top-level function, a constant has the type of its value
+func functionType(f *Func) Type {
+	if len(f.Params) == 0 {
+		return f.Result
+	}
+	return f
+}
+
+func (c *typeChecker) fresh() *Var {
+	c.vars++
+	return &Var{id: c.vars}
+}
+
+func (c *typeChecker) infer(expr ast.Expression, sc *scope) Type {
+	switch e := expr.(type) {
+	case *ast.Integer:
+		return Int
+
+	case *ast.Boolean:
+		return Bool
+
+	case *ast.String:
+		return String
+
+	case *ast.Ident:
+		return c.variable(e.Name, sc, &e.Token)
+
+	case *ast.FunctionCall:
+		callee := c.callee(e.Name, sc, &e.Token)
+		return c.call(callee, e.Params, sc, &e.Token, fmt.Sprintf("'%s'", e.Name))
+
+	case *ast.CallExpression:
+		callee := c.infer(e.Function, sc)
+		return c.call(callee, e.Params, sc, &e.Token, "the function")
+
+	case *ast.Lambda:
+		f := c.function(len(e.Params))
+		for i, p := range e.Params {
+			sc = sc.bind(p.Name, f.Params[i])
+		}
+		c.expect(c.inferBody(e.Body, sc), f.Result, &e.Token, "body of fn")
+		return f
+
+	case *ast.ArrayLiteral:
+		elem := Type(c.fresh())
+		for i, el := range e.Elements {
+			c.expect(c.infer(el, sc), elem, &e.Token, fmt.Sprintf("element %d", i+1))
+		}
+		return &Array{Elem: elem}
+
+	case *ast.ArrayExpression:
+		c.expect(c.infer(e.Length, sc), Int, &e.Token, "length of array")
+		elem := c.fresh()
+		c.expect(c.infer(e.Function, sc), &Func{Params: []Type{Int}, Result: elem}, &e.Token, "function of array")
+		return &Array{Elem: elem}
+
+	case *ast.IndexExpression:
+		elem := c.fresh()
+		c.expect(c.infer(e.Array, sc), &Array{Elem: elem}, &e.Token, "indexed value")
+		c.expect(c.infer(e.Index, sc), Int, &e.Token, "index")
+		return elem
+
+	case *ast.LengthExpression:
+		c.expect(c.infer(e.Array, sc), &Array{Elem: c.fresh()}, &e.Token, "operand of len")
+		return Int
+
+	case *ast.IfExpression:
+		c.expect(c.infer(e.Condition, sc), Bool, &e.Token, "condition of if")
+		res := c.infer(e.Consequence, sc)
+		c.expect(c.infer(e.Alternative, sc), res, &e.Token, "else branch")
+		return res
+
+	case *ast.CondExpression:
+		res := Type(c.fresh())
+		for _, b := range e.Branches {
+			c.expect(c.infer(b.Condition, sc), Bool, &b.Token, "condition of if")
+			c.expect(c.infer(b.Consequence, sc), res, &b.Token, "then branch")
+		}
+		c.expect(c.infer(e.Alternative, sc), res, &e.Token, "else branch")
+		return res
+
+	case *ast.UnaryExpression:
+		operand := c.infer(e.Operand, sc)
+		if e.Operator == token.NOT {
+			c.expect(operand, Bool, &e.Token, "operand of !")
+			return Bool
+		}
+		c.expect(operand, Int, &e.Token, fmt.Sprintf("operand of %s", e.Operator))
+		return Int
+
+	case *ast.BinaryExpression:
+		return c.binary(e, sc)
+
+	case *ast.LetExpression:
+		sc, _ = c.bindings(e.Bindings, sc)
+		return c.infer(e.Expr, sc)
+
+	case *ast.LoopExpression:
+		sc, frame := c.bindings(e.Bindings, sc)
+
+		c.loops = append(c.loops, frame)
+		res := c.infer(e.Expr, sc)
+		c.loops = c.loops[:len(c.loops)-1]
+		return res
+
+	case *ast.PrintExpression:
+		var res Type
+		for _, a := range e.Args {
+			res = c.infer(a, sc)
+		}
+		return res
+
+	case *ast.Sequence:
+		var res Type
+		for _, expr := range e.Exprs {
+			res = c.infer(expr, sc)
+		}
+		return res
+
+	case *ast.Recur:
+		if len(c.loops) == 0 {
+			c.errorf(&e.Token, "recur outside of a loop")
+			return c.fresh()
+		}
+
+		frame := c.loops[len(c.loops)-1]
+		if len(e.Args) != len(frame) {
+			c.errorf(&e.Token, "recur has %d argument(s), the loop binds %d", len(e.Args), len(frame))
+			return c.fresh()
+		}
+
+		for i, a := range e.Args {
+			c.expect(c.infer(a, sc), frame[i], &e.Token, fmt.Sprintf("argument %d of recur", i+1))
+		}
+
+		// recur doesn't produce a value, it fits any type
+		return c.fresh()
+	}
+
+	// parts missing after syntax errors
+	return c.fresh()
+}
+
+// inferBody infers the type of the body of a function, which can't recur to the
+// loops around the function
+func (c *typeChecker) inferBody(body ast.Expression, sc *scope) Type {
+	loops := c.loops
+	c.loops = nil
+	t := c.infer(body, sc)
+	c.loops = loops
+	return t
+}
+
+// variable returns the type of a variable or top-level function
+func (c *typeChecker) variable(name string, sc *scope, t *token.Token) Type {
+	if v, ok := sc.lookup(name); ok {
+		return v
+	}
+
+	if s, ok := c.globals[name]; ok {
+		return c.instantiate(s)
+	}
+
+	if c.builtin(name) != nil {
+		c.errorf(t, "builtin '%s' can only be called", name)
+		return c.fresh()
+	}
+
+	c.errorf(t, "'%s' is not defined", name)
+	return c.fresh()
+}
+
+// callee returns the type of the function called by name, builtins hide the
+// top-level functions
+func (c *typeChecker) callee(name string, sc *scope, t *token.Token) Type {
+	if _, ok := sc.lookup(name); !ok {
+		if b := c.builtin(name); b != nil {
+			f := &Func{Params: make([]Type, b.Arity), Result: Int}
+			for i := range f.Params {
+				f.Params[i] = Int
+			}
+			return f
+		}
+	}
+
+	return c.variable(name, sc, t)
+}
+
+func (c *typeChecker) call(callee Type, args []ast.Expression, sc *scope, t *token.Token, name string) Type {
+	types := make([]Type, len(args))
+	for i, a := range args {
+		types[i] = c.infer(a, sc)
+	}
+
+	switch f := prune(callee).(type) {
+	case *Var:
+		res := c.fresh()
+		c.expect(f, &Func{Params: types, Result: res}, t, name)
+		return res
+
+	case *Func:
+		if len(f.Params) != len(types) {
+			c.errorf(t, "%s takes %d argument(s), got %d", name, len(f.Params), len(types))
+			return f.Result
+		}
+
+		for i, a := range types {
+			c.expect(a, f.Params[i], t, fmt.Sprintf("argument %d of %s", i+1, name))
+		}
+		return f.Result
+	}
+
+	c.errorf(t, "%s has type %s, it is not a function", name, callee)
+	return c.fresh()
+}
+
+func (c *typeChecker) binary(e *ast.BinaryExpression, sc *scope) Type {
+	l := c.infer(e.Left, sc)
+	r := c.infer(e.Right, sc)
+	operands := fmt.Sprintf("operand of %s", e.Operator)
+
+	switch e.Operator {
+	case token.LOG_AND, token.LOG_OR:
+		c.expect(l, Bool, &e.Token, operands)
+		c.expect(r, Bool, &e.Token, operands)
+		return Bool
+
+	case token.EQUAL, token.NOT_EQUAL:
+		c.expect(r, l, &e.Token, fmt.Sprintf("right %s", operands))
+		if hasFunc(l) {
+			c.errorf(&e.Token, "functions can't be compared with %s", e.Operator)
+		}
+		return Bool
+
+	case token.LESS, token.GREATER, token.LESS_EQUAL, token.GREATER_EQUAL:
+		c.expect(l, Int, &e.Token, operands)
+		c.expect(r, Int, &e.Token, operands)
+		return Bool
+	}
+
+	c.expect(l, Int, &e.Token, operands)
+	c.expect(r, Int, &e.Token, operands)
+	return Int
+}
+
+// hasFunc reports whether values of type t contain functions
+func hasFunc(t Type) bool {
+	switch t := prune(t).(type) {
+	case *Func:
+		return true
+	case *Array:
+		return hasFunc(t.Elem)
+	}
+	return false
+}
+
+// bindings checks sequential bindings and returns the scope with all of them along
+// with their types. A local function sees itself, so it can be recursive.
+func (c *typeChecker) bindings(binds []*ast.Binding, sc *scope) (*scope, []Type) {
+	types := make([]Type, len(binds))
+
+	for i, b := range binds {
+		local, ok := b.Expr.(*ast.Function)
+		if !ok {
+			types[i] = c.infer(b.Expr, sc)
+			sc = sc.bind(b.Ident.Name, types[i])
+			continue
+		}
+
+		f := c.function(len(local.Params))
+		types[i] = f
+		sc = sc.bind(b.Ident.Name, f)
+
+		inner := sc
+		for i, p := range local.Params {
+			inner = inner.bind(p.Name, f.Params[i])
+		}
+		c.expect(c.inferBody(local.Body, inner), f.Result, &b.Token, fmt.Sprintf("body of '%s'", b.Ident.Name))
+	}
+
+	return sc, types
+}
+
+// expect reports an error at tok if t can't be unified with want
+func (c *typeChecker) expect(t Type, want Type, tok *token.Token, what string) {
+	if !unify(t, want) {
+		c.errorf(tok, "%s has type %s, expected %s%s", what, t, want, boolHint(t, want))
+	}
+}
+
+// boolHint tells how to turn an int into a bool or a bool into an int, programs
+// written before there was a bool type use ints as conditions
+func boolHint(t Type, want Type) string {
+	switch {
+	case prune(t) == Int && prune(want) == Bool:
+		return ", compare the int with 0 like x != 0"
+	case prune(t) == Bool && prune(want) == Int:
+		return ", turn the bool into an int like if b then 1 else 0 end"
+	}
+	return ""
+}
+
+// unify makes a and b the same type by binding variables, it reports whether that
+// is possible
+func unify(a Type, b Type) bool {
+	a, b = prune(a), prune(b)
+
+	if v, ok := a.(*Var); ok {
+		if a == b {
+			return true
+		}
+		if occurs(v, b) {
+			return false
+		}
+		v.instance = b
+		return true
+	}
+
+	if _, ok := b.(*Var); ok {
+		return unify(b, a)
+	}
+
+	switch a := a.(type) {
+	case basic:
+		return a == b
+
+	case *Func:
+		f, ok := b.(*Func)
+		if !ok || len(a.Params) != len(f.Params) {
+			return false
+		}
+
+		for i := range a.Params {
+			if !unify(a.Params[i], f.Params[i]) {
+				return false
+			}
+		}
+		return unify(a.Result, f.Result)
+
+	case *Array:
+		arr, ok := b.(*Array)
+		return ok && unify(a.Elem, arr.Elem)
+	}
+
+	return false
+}
+
+// occurs reports whether v appears in t, binding v to t would make it infinite
+func occurs(v *Var, t Type) bool {
+	for _, w := range freeVars(t, nil) {
+		if w == v {
+			return true
+		}
+	}
+	return false
+}
+
+// freeVars appends the unbound variables in t to vars
+func freeVars(t Type, vars []*Var) []*Var {
+	switch t := prune(t).(type) {
+	case *Var:
+		for _, v := range vars {
+			if v == t {
+				return vars
+			}
+		}
+		return append(vars, t)
+
+	case *Func:
+		for _, p := range t.Params {
+			vars = freeVars(p, vars)
+		}
+		return freeVars(t.Result, vars)
+
+	case *Array:
+		return freeVars(t.Elem, vars)
+	}
+
+	return vars
+}
+
+// instantiate returns the type of s with fresh variables
+func (c *typeChecker) instantiate(s *scheme) Type {
+	if len(s.vars) == 0 {
+		return s.t
+	}
+
+	fresh := map[*Var]Type{}
+	for _, v := range s.vars {
+		fresh[v] = c.fresh()
+	}
+	return substitute(s.t, fresh)
+}
+
+func substitute(t Type, vars map[*Var]Type) Type {
+	switch t := prune(t).(type) {
+	case *Var:
+		if s, ok := vars[t]; ok {
+			return s
+		}
+		return t
+
+	case *Func:
+		f := &Func{Params: make([]Type, len(t.Params)), Result: substitute(t.Result, vars)}
+		for i, p := range t.Params {
+			f.Params[i] = substitute(p, vars)
+		}
+		return f
+
+	case *Array:
+		return &Array{Elem: substitute(t.Elem, vars)}
+	}
+
+	return prune(t)
+}
+
+func (c *typeChecker) errorf(t *token.Token, format string, args ...interface{}) {
+	c.errors = append(c.errors, fmt.Sprintf("%s (line %d.%d)", fmt.Sprintf(format, args...), t.Line, t.Column))
+}
@@ -0,0 +1,144 @@
+package checker
+
+import (
+	"io/ioutil"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/simplang/ast"
+	"github.com/simplang/lexer"
+	"github.com/simplang/parser"
+)
+
+func parse(t *testing.T, input string) *ast.Program {
+	p := parser.New(lexer.New(input))
+	prog := p.ParseProgram()
+	if len(p.Errors()) != 0 {
+		t.Fatalf("parser errors: %v", p.Errors())
+	}
+	return prog
+}
+
+func TestInferTypes(t *testing.T) {
+	prog := parse(t, `let add x y = x + y end
+let even n = if n == 0 then true else odd (n - 1) end end
+let odd n = if n == 0 then false else even (n - 1) end end
+let id x = x end
+let compose f g = fn x -> f (g (x)) end end
+let limit = 10 end
+let sum a = loop i = 0 and s = 0 in if i < len (a) then recur (i + 1) (s + a[i]) else s end end end
+let squares n = array (n) (fn i -> i * i end) end
+let count p n = loop i = 0 and c = 0 in if i == n then c elif p (i) then recur (i + 1) (c + 1) else recur (i + 1) (c) end end end
+let main x = if id (even (x)) then count ((compose (odd) (id))) (id (limit)) else add (x) (1) end end`)
+
+	types, errs := Types(prog)
+	if len(errs) != 0 {
+		t.Fatalf("unexpected type errors: %v", errs)
+	}
+
+	expected := map[string]string{
+		"add":     "fn(int, int) -> int",
+		"even":    "fn(int) -> bool",
+		"odd":     "fn(int) -> bool",
+		"limit":   "int",
+		"sum":     "fn([int]) -> int",
+		"squares": "fn(int) -> [int]",
+		"count":   "fn(fn(int) -> bool, int) -> int",
+		"main":    "fn(int) -> int",
+	}
+
+	for name, want := range expected {
+		if got := types[name].String(); got != want {
+			t.Errorf("type of %s wrong. expected=%s, got=%s", name, want, got)
+		}
+	}
+}
+
+func TestTypeErrors(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"let main i j = if i * j then 1 else 0 end end", "condition of if has type int, expected bool, compare the int with 0 like x != 0 (line 1.15)"},
+		{"let main x = x + (x < 1) end", "operand of + has type bool, expected int"},
+		{"let main x = if x == 1 then x else false end end", "else branch has type bool, expected int"},
+		{"let main x = if !(x + 1) then 1 else 0 end end", "operand of ! has type int, expected bool"},
+		{"let main x = let y = x + 1 in y (1) end end", "'y' has type int, it is not a function"},
+		{"let f x = x end let main x = f (x) (x) end", "'f' takes 1 argument(s), got 2"},
+		{"let f x = x end let main x = if f (true) then x else f (x) end end", ""},
+		{"let main x = let g = fn y -> y end in if g (x > 1) then x else g (x) end end end", "argument 1 of 'g' has type int, expected bool"},
+		{"let main x = recur (x) end", "recur outside of a loop"},
+		{"let main x = loop i = 0 in recur (i) (x) end end", "recur has 2 argument(s), the loop binds 1"},
+		{"let main x = x == 1 end", "result of main has type bool, expected int"},
+		{"let main x = y end", "'y' is not defined"},
+		{"let f x = x (x) end let main x = 1 end", "'x' has type t"},
+		{"let main x = (fn y -> y end) == (fn y -> y end) end", "functions can't be compared with =="},
+		{"let main x = if [[fn y -> y end]] != [] then 1 else 0 end end", "functions can't be compared with !="},
+		{"let main x = [x, x > 1][0] end", "element 2 has type bool, expected int"},
+		{"let main x = [x][x > 1] end", "index has type bool, expected int"},
+		{"let main x = (x + 1)[0] end", "indexed value has type int, expected [t"},
+		{"let main x = len (x + 1) end", "operand of len has type int, expected [t"},
+		{"let main x = array (x) (fn i j -> i end)[0] end", "function of array has type fn(t5, t7) -> t5, expected fn(int) -> t4"},
+		{"let main x = len ([]) + len ([true]) + [[x]][0][0] end", ""},
+		{"let trace s x = print (s) (x); x end let main x = trace (\"x\") (x); trace ([x]) (x > 0); x end", ""},
+		{"let main x = isqrt (pow (x) (2)) + popcount (x) end", ""},
+		{"let main x = let isqrt = fn b -> if b then 1 else 0 end end in isqrt (x > 1) end end", ""},
+		{"let isqrt b = if b then 1 else 0 end end let main x = isqrt (x > 1) end", "argument 1 of 'isqrt' has type bool, expected int"},
+		{"let main x = isqrt (x) (x) end", "'isqrt' takes 1 argument(s), got 2"},
+		{"let main x = isqrt (x > 1) end", "argument 1 of 'isqrt' has type bool, expected int"},
+		{"let main x = (fn f -> f (x) end) (isqrt) end", "builtin 'isqrt' can only be called"},
+		{"let main x = print (x) (\"done\") end", "result of main has type string, expected int"},
+		{"let main x = if \"a\" == \"b\" then 1 else 0 end end", ""},
+		{"let main x = if \"a\" == x + 1 then 1 else 0 end end", "right operand of == has type int, expected string"},
+	}
+
+	for i, tt := range tests {
+		_, errs := Types(parse(t, tt.input))
+
+		if tt.expected == "" {
+			if len(errs) != 0 {
+				t.Errorf("tests[%d] - unexpected type errors: %v", i, errs)
+			}
+			continue
+		}
+
+		if len(errs) == 0 || !strings.Contains(errs[0], tt.expected) {
+			t.Errorf("tests[%d] - expected an error containing %q, got %v", i, tt.expected, errs)
+		}
+	}
+}
+
+func TestTestfileTypes(t *testing.T) {
+	file, err := ioutil.ReadFile("../testfile.txt")
+	if err != nil {
+		t.Fatalf("could not read testfile: %s", err)
+	}
+
+	if _, errs := Types(parse(t, string(file))); len(errs) != 0 {
+		t.Fatalf("unexpected type errors: %v", errs)
+	}
+}
+
+func TestIntConditions(t *testing.T) {
+	// conditions and results of predicates the way they were written before there
+	// was a bool type
+	src := `let isodd x = x % 2 end
+let bitset x i = (x >> i) % 2 == 1 end
+let odds x = if isodd (x) then 1 else 0 end end
+let bits x = bitset (x) (0) + bitset (x) (1) end
+let main x = if !(x + 1) || odds (x) && bits (x) then 1 else 0 end end`
+
+	expected := []string{
+		"operand of + has type bool, expected int, turn the bool into an int like if b then 1 else 0 end (line 4.29)",
+		"operand of + has type bool, expected int, turn the bool into an int like if b then 1 else 0 end (line 4.29)",
+		"condition of if has type int, expected bool, compare the int with 0 like x != 0 (line 3.14)",
+		"operand of ! has type int, expected bool, compare the int with 0 like x != 0 (line 5.17)",
+		"operand of || has type int, expected bool, compare the int with 0 like x != 0 (line 5.26)",
+		"operand of && has type int, expected bool, compare the int with 0 like x != 0 (line 5.38)",
+	}
+
+	if _, errs := Types(parse(t, src)); !reflect.DeepEqual(errs, expected) {
+		t.Fatalf("type errors wrong. expected=%q, got=%q", expected, errs)
+	}
+}
@@ -0,0 +1,223 @@
+package dot
+
+import (
+	"fmt"
+	"io"
+	"reflect"
+	"strconv"
+
+	"github.com/simplang/ast"
+	"github.com/simplang/callgraph"
+)
+
+// Tree writes a Graphviz digraph of the syntax tree of expression.
+// expression is usually an *ast.Program or a single *ast.Function.
+func Tree(w io.Writer, expression ast.Expression) {
+	t := &tree{w: w}
+
+	fmt.Fprintln(w, "digraph ast {")
+	fmt.Fprintln(w, "  node [shape=box, fontname=\"monospace\"];")
+	t.node(expression)
+	fmt.Fprintln(w, "}")
+}
+
+type tree struct {
+	w    io.Writer
+	next int
+}
+
+type child struct {
+	label string
+	expr  ast.Expression
+}
+
+// node writes expr and all of its children and returns the id of expr
+func (t *tree) node(expr ast.Expression) string {
+	id := fmt.Sprintf("n%d", t.next)
+	t.next++
+
+	label, children := describe(expr)
+	fmt.Fprintf(t.w, "  %s [label=%s];\n", id, strconv.Quote(label))
+
+	for _, c := range children {
+		cid := t.node(c.expr)
+		if c.label == "" {
+			fmt.Fprintf(t.w, "  %s -> %s;\n", id, cid)
+		} else {
+			fmt.Fprintf(t.w, "  %s -> %s [label=%s];\n", id, cid, strconv.Quote(c.label))
+		}
+	}
+
+	return id
+}
+
+// describe returns the label of a node and its children in source order
+func describe(expr ast.Expression) (string, []child) {
+	switch e := expr.(type) {
+	case *ast.Integer:
+		return e.BigValue().String(), nil
+
+	case *ast.Boolean:
+		return strconv.FormatBool(e.Value), nil
+
+	case *ast.String:
+		return strconv.Quote(e.Value), nil
+
+	case *ast.Ident:
+		return e.Name, nil
+
+	case *ast.Program:
+		children := make([]child, len(e.Functions))
+		for i, f := range e.Functions {
+			children[i] = child{expr: f}
+		}
+		return "program", children
+
+	case *ast.Function:
+		label := "let " + e.Name.Name
+		for _, p := range e.Params {
+			label += " " + p.Name
+		}
+		if len(e.Clauses) != 0 {
+			children := make([]child, len(e.Clauses))
+			for i, c := range e.Clauses {
+				children[i] = child{label: strconv.Itoa(i + 1), expr: c}
+			}
+			return label, children
+		}
+		return label, []child{{expr: e.Body}}
+
+	case *ast.Clause:
+		label := "clause"
+		for _, p := range e.Patterns {
+			pattern, _ := describe(p)
+			label += " " + pattern
+		}
+		return label, []child{{expr: e.Body}}
+
+	case *ast.FunctionCall:
+		return "call " + e.Name, args(e.Params)
+
+	case *ast.CallExpression:
+		return "call", append([]child{{label: "fn", expr: e.Function}}, args(e.Params)...)
+
+	case *ast.Lambda:
+		label := "fn"
+		for _, p := range e.Params {
+			label += " " + p.Name
+		}
+		return label, []child{{expr: e.Body}}
+
+	case *ast.ArrayLiteral:
+		return "[]", args(e.Elements)
+
+	case *ast.ArrayExpression:
+		return "array", []child{{label: "len", expr: e.Length}, {label: "fn", expr: e.Function}}
+
+	case *ast.IndexExpression:
+		return "[i]", []child{{expr: e.Array}, {label: "index", expr: e.Index}}
+
+	case *ast.LengthExpression:
+		return "len", []child{{expr: e.Array}}
+
+	case *ast.IfExpression:
+		return "if", []child{
+			{label: "cond", expr: e.Condition},
+			{label: "then", expr: e.Consequence},
+			{label: "else", expr: e.Alternative},
+		}
+
+	case *ast.CondExpression:
+		children := []child{}
+		for i, b := range e.Branches {
+			children = append(children,
+				child{label: fmt.Sprintf("cond %d", i+1), expr: b.Condition},
+				child{label: fmt.Sprintf("then %d", i+1), expr: b.Consequence})
+		}
+		return "if", append(children, child{label: "else", expr: e.Alternative})
+
+	case *ast.UnaryExpression:
+		return string(e.Operator), []child{{expr: e.Operand}}
+
+	case *ast.BinaryExpression:
+		return string(e.Operator), []child{{expr: e.Left}, {expr: e.Right}}
+
+	case *ast.Binding:
+		return e.Ident.Name + " =", []child{{expr: e.Expr}}
+
+	case *ast.LetExpression:
+		return "let", bindings(e.Bindings, e.Expr)
+
+	case *ast.LoopExpression:
+		return "loop", bindings(e.Bindings, e.Expr)
+
+	case *ast.PrintExpression:
+		return "print", args(e.Args)
+
+	case *ast.Sequence:
+		return ";", args(e.Exprs)
+
+	case *ast.Recur:
+		return "recur", args(e.Args)
+
+	case nil:
+		return "<nil>", nil
+
+	default:
+		return reflect.TypeOf(expr).String(), nil
+	}
+}
+
+func args(exprs []ast.Expression) []child {
+	children := make([]child, len(exprs))
+	for i, a := range exprs {
+		children[i] = child{label: strconv.Itoa(i + 1), expr: a}
+	}
+	return children
+}
+
+func bindings(binds []*ast.Binding, body ast.Expression) []child {
+	children := make([]child, 0, len(binds)+1)
+	for _, b := range binds {
+		children = append(children, child{expr: b})
+	}
+	return append(children, child{label: "in", expr: body})
+}
+
+// CallGraph writes a Graphviz digraph with an edge from every top-level function
+// to each function it calls. Functions that are part of a recursive cycle are
+// drawn with a double border and the edges of the cycle in red. Calls to functions
+// which are not defined in prog are drawn dashed.
+func CallGraph(w io.Writer, prog *ast.Program) {
+	g := callgraph.New(prog)
+	recursive := g.RecursiveEdges()
+
+	fmt.Fprintln(w, "digraph calls {")
+	fmt.Fprintln(w, "  node [shape=ellipse, fontname=\"monospace\"];")
+
+	for _, f := range prog.Functions {
+		attr := ""
+		if recursive[f.Name.Name] != nil {
+			attr = ", peripheries=2"
+		}
+		fmt.Fprintf(w, "  %s [label=%s%s];\n", strconv.Quote(f.Name.Name), strconv.Quote(f.Name.Name), attr)
+	}
+
+	undefined := map[string]bool{}
+	for _, f := range prog.Functions {
+		for _, callee := range g[f.Name.Name] {
+			if !g.IsDefined(callee) && !undefined[callee] {
+				undefined[callee] = true
+				fmt.Fprintf(w, "  %s [label=%s, style=dashed];\n", strconv.Quote(callee), strconv.Quote(callee))
+			}
+
+			attr := ""
+			if recursive[f.Name.Name][callee] {
+				attr = " [color=red]"
+			}
+			fmt.Fprintf(w, "  %s -> %s%s;\n", strconv.Quote(f.Name.Name), strconv.Quote(callee), attr)
+		}
+	}
+
+	fmt.Fprintln(w, "}")
+}
@@ -0,0 +1,101 @@
+package dot
+
+import (
+	"bytes"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/simplang/callgraph"
+	"github.com/simplang/lexer"
+	"github.com/simplang/parser"
+)
+
+const input = `let even n = if n == 0 then 1 else odd (n + -1) end end
+let odd n = if n == 0 then 0 else even (n + -1) end end
+let fact n = if n == 0 then 1 else n * fact (n + -1) end end
+let main n = even (fact (n)) + show (n) end`
+
+func TestCallGraph(t *testing.T) {
+	p := parser.New(lexer.New(input))
+	prog := p.ParseProgram()
+	if len(p.Errors()) != 0 {
+		t.Fatalf("parser errors: %v", p.Errors())
+	}
+
+	expected := callgraph.Graph{
+		"even": {"odd"},
+		"odd":  {"even"},
+		"fact": {"fact"},
+		"main": {"even", "fact", "show"},
+	}
+
+	if calls := callgraph.New(prog); !reflect.DeepEqual(calls, expected) {
+		t.Fatalf("calls wrong. expected=%v, got=%v", expected, calls)
+	}
+
+	var buf bytes.Buffer
+	CallGraph(&buf, prog)
+	out := buf.String()
+
+	for _, line := range []string{
+		`"even" -> "odd" [color=red];`,
+		`"odd" -> "even" [color=red];`,
+		`"fact" -> "fact" [color=red];`,
+		`"main" -> "even";`,
+		`"fact" [label="fact", peripheries=2];`,
+		`"main" [label="main"];`,
+		`"show" [label="show", style=dashed];`,
+	} {
+		if !strings.Contains(out, line) {
+			t.Fatalf("call graph is missing %q. got=\n%s", line, out)
+		}
+	}
+}
+
+func TestTree(t *testing.T) {
+	p := parser.New(lexer.New("let main x = x + 1 end"))
+	prog := p.ParseProgram()
+
+	var buf bytes.Buffer
+	Tree(&buf, prog.Functions[0])
+
+	expected := `digraph ast {
+  node [shape=box, fontname="monospace"];
+  n0 [label="let main x"];
+  n1 [label="+"];
+  n2 [label="x"];
+  n1 -> n2;
+  n3 [label="1"];
+  n1 -> n3;
+  n0 -> n1;
+}
+`
+	if buf.String() != expected {
+		t.Fatalf("tree wrong. expected=\n%s\ngot=\n%s", expected, buf.String())
+	}
+}
+
+func TestCallGraphScopes(t *testing.T) {
+	p := parser.New(lexer.New(`let g x = x end
+let h x = x end
+let k = 3 end
+let apply g x = g (x) + k end
+let main x = let f y = h (y) and k = 4 in apply (fn z -> f (z) + k end) (g) end end`))
+	prog := p.ParseProgram()
+	if len(p.Errors()) != 0 {
+		t.Fatalf("parser errors: %v", p.Errors())
+	}
+
+	expected := callgraph.Graph{
+		"g":     {},
+		"h":     {},
+		"k":     {},
+		"apply": {"k"},
+		"main":  {"h", "apply", "g"},
+	}
+
+	if calls := callgraph.New(prog); !reflect.DeepEqual(calls, expected) {
+		t.Fatalf("calls wrong. expected=%v, got=%v", expected, calls)
+	}
+}
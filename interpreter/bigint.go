@@ -0,0 +1,133 @@
+package interpreter
+
+import (
+	"fmt"
+	"math"
+	"math/big"
+
+	"github.com/simplang/ast"
+	"github.com/simplang/token"
+)
+
+// bigValue returns the integer n. Integers are stored in the i field of a value
+// whenever they fit into 64 bits, only larger ones use the n field, so every
+// integer has a single representation. Values are never modified, so they may
+// share n.
+func bigValue(n *big.Int) value {
+	if n.IsInt64() {
+		return intValue(n.Int64())
+	}
+	return value{kind: intKind, n: n}
+}
+
+// asBig returns the integer v holds as a big.Int, an error is raised at t for any other value
+func asBig(v value, t *token.Token) *big.Int {
+	if v.kind != intKind {
+		throwError(fmt.Sprintf("expected an integer, got %s", v.kind.article()), t)
+	}
+	if v.n != nil {
+		return v.n
+	}
+	return big.NewInt(v.i)
+}
+
+// bigUnop computes "op x" for - and ~
+func bigUnop(op token.TokenType, x *big.Int) value {
+	if op == token.MINUS {
+		return bigValue(new(big.Int).Neg(x))
+	}
+	return bigValue(new(big.Int).Not(x))
+}
+
+// bigBinop computes "l op r" for the operators on integers. The results are the
+// same as the ones of the int64 operators unless those overflow, apart from >>>,
+// which has no meaning without a width.
+func bigBinop(op token.TokenType, l *big.Int, r *big.Int, t *token.Token) value {
+	res := new(big.Int)
+
+	switch op {
+	case token.LESS:
+		return boolValue(l.Cmp(r) < 0)
+
+	case token.GREATER:
+		return boolValue(l.Cmp(r) > 0)
+
+	case token.LESS_EQUAL:
+		return boolValue(l.Cmp(r) <= 0)
+
+	case token.GREATER_EQUAL:
+		return boolValue(l.Cmp(r) >= 0)
+
+	case token.PLUS:
+		res.Add(l, r)
+
+	case token.MINUS:
+		res.Sub(l, r)
+
+	case token.TIMES:
+		res.Mul(l, r)
+
+	case token.SLASH:
+		if r.Sign() == 0 {
+			throwError("division by zero", t)
+		}
+		res.Quo(l, r)
+
+	case token.PERCENT:
+		if r.Sign() == 0 {
+			throwError("modulo by zero", t)
+		}
+		res.Rem(l, r)
+
+	case token.BIT_AND:
+		res.And(l, r)
+
+	case token.BIT_OR:
+		res.Or(l, r)
+
+	case token.BIT_XOR:
+		res.Xor(l, r)
+
+	case token.SHIFT_LEFT:
+		res.Lsh(l, bigShiftCount(r, t))
+
+	case token.SHIFT_RIGHT:
+		res.Rsh(l, bigShiftCount(r, t))
+
+	case token.SHIFT_RIGHT_LOGICAL:
+		throwError("the logical shift >>> is not defined for big integers", t)
+
+	default:
+		throwError(fmt.Sprintf("invalid binary operator %s", op), t)
+	}
+
+	return bigValue(res)
+}
+
+// bigShiftCount returns r as a shift count, counts beyond 32 bits are refused
+// since shifting to the left by them would take all memory
+func bigShiftCount(r *big.Int, t *token.Token) uint {
+	if r.Sign() < 0 {
+		throwError(fmt.Sprintf("negative shift count %s", r), t)
+	}
+	if !r.IsUint64() || r.Uint64() > math.MaxUint32 {
+		throwError(fmt.Sprintf("shift count %s too large", r), t)
+	}
+	return uint(r.Uint64())
+}
+
+// intLiteral returns the value of the integer literal i
+func intLiteral(i *ast.Integer) value {
+	if i.Big != nil {
+		return bigValue(i.Big)
+	}
+	return intValue(i.Value)
+}
+
+// sameInt reports whether the integers l and r are equal
+func sameInt(l value, r value) bool {
+	if l.n != nil || r.n != nil {
+		return l.n != nil && r.n != nil && l.n.Cmp(r.n) == 0
+	}
+	return l.i == r.i
+}
@@ -0,0 +1,252 @@
+package interpreter
+
+import (
+	"fmt"
+	"math"
+	"math/big"
+	"math/bits"
+	"sort"
+
+	"github.com/simplang/arith"
+	"github.com/simplang/token"
+)
+
+// Builtin is a function implemented in Go. It takes Arity integers and returns an
+// integer, an error stops the program like any other runtime error. There is no
+// compiler for the vm, so builtins can only be called by interpreted programs.
+type Builtin struct {
+	Name  string
+	Arity int
+	Fn    func(args []int64) (int64, error)
+
+	// eval computes a builtin of this package in the arithmetic of the interpreter
+	// calling it, Fn is used by other interpreters
+	eval func(ev *evaluation, args []value, t *token.Token) (value, error)
+}
+
+// registered builtins by name, a call of one of these names goes to the builtin
+// unless a variable of the same name is in scope
+var builtins = map[string]*Builtin{}
+
+func init() {
+	RegisterBuiltin("isqrt", 1, isqrt)
+	RegisterBuiltin("popcount", 1, popcount)
+	RegisterBuiltin("pow", 2, pow)
+
+	builtins["isqrt"].eval = evalIsqrt
+	builtins["popcount"].eval = evalPopcount
+	builtins["pow"].eval = evalPow
+}
+
+// NewBuiltin returns the builtin name calling fn with arity arguments. It fails if
+// name is not an identifier or arity is less than 1, since a call needs at least
+// one argument.
+func NewBuiltin(name string, arity int, fn func(args []int64) (int64, error)) (*Builtin, error) {
+	if !isIdentifier(name) {
+		return nil, fmt.Errorf("builtin name %q is not an identifier", name)
+	}
+	if arity < 1 {
+		return nil, fmt.Errorf("builtin '%s' needs at least one parameter", name)
+	}
+
+	return &Builtin{Name: name, Arity: arity, Fn: fn}, nil
+}
+
+// RegisterBuiltin makes fn callable as name with arity arguments in every program.
+// It panics if NewBuiltin fails or name is already registered.
+func RegisterBuiltin(name string, arity int, fn func(args []int64) (int64, error)) {
+	b, err := NewBuiltin(name, arity, fn)
+	if err != nil {
+		panic(err.Error())
+	}
+	if _, ok := builtins[name]; ok {
+		panic(fmt.Sprintf("builtin '%s' is already registered", name))
+	}
+
+	builtins[name] = b
+}
+
+// LookupBuiltin returns the builtin registered as name, or nil
+func LookupBuiltin(name string) *Builtin {
+	return builtins[name]
+}
+
+// Builtins returns the names of all registered builtins in sorted order
+func Builtins() []string {
+	names := make([]string, 0, len(builtins))
+	for name := range builtins {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func isIdentifier(name string) bool {
+	if name == "" || token.LookupIdent(name) != token.IDENT || (name[0] >= '0' && name[0] <= '9') {
+		return false
+	}
+
+	for i := 0; i < len(name); i++ {
+		c := name[i]
+		if !(c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' || c == '_') {
+			return false
+		}
+	}
+	return true
+}
+
+// callBuiltin calls b with the arguments, which have to be integers
+func (ev *evaluation) callBuiltin(b *Builtin, args []value, t *token.Token) value {
+	if len(args) != b.Arity {
+		throwError(fmt.Sprintf("builtin '%s' takes %d argument(s), got %d", b.Name, b.Arity, len(args)), t)
+	}
+
+	if b.eval != nil {
+		res, err := b.eval(ev, args, t)
+		builtinError(b, err, t)
+		return res
+	}
+
+	ints := make([]int64, len(args))
+	for i, a := range args {
+		ints[i] = asInt(a, t)
+	}
+
+	res, err := b.Fn(ints)
+	builtinError(b, err, t)
+
+	return ev.convert(intValue(res), t)
+}
+
+// builtinError stops the evaluation at t if the call of b failed with err
+func builtinError(b *Builtin, err error, t *token.Token) {
+	if err != nil {
+		throwError(fmt.Sprintf("%s: %s", b.Name, err.Error()), t)
+	}
+}
+
+// isqrt x is the largest integer whose square is at most x
+func isqrt(args []int64) (int64, error) {
+	x := args[0]
+	if x < 0 {
+		return 0, fmt.Errorf("square root of negative number %d", x)
+	}
+	if x < 2 {
+		return x, nil
+	}
+
+	// Newton's method from above, starting at a power of two larger than the root
+	r := int64(1) << uint((bits.Len64(uint64(x))+1)/2)
+	for {
+		next := (r + x/r) / 2
+		if next >= r {
+			return r, nil
+		}
+		r = next
+	}
+}
+
+// popcount x is the number of one bits of x
+func popcount(args []int64) (int64, error) {
+	return int64(bits.OnesCount64(uint64(args[0]))), nil
+}
+
+// pow x n is x to the power of n with wrapping multiplication, 1 for every n <= 0
+func pow(args []int64) (int64, error) {
+	r, b, n := int64(1), args[0], args[1]
+	for ; n > 0; n /= 2 {
+		if n%2 == 1 {
+			r *= b
+		}
+		b *= b
+	}
+	return r, nil
+}
+
+// evalIsqrt is isqrt for integers of any size and type
+func evalIsqrt(ev *evaluation, args []value, t *token.Token) (value, error) {
+	x := args[0].n
+	if x == nil {
+		x = ev.intType.ToBig(asInt(args[0], t))
+	}
+
+	if x.Sign() < 0 {
+		return value{}, fmt.Errorf("square root of negative number %s", x)
+	}
+	return bigValue(new(big.Int).Sqrt(x)), nil
+}
+
+// evalPopcount is popcount for integers of any size and type, it counts the bits
+// of the type. Beyond 64 bits integers have to be positive, a negative one has
+// infinitely many one bits.
+func evalPopcount(ev *evaluation, args []value, t *token.Token) (value, error) {
+	if args[0].n == nil {
+		return intValue(int64(ev.intType.OnesCount(asInt(args[0], t)))), nil
+	}
+
+	if args[0].n.Sign() < 0 {
+		return value{}, fmt.Errorf("negative number %s has infinitely many one bits", args[0].n)
+	}
+
+	count := 0
+	for _, w := range args[0].n.Bits() {
+		count += bits.OnesCount(uint(w))
+	}
+	return intValue(int64(count)), nil
+}
+
+// evalPow is pow with the multiplication and integer type of the interpreter. Only
+// the squares needed are computed, so it overflows exactly when the result
+// doesn't fit.
+func evalPow(ev *evaluation, args []value, t *token.Token) (value, error) {
+	if ev.arithmetic == arith.Big && (args[0].n != nil || args[1].n != nil) {
+		return bigPow(asBig(args[0], t), asBig(args[1], t))
+	}
+
+	x, n := asInt(args[0], t), asInt(args[1], t)
+
+	// exponents of unsigned types are never negative
+	e := uint64(n)
+	if n < 0 && !ev.intType.Unsigned {
+		e = 0
+	}
+
+	r, b, ok := int64(1), x, true
+	for ; e > 0 && ok; e /= 2 {
+		if e%2 == 1 {
+			r, ok = ev.intType.Mul(ev.arithmetic, r, b)
+		}
+		if e > 1 && ok {
+			b, ok = ev.intType.Mul(ev.arithmetic, b, b)
+		}
+	}
+
+	if !ok && ev.arithmetic == arith.Big {
+		return bigPow(big.NewInt(x), big.NewInt(n))
+	}
+	if !ok {
+		overflow(fmt.Sprintf("pow (%s) (%s)", ev.intType.Format(x), ev.intType.Format(n)), t)
+	}
+	return intValue(r), nil
+}
+
+// bigPow is pow for integers of any size. Results beyond 2^32 bits are refused,
+// like the counts of big shifts, since they would take all memory.
+func bigPow(x *big.Int, n *big.Int) (value, error) {
+	if n.Sign() <= 0 {
+		return intValue(1), nil
+	}
+
+	// 0, 1 and -1 stay small for any n
+	if x.CmpAbs(big.NewInt(1)) <= 0 {
+		if x.Sign() < 0 && n.Bit(0) == 0 {
+			return intValue(1), nil
+		}
+		return bigValue(x), nil
+	}
+
+	if size := uint64(x.BitLen() - 1); !n.IsUint64() || n.Uint64() > math.MaxUint32/size {
+		return value{}, fmt.Errorf("exponent %s too large", n)
+	}
+	return bigValue(new(big.Int).Exp(x, n, nil)), nil
+}
@@ -0,0 +1,229 @@
+package interpreter
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"math/big"
+	"testing"
+
+	"github.com/simplang/arith"
+	"github.com/simplang/lexer"
+	"github.com/simplang/parser"
+)
+
+func run(t *testing.T, input string, params ...int64) int64 {
+	p := parser.New(lexer.New(input))
+	prog := p.ParseProgram()
+	if len(p.Errors()) != 0 {
+		t.Fatalf("parser errors: %v", p.Errors())
+	}
+	return Interprete(prog, params)
+}
+
+func TestBuiltins(t *testing.T) {
+	tests := []struct {
+		main     string
+		expected int64
+	}{
+		{"isqrt (0) + isqrt (1) + isqrt (15) + isqrt (16)", 8},
+		{"isqrt (9223372036854775807)", 3037000499},
+		{"popcount (255) + popcount (-1)", 72},
+		{"pow (3) (4) + pow (2) (0) + pow (2) (-1)", 83},
+		{"pow (2) (63)", -9223372036854775808},
+		{"let pow x = x + 1 in pow (1) end", 2},
+	}
+
+	for i, tt := range tests {
+		if got := run(t, "let main = "+tt.main+" end"); got != tt.expected {
+			t.Fatalf("tests[%d] - %s wrong. expected=%d, got=%d", i, tt.main, tt.expected, got)
+		}
+	}
+}
+
+func TestBuiltinArithmetic(t *testing.T) {
+	p := parser.New(lexer.New("let main x n = pow (x) (n) end"))
+	in, err := New(p.ParseProgram())
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	tests := []struct {
+		args      []int64
+		wrapped   int64
+		saturated int64
+		checked   string
+	}{
+		{[]int64{2, 64}, 0, math.MaxInt64, "integer overflow in pow (2) (64) (line 1.15)"},
+		{[]int64{-2, 65}, 0, math.MinInt64, "integer overflow in pow (-2) (65) (line 1.15)"},
+		{[]int64{3, 41}, -420491770248316829, math.MaxInt64, "integer overflow in pow (3) (41) (line 1.15)"},
+		{[]int64{-2, 63}, math.MinInt64, math.MinInt64, ""},
+		{[]int64{2, 62}, 1 << 62, 1 << 62, ""},
+		{[]int64{-1, math.MaxInt64}, -1, -1, ""},
+		{[]int64{5, -1}, 1, 1, ""},
+	}
+
+	for i, tt := range tests {
+		for _, mode := range []arith.Mode{arith.Wrapping, arith.Saturating, arith.Checked} {
+			in.SetArithmetic(mode)
+			res, err := in.Call("main", tt.args)
+
+			if mode == arith.Checked && tt.checked != "" {
+				if !errors.Is(err, arith.ErrOverflow) || err.Error() != tt.checked {
+					t.Fatalf("tests[%d] - expected the overflow %q, got %v", i, tt.checked, err)
+				}
+				continue
+			}
+
+			expected := tt.wrapped
+			if mode == arith.Saturating {
+				expected = tt.saturated
+			}
+			if err != nil || res != expected {
+				t.Fatalf("tests[%d] - pow%v in %s mode wrong. expected=%d, got=%d, err=%v", i, tt.args, mode, expected, res, err)
+			}
+		}
+	}
+}
+
+func TestBigBuiltins(t *testing.T) {
+	p := parser.NewWithOptions(lexer.New(`let sqr x = isqrt (x * x) end
+let ones x = popcount (x) end
+let power x n = pow (x) (n) end`), parser.Options{BigIntegers: true})
+	in, err := New(p.ParseProgram())
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	in.SetArithmetic(arith.Big)
+
+	tests := []struct {
+		name     string
+		args     []string
+		expected string
+		err      string
+	}{
+		{"power", []string{"2", "100"}, "1267650600228229401496703205376", ""},
+		{"power", []string{"-3", "41"}, "-36472996377170786403", ""},
+		{"power", []string{"18446744073709551616", "2"}, "340282366920938463463374607431768211456", ""},
+		{"power", []string{"-1", "18446744073709551617"}, "-1", ""},
+		{"power", []string{"2", "62"}, "4611686018427387904", ""},
+		{"power", []string{"2", "4294967296"}, "", "pow: exponent 4294967296 too large (line 3.17)"},
+		{"sqr", []string{"12345678901234567890"}, "12345678901234567890", ""},
+		{"sqr", []string{"-3037000500"}, "3037000500", ""},
+		{"ones", []string{"1267650600228229401496703205375"}, "100", ""},
+		{"ones", []string{"-1"}, "64", ""},
+		{"ones", []string{"-18446744073709551616"}, "", "popcount: negative number -18446744073709551616 has infinitely many one bits (line 2.14)"},
+	}
+
+	for i, tt := range tests {
+		args := make([]*big.Int, len(tt.args))
+		for j, a := range tt.args {
+			args[j], _ = new(big.Int).SetString(a, 10)
+		}
+
+		res, err := in.CallBig(context.Background(), tt.name, args)
+		if tt.err != "" {
+			if err == nil || err.Error() != tt.err {
+				t.Fatalf("tests[%d] - expected the error %q, got %v", i, tt.err, err)
+			}
+			continue
+		}
+		if err != nil || res.String() != tt.expected {
+			t.Fatalf("tests[%d] - %s%v wrong. expected=%s, got=%v, err=%v", i, tt.name, tt.args, tt.expected, res, err)
+		}
+	}
+}
+
+func TestBuiltinIntTypes(t *testing.T) {
+	uint8, int8, uint64 := arith.Int{Bits: 8, Unsigned: true}, arith.Int{Bits: 8}, arith.Int{Bits: 64, Unsigned: true}
+
+	tests := []struct {
+		typ      arith.Int
+		mode     arith.Mode
+		main     string
+		args     []int64
+		expected int64
+		err      string
+	}{
+		{uint64, arith.Wrapping, "isqrt (x)", []int64{-1, 0}, 4294967295, ""},
+		{uint64, arith.Wrapping, "isqrt (x)", []int64{-2, 0}, 4294967295, ""},
+		{uint8, arith.Wrapping, "isqrt (x)", []int64{255, 0}, 15, ""},
+		{int8, arith.Wrapping, "isqrt (x)", []int64{-1, 0}, 0, "isqrt: square root of negative number -1 (line 1.15)"},
+		{uint64, arith.Wrapping, "popcount (x)", []int64{-1, 0}, 64, ""},
+		{uint8, arith.Wrapping, "popcount (x)", []int64{255, 0}, 8, ""},
+		{int8, arith.Wrapping, "popcount (x)", []int64{-1, 0}, 8, ""},
+		{uint8, arith.Wrapping, "pow (x) (y)", []int64{2, 8}, 0, ""},
+		{uint8, arith.Saturating, "pow (x) (y)", []int64{2, 8}, 255, ""},
+		{uint8, arith.Checked, "pow (x) (y)", []int64{2, 8}, 0, "integer overflow in pow (2) (8) (line 1.15)"},
+		{uint8, arith.Checked, "pow (x) (y)", []int64{3, 5}, 243, ""},
+		{uint64, arith.Checked, "pow (x) (y)", []int64{2, 63}, math.MinInt64, ""},
+		{uint64, arith.Checked, "pow (x) (y)", []int64{1, -1}, 1, ""},
+		{uint64, arith.Wrapping, "pow (x) (y)", []int64{3, -1}, -6148914691236517205, ""},
+		{int8, arith.Saturating, "pow (x) (y)", []int64{-2, 9}, -128, ""},
+	}
+
+	for i, tt := range tests {
+		p := parser.NewWithOptions(lexer.New("let main x y = "+tt.main+" end"), parser.Options{Int: tt.typ})
+		in, err := New(p.ParseProgram())
+		if err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+		in.SetIntType(tt.typ)
+		in.SetArithmetic(tt.mode)
+
+		res, err := in.Call("main", tt.args)
+		if tt.err != "" {
+			if err == nil || err.Error() != tt.err {
+				t.Fatalf("tests[%d] - expected the error %q, got %v", i, tt.err, err)
+			}
+			continue
+		}
+		if err != nil || res != tt.expected {
+			t.Fatalf("tests[%d] - %s with %v as %s in %s mode wrong. expected=%d, got=%d, err=%v", i, tt.main, tt.args, tt.typ, tt.mode, tt.expected, res, err)
+		}
+	}
+}
+
+func TestBuiltinHidesFunction(t *testing.T) {
+	if got := run(t, "let popcount x = 0 end let main x = popcount (x) end", 7); got != 3 {
+		t.Fatalf("expected the builtin to be called. expected=3, got=%d", got)
+	}
+}
+
+func TestRegisterBuiltin(t *testing.T) {
+	RegisterBuiltin("clamp_test", 3, func(args []int64) (int64, error) {
+		if args[1] > args[2] {
+			return 0, fmt.Errorf("empty range")
+		}
+		if args[0] < args[1] {
+			return args[1], nil
+		}
+		if args[0] > args[2] {
+			return args[2], nil
+		}
+		return args[0], nil
+	})
+
+	if got := run(t, "let main x = clamp_test (x) (0) (10) * 100 + clamp_test (-x) (0) (10) end", 42); got != 1000 {
+		t.Fatalf("result wrong. expected=1000, got=%d", got)
+	}
+
+	if b := LookupBuiltin("clamp_test"); b == nil || b.Arity != 3 {
+		t.Fatalf("builtin not registered correctly, got %v", b)
+	}
+
+	for _, tt := range []struct {
+		name  string
+		arity int
+	}{{"clamp_test", 1}, {"let", 1}, {"1x", 1}, {"a-b", 1}, {"noargs", 0}} {
+		func() {
+			defer func() {
+				if recover() == nil {
+					t.Fatalf("expected RegisterBuiltin(%q, %d) to panic", tt.name, tt.arity)
+				}
+			}()
+			RegisterBuiltin(tt.name, tt.arity, nil)
+		}()
+	}
+}
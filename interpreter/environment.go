@@ -3,31 +3,44 @@ package interpreter
 import (
 	"fmt"
 
-	"os"
-
 	"github.com/simplang/token"
 )
 
 type element struct {
 	name  string
-	value int64
+	value value
 }
 
 type environment struct {
 	elements []*element
 }
 
-func throwError(msg string, t *token.Token) {
-	if t != nil {
-		fmt.Printf("Error: %s (line %d.%d)\n", msg, t.Line, t.Column)
-	} else {
-		fmt.Printf("Error: %s\n", msg)
+// Error is a runtime error, Token is where it was raised or nil if it has no position.
+// Err is the cause if the run was stopped by a limit, its context or an overflow.
+type Error struct {
+	Msg   string
+	Token *token.Token
+	Err   error
+}
+
+func (e *Error) Error() string {
+	if e.Token != nil {
+		return fmt.Sprintf("%s (line %d.%d)", e.Msg, e.Token.Line, e.Token.Column)
 	}
-	os.Exit(1)
+	return e.Msg
+}
+
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+// throwError stops the evaluation, Call returns the error
+func throwError(msg string, t *token.Token) {
+	panic(&Error{Msg: msg, Token: t})
 }
 
 func (e *element) String() string {
-	return fmt.Sprintf("{%s = %d}", e.name, e.value)
+	return fmt.Sprintf("{%s = %s}", e.name, e.value)
 }
 
 func (e *environment) String() string {
@@ -55,7 +68,7 @@ func (e *environment) indexOfElement(name string) int {
 	return -1
 }
 
-func (e *environment) setValue(name string, val int64) bool {
+func (e *environment) setValue(name string, val value) bool {
 	if i := e.indexOfElement(name); i >= 0 {
 		e.elements[i].value = val
 		return true
@@ -64,13 +77,24 @@ func (e *environment) setValue(name string, val int64) bool {
 	return false
 }
 
-func (e *environment) getValue(t *token.Token) int64 {
+func (e *environment) getValue(t *token.Token) value {
 	if i := e.indexOfElement(t.Literal); i >= 0 {
 		return e.elements[i].value
 	}
 
 	throwError(fmt.Sprintf("Variable '%s' not defined (line %d.%d)", t.Literal, t.Line, t.Column), t)
-	return 0
+	return value{}
+}
+
+// snapshot returns a copy of e which is not affected by later changes to e
+func (e *environment) snapshot() *environment {
+	elements := make([]*element, len(e.elements))
+	for i, el := range e.elements {
+		c := *el
+		elements[i] = &c
+	}
+
+	return &environment{elements: elements}
 }
 
 func (e *environment) appendElement(el *element) {
@@ -1,17 +1,191 @@
 package interpreter
 
 import (
+	"context"
 	"fmt"
-
+	"io"
+	"math/big"
+	"os"
 	"reflect"
+	"strings"
 
+	"github.com/simplang/arith"
 	"github.com/simplang/ast"
+	"github.com/simplang/limits"
 	"github.com/simplang/token"
 )
 
-// public map of functions with their names
-var functions map[string]*ast.Function
+// Interpreter runs the functions of a program. Calls don't modify it, so once it is
+// set up it can be used by several goroutines at the same time.
+type Interpreter struct {
+	functions  map[string]*ast.Function
+	builtins   map[string]*Builtin
+	output     io.Writer
+	limits     limits.Limits
+	arithmetic arith.Mode
+	intType    arith.Int
+}
+
+// evaluation is the state of a single call, constants are evaluated again by
+// every call
+type evaluation struct {
+	*Interpreter
+
+	// a step is a function call or an iteration of a loop
+	counter *limits.Counter
+
+	// values of the functions without parameters which have been evaluated already
+	constants map[string]value
+
+	// constants whose value is being computed, to detect constants defined by themselves
+	evaluating map[string]bool
+}
+
+// New returns an interpreter for the functions of prog with the registered builtins
+func New(prog *ast.Program) (*Interpreter, error) {
+	in := &Interpreter{functions: map[string]*ast.Function{}, builtins: map[string]*Builtin{}, output: os.Stdout}
+
+	for _, f := range prog.Functions {
+		if _, ok := in.functions[f.Name.Name]; ok {
+			return nil, &Error{Msg: fmt.Sprintf("function '%s' is already defined", f.Name.Name), Token: &f.Token}
+		}
+		in.functions[f.Name.Name] = f
+	}
+
+	for name, b := range builtins {
+		in.builtins[name] = b
+	}
+
+	return in, nil
+}
+
+// SetOutput sets the writer print writes to, it is os.Stdout by default
+func (in *Interpreter) SetOutput(w io.Writer) {
+	in.output = w
+}
+
+// AddBuiltin makes b callable by the programs run by in, it replaces a registered
+// builtin of the same name. It must not be called while a call is running.
+func (in *Interpreter) AddBuiltin(b *Builtin) {
+	in.builtins[b.Name] = b
+}
+
+// SetLimits sets the limits of every call, a call of a builtin is not counted
+func (in *Interpreter) SetLimits(l limits.Limits) {
+	in.limits = l
+}
+
+// SetArithmetic sets what +, -, *, / and negation do on overflow, they wrap by
+// default. In arith.Big mode integers of any size are computed exactly, only >>>
+// fails.
+func (in *Interpreter) SetArithmetic(m arith.Mode) {
+	in.arithmetic = m
+}
+
+// SetIntType sets the type of all integers, they are int64 by default. Integers of
+// an unsigned 64-bit type are passed to and returned by Call as their bits. The
+// type can't be combined with arith.Big mode.
+func (in *Interpreter) SetIntType(t arith.Int) {
+	in.intType = t
+}
+
+// Builtin returns the builtin called name in the programs run by in, or nil
+func (in *Interpreter) Builtin(name string) *Builtin {
+	return in.builtins[name]
+}
+
+// Call calls the top-level function name with the arguments and returns its
+// result, which has to be an integer. Runtime errors are returned as *Error.
+func (in *Interpreter) Call(name string, args []int64) (int64, error) {
+	return in.CallContext(context.Background(), name, args)
+}
+
+// CallContext is like Call, but the call is stopped once ctx is done
+func (in *Interpreter) CallContext(ctx context.Context, name string, args []int64) (int64, error) {
+	params := make([]value, len(args))
+	for i, a := range args {
+		if in.intType.Wrap(a) != a {
+			return 0, &Error{Msg: fmt.Sprintf("argument %d of '%s' does not fit into %s", i+1, name, in.intType)}
+		}
+		params[i] = intValue(a)
+	}
+
+	v, err := in.call(ctx, name, params)
+	if err != nil {
+		return 0, err
+	}
+	if v.n != nil {
+		return 0, &Error{Msg: fmt.Sprintf("the result %s of %s does not fit into 64 bits", v.n, name)}
+	}
+	return v.i, nil
+}
+
+// CallBig is like CallContext for arguments and results of any size. Integers
+// beyond 64 bits are only computed in arith.Big mode, otherwise they fail like
+// the results of Call.
+func (in *Interpreter) CallBig(ctx context.Context, name string, args []*big.Int) (*big.Int, error) {
+	params := make([]value, len(args))
+	for i, a := range args {
+		if in.intType.Is64() {
+			params[i] = bigValue(a)
+			continue
+		}
+
+		x, ok := in.intType.FromBig(a)
+		if !ok {
+			return nil, &Error{Msg: fmt.Sprintf("argument %d of '%s' does not fit into %s", i+1, name, in.intType)}
+		}
+		params[i] = intValue(x)
+	}
+
+	v, err := in.call(ctx, name, params)
+	if err != nil {
+		return nil, err
+	}
+	if v.n != nil {
+		return v.n, nil
+	}
+	return in.intType.ToBig(v.i), nil
+}
+
+// call calls the top-level function name with the parameters, its result has to be an integer
+func (in *Interpreter) call(ctx context.Context, name string, params []value) (res value, err error) {
+	f, ok := in.functions[name]
+	if !ok {
+		return value{}, &Error{Msg: fmt.Sprintf("function '%s' could not be found", name)}
+	}
+
+	if len(params) != len(f.Params) {
+		return value{}, &Error{Msg: fmt.Sprintf("function '%s' takes %d argument(s), got %d", name, len(f.Params), len(params)), Token: &f.Token}
+	}
+
+	if in.arithmetic == arith.Big && !in.intType.Is64() {
+		return value{}, &Error{Msg: fmt.Sprintf("big arithmetic can't be used with %s", in.intType)}
+	}
+
+	defer func() {
+		if r := recover(); r != nil {
+			e, ok := r.(*Error)
+			if !ok {
+				panic(r)
+			}
+			err = e
+		}
+	}()
+
+	ev := &evaluation{Interpreter: in, counter: limits.NewCounter(ctx, in.limits), constants: map[string]value{}, evaluating: map[string]bool{}}
+	ev.check(ev.counter.Start(), &f.Token)
+
+	v := ev.interpreteFunction(f, params)
+	if v.kind != intKind {
+		throwError(fmt.Sprintf("%s has to return an integer, got %s", name, v.kind.article()), &f.Token)
+	}
 
+	return v, nil
+}
+
+// Interprete runs the main function of the program with the parameters. A runtime
+// error is printed and ends the process.
 func Interprete(expression ast.Expression, params []int64) int64 {
 	prog, ok := expression.(*ast.Program)
 
@@ -20,85 +194,223 @@ func Interprete(expression ast.Expression, params []int64) int64 {
 		return 0
 	}
 
-	functions = map[string]*ast.Function{}
-
-	for _, f := range prog.Functions {
-		if _, ok := functions[f.Name.Name]; ok {
-			throwError(fmt.Sprintf("Error: Function '%s' is already defined", f.Name.Name), &f.Token)
+	in, err := New(prog)
+	if err == nil {
+		var res int64
+		if res, err = in.Call("main", params); err == nil {
+			return res
 		}
-		functions[f.Name.Name] = f
 	}
 
-	val, ok := functions["main"]
+	fmt.Printf("Error: %s\n", err)
+	os.Exit(1)
+	return 0
+}
 
-	if !ok {
-		throwError("Error: Function 'main' could not be found", nil)
+// check stops the evaluation at t if a limit has been exceeded
+func (ev *evaluation) check(err error, t *token.Token) {
+	if err != nil {
+		panic(&Error{Msg: err.Error(), Token: t, Err: err})
 	}
+}
 
-	return interpreteFunction(val, params)
+func (ev *evaluation) interpreteFunction(f *ast.Function, params []value) value {
+	return ev.callFunction(f, &environment{}, params)
 }
 
-func interpreteFunction(f *ast.Function, params []int64) int64 {
+// callFunction evaluates the body of f with the parameters bound in scope
+func (ev *evaluation) callFunction(f *ast.Function, scope *environment, params []value) value {
 	l := len(params)
 	if l != len(f.Params) {
-		throwError(fmt.Sprintf("Error: Function called with wrong amount of arguments. expected=%d, got=%d", len(f.Params), l), &f.Token)
+		throwError(fmt.Sprintf("function called with wrong amount of arguments. expected=%d, got=%d", len(f.Params), l), &f.Token)
 	}
 
-	env := &environment{elements: make([]*element, l)}
+	body := f.Body
+	env := &environment{elements: make([]*element, len(scope.elements), len(scope.elements)+l)}
+	copy(env.elements, scope.elements)
 	for i, val := range f.Params {
-		env.elements[i] = &element{name: val.Name, value: params[i]}
+		env.appendElement(&element{name: val.Name, value: params[i]})
 	}
 
-	res, isRec := interpreteExpr(f.Body, env)
+	if len(f.Clauses) != 0 {
+		body, env = matchClause(f, params)
+	}
+
+	ev.check(ev.counter.Step(), &f.Token)
+	ev.check(ev.counter.Enter(), &f.Token)
+	res, isRec := ev.interpreteExpr(body, env)
+	ev.counter.Leave()
 
 	if isRec != nil {
-		throwError(fmt.Sprintf("Error: recur appeared after function %s ended. Is a loop missing?", f.Name.Name), &f.Token)
+		throwError(fmt.Sprintf("recur appeared after function %s ended. Is a loop missing?", f.Name.Name), &f.Token)
 	}
 
 	return res
 }
 
+// interpreteGlobal returns the value of the top-level function named by ident.
+// A function without parameters is a constant, it is evaluated once and later
+// uses get the same value.
+func (ev *evaluation) interpreteGlobal(ident *ast.Ident, env *environment) value {
+	if val, ok := ev.constants[ident.Name]; ok {
+		return val
+	}
+
+	f, ok := ev.functions[ident.Name]
+	if !ok {
+		// reports the undefined variable
+		return env.getValue(&ident.Token)
+	}
+
+	if len(f.Params) != 0 {
+		return funcValue(&closure{f: f, env: &environment{}})
+	}
+
+	if ev.evaluating[f.Name.Name] {
+		throwError(fmt.Sprintf("constant '%s' depends on its own value", f.Name.Name), &ident.Token)
+	}
+
+	ev.evaluating[f.Name.Name] = true
+	val := ev.interpreteFunction(f, nil)
+	delete(ev.evaluating, f.Name.Name)
+
+	ev.constants[f.Name.Name] = val
+	return val
+}
+
+// matchClause returns the body of the first clause of f whose integer patterns equal
+// the arguments and the environment binding the remaining arguments to their names
+func matchClause(f *ast.Function, params []value) (ast.Expression, *environment) {
+	for _, c := range f.Clauses {
+		env := &environment{elements: []*element{}}
+		matches := true
+
+		for i, pattern := range c.Patterns {
+			switch p := pattern.(type) {
+			case *ast.Integer:
+				matches = matches && params[i].kind == intKind && sameInt(intLiteral(p), params[i])
+			case *ast.Ident:
+				env.appendElement(&element{name: p.Name, value: params[i]})
+			}
+		}
+
+		if matches {
+			return c.Body, env
+		}
+	}
+
+	throwError(fmt.Sprintf("no clause of function '%s' matches the arguments %v", f.Name.Name, params), &f.Token)
+	return nil, nil
+}
+
 // functions return tuples
-// int64 is the result we get
-// []int64 are the argument values when recur is called
-func interpreteExpr(expr ast.Expression, env *environment) (int64, []int64) {
-	var res int64
-	var rec []int64
+// value is the result we get
+// []value are the argument values when recur is called
+func (ev *evaluation) interpreteExpr(expr ast.Expression, env *environment) (value, []value) {
+	var res value
+	var rec []value
 
 	switch t := expr.(type) {
 	case *ast.Integer:
-		res = (*ast.Integer)(t).Value
+		res = intLiteral(t)
+		if res.n == nil && ev.intType.Wrap(res.i) != res.i {
+			throwError(fmt.Sprintf("integer literal %s does not fit into %s", t.Token.Literal, ev.intType), &t.Token)
+		}
+
+	case *ast.Boolean:
+		res = boolValue(t.Value)
+
+	case *ast.String:
+		res = stringValue(t.Value)
 
 	case *ast.IfExpression:
-		res, rec = interpreteIf(t, env)
+		res, rec = ev.interpreteIf(t, env)
+
+	case *ast.CondExpression:
+		res, rec = ev.interpreteCond(t, env)
 
 	case *ast.UnaryExpression:
-		res, rec = interpreteUnop(t, env)
+		res, rec = ev.interpreteUnop(t, env)
 
 	case *ast.BinaryExpression:
-		res, rec = interpreteBinop(t, env)
+		res, rec = ev.interpreteBinop(t, env)
 
 	case *ast.LetExpression:
-		res, rec = interpreteLet(t, env)
+		res, rec = ev.interpreteLet(t, env)
 
 	case *ast.Ident:
-		res = env.getValue(&(*ast.Ident)(t).Token)
+		if env.indexOfElement(t.Name) >= 0 {
+			res = env.getValue(&(*ast.Ident)(t).Token)
+			break
+		}
+
+		res = ev.interpreteGlobal(t, env)
 
 	case *ast.FunctionCall:
 		fc := (*ast.FunctionCall)(t)
-		f, ok := functions[fc.Name]
+
+		// variables hide the global functions
+		if i := env.indexOfElement(fc.Name); i >= 0 {
+			v := env.elements[i].value
+			if v.kind != funcKind {
+				throwError(fmt.Sprintf("'%s' is %s, not a function", fc.Name, v.kind.article()), &fc.Token)
+			}
+
+			res = ev.callFunction(v.f.f, v.f.env, ev.evalArgs(fc.Params, &fc.Token, env))
+			break
+		}
+
+		// builtins hide the top-level functions
+		if b, ok := ev.builtins[fc.Name]; ok {
+			res = ev.callBuiltin(b, ev.evalArgs(fc.Params, &fc.Token, env), &fc.Token)
+			break
+		}
+
+		f, ok := ev.functions[fc.Name]
 		if !ok {
 			throwError(fmt.Sprintf("function '%s' is not defined", fc.Name), &fc.Token)
 			break
 		}
 
-		res = interpreteFunction(f, evalArgs(fc.Params, &fc.Token, env))
+		res = ev.interpreteFunction(f, ev.evalArgs(fc.Params, &fc.Token, env))
+
+	case *ast.CallExpression:
+		f, isRec := ev.interpreteExpr(t.Function, env)
+		if isRec != nil {
+			throwError("recur may not be called. Is a loop missing?", &t.Token)
+		}
+
+		c := asFunc(f, &t.Token)
+		res = ev.callFunction(c.f, c.env, ev.evalArgs(t.Params, &t.Token, env))
+
+	case *ast.Lambda:
+		name := &ast.Ident{Token: t.Token, Name: "fn"}
+		res = funcValue(&closure{f: &ast.Function{Token: t.Token, Name: name, Params: t.Params, Body: t.Body}, env: env.snapshot()})
+
+	case *ast.ArrayLiteral:
+		res = arrayValue(ev.evalOperands(t.Elements, &t.Token, env))
+
+	case *ast.ArrayExpression:
+		res = ev.interpreteArray(t, env)
+
+	case *ast.IndexExpression:
+		res = ev.interpreteIndex(t, env)
+
+	case *ast.LengthExpression:
+		ops := ev.evalOperands([]ast.Expression{t.Array}, &t.Token, env)
+		res = ev.convert(intValue(int64(len(asArray(ops[0], &t.Token)))), &t.Token)
 
 	case *ast.LoopExpression:
-		res, rec = interpreteLoop(t, env)
+		res, rec = ev.interpreteLoop(t, env)
+
+	case *ast.PrintExpression:
+		res = ev.interpretePrint(t, env)
+
+	case *ast.Sequence:
+		res, rec = ev.interpreteSequence(t, env)
 
 	case *ast.Recur:
-		rec = evalArgs(t.Args, &t.Token, env)
+		rec = ev.evalArgs(t.Args, &t.Token, env)
 
 	default:
 		throwError(fmt.Sprintf("type is not valid in expression. got=%s", reflect.TypeOf(expr)), nil)
@@ -107,12 +419,12 @@ func interpreteExpr(expr ast.Expression, env *environment) (int64, []int64) {
 	return res, rec
 }
 
-func evalArgs(expr []ast.Expression, t *token.Token, env *environment) []int64 {
-	res := make([]int64, len(expr))
-	var isRec []int64
+func (ev *evaluation) evalArgs(expr []ast.Expression, t *token.Token, env *environment) []value {
+	res := make([]value, len(expr))
+	var isRec []value
 
 	for i, val := range expr {
-		res[i], isRec = interpreteExpr(val, env)
+		res[i], isRec = ev.interpreteExpr(val, env)
 
 		if isRec != nil {
 			throwError("recur may not appear inside an argument. Is a loop missing?", t)
@@ -122,24 +434,112 @@ func evalArgs(expr []ast.Expression, t *token.Token, env *environment) []int64 {
 	return res
 }
 
-func interpreteIf(expr *ast.IfExpression, env *environment) (int64, []int64) {
-	res, isRec := interpreteExpr(expr.Condition, env)
+// evalOperands evaluates the operands of an array operation
+func (ev *evaluation) evalOperands(expr []ast.Expression, t *token.Token, env *environment) []value {
+	res := make([]value, len(expr))
+	var isRec []value
+
+	for i, val := range expr {
+		res[i], isRec = ev.interpreteExpr(val, env)
+
+		if isRec != nil {
+			throwError("recur may not be used inside an array operation. Is a loop missing?", t)
+		}
+	}
+
+	return res
+}
+
+// interpreteArray calls the function of expr with every index to get the elements
+func (ev *evaluation) interpreteArray(expr *ast.ArrayExpression, env *environment) value {
+	ops := ev.evalOperands([]ast.Expression{expr.Length, expr.Function}, &expr.Token, env)
+	n := asInt(ops[0], &expr.Token)
+	f := asFunc(ops[1], &expr.Token)
+
+	if n < 0 {
+		throwError(fmt.Sprintf("negative array length %d", n), &expr.Token)
+	}
+
+	elements := make([]value, n)
+	for i := range elements {
+		elements[i] = ev.callFunction(f.f, f.env, []value{intValue(int64(i))})
+	}
+
+	return arrayValue(elements)
+}
+
+func (ev *evaluation) interpreteIndex(expr *ast.IndexExpression, env *environment) value {
+	ops := ev.evalOperands([]ast.Expression{expr.Array, expr.Index}, &expr.Token, env)
+	a := asArray(ops[0], &expr.Token)
+	i := asInt(ops[1], &expr.Token)
+
+	if i < 0 || i >= int64(len(a)) {
+		throwError(fmt.Sprintf("index %d out of range for an array of length %d", i, len(a)), &expr.Token)
+	}
+
+	return a[i]
+}
+
+// interpretePrint writes the arguments to the output and returns the last one
+func (ev *evaluation) interpretePrint(expr *ast.PrintExpression, env *environment) value {
+	args := ev.evalArgs(expr.Args, &expr.Token, env)
+
+	s := make([]string, len(args))
+	for i, a := range args {
+		s[i] = a.format(ev.intType)
+	}
+	fmt.Fprintln(ev.output, strings.Join(s, " "))
+
+	return args[len(args)-1]
+}
+
+// interpreteSequence evaluates all expressions, only the last one may recur
+func (ev *evaluation) interpreteSequence(expr *ast.Sequence, env *environment) (value, []value) {
+	last := len(expr.Exprs) - 1
+
+	for _, e := range expr.Exprs[:last] {
+		if _, isRec := ev.interpreteExpr(e, env); isRec != nil {
+			throwError("recur may only be the last expression of a sequence", &expr.Token)
+		}
+	}
+
+	return ev.interpreteExpr(expr.Exprs[last], env)
+}
+
+func (ev *evaluation) interpreteIf(expr *ast.IfExpression, env *environment) (value, []value) {
+	res, isRec := ev.interpreteExpr(expr.Condition, env)
 
 	if isRec != nil {
 		throwError("recur statement may not appear as a condition in an if statement. Is a loop missing?", &expr.Token)
 	}
 
-	if res != 0 {
-		res, isRec = interpreteExpr(expr.Consequence, env)
+	if asBool(res, &expr.Token) {
+		res, isRec = ev.interpreteExpr(expr.Consequence, env)
 		return res, isRec
 	}
 
-	res, isRec = interpreteExpr(expr.Alternative, env)
+	res, isRec = ev.interpreteExpr(expr.Alternative, env)
 	return res, isRec
 }
 
-func interpreteUnop(expr *ast.UnaryExpression, env *environment) (int64, []int64) {
-	res, isRec := interpreteExpr(expr.Operand, env)
+func (ev *evaluation) interpreteCond(expr *ast.CondExpression, env *environment) (value, []value) {
+	for _, b := range expr.Branches {
+		res, isRec := ev.interpreteExpr(b.Condition, env)
+
+		if isRec != nil {
+			throwError("recur statement may not appear as a condition in an if statement. Is a loop missing?", &b.Token)
+		}
+
+		if asBool(res, &b.Token) {
+			return ev.interpreteExpr(b.Consequence, env)
+		}
+	}
+
+	return ev.interpreteExpr(expr.Alternative, env)
+}
+
+func (ev *evaluation) interpreteUnop(expr *ast.UnaryExpression, env *environment) (value, []value) {
+	v, isRec := ev.interpreteExpr(expr.Operand, env)
 
 	if isRec != nil {
 		throwError("recur may not be used in connection with a unary operator. Is a loop missing?", &expr.Token)
@@ -147,23 +547,39 @@ func interpreteUnop(expr *ast.UnaryExpression, env *environment) (int64, []int64
 
 	switch expr.Operator {
 	case token.NOT:
-		if res != 0 {
-			return 0, nil
-		}
-		return 1, nil
+		return boolValue(!asBool(v, &expr.Token)), nil
 
 	case token.MINUS:
-		return -res, nil
+		if ev.arithmetic == arith.Big && v.n != nil {
+			return bigUnop(expr.Operator, v.n), nil
+		}
+
+		x := asInt(v, &expr.Token)
+		res, ok := ev.intType.Neg(ev.arithmetic, x)
+		if ok {
+			return intValue(res), nil
+		}
+		if ev.arithmetic == arith.Big {
+			return bigUnop(expr.Operator, big.NewInt(x)), nil
+		}
+		overflow(fmt.Sprintf("-(%s)", ev.intType.Format(x)), &expr.Token)
+		return value{}, nil
+
+	case token.BIT_NOT:
+		if ev.arithmetic == arith.Big && v.n != nil {
+			return bigUnop(expr.Operator, v.n), nil
+		}
+		return intValue(ev.intType.Not(asInt(v, &expr.Token))), nil
 
 	default:
-		throwError(fmt.Sprintf("invalid unary operator. Expected ! or -, got %s instead", expr.Operator), &expr.Token)
-		return 0, nil
+		throwError(fmt.Sprintf("invalid unary operator. Expected !, - or ~, got %s instead", expr.Operator), &expr.Token)
+		return value{}, nil
 	}
 }
 
-func interpreteBinop(expr *ast.BinaryExpression, env *environment) (int64, []int64) {
-	l, isRecl := interpreteExpr(expr.Left, env)
-	r, isRecr := interpreteExpr(expr.Right, env)
+func (ev *evaluation) interpreteBinop(expr *ast.BinaryExpression, env *environment) (value, []value) {
+	lv, isRecl := ev.interpreteExpr(expr.Left, env)
+	rv, isRecr := ev.interpreteExpr(expr.Right, env)
 
 	if (isRecl != nil) || (isRecr != nil) {
 		throwError("recur may not be used with a binary operator. Is a loop missing?", &expr.Token)
@@ -171,53 +587,178 @@ func interpreteBinop(expr *ast.BinaryExpression, env *environment) (int64, []int
 
 	switch expr.Operator {
 	case token.LOG_AND:
-		if l == 0 {
-			return 0, nil
-		}
-		if r == 0 {
-			return 0, nil
-		}
-		return 1, nil
+		return boolValue(asBool(lv, &expr.Token) && asBool(rv, &expr.Token)), nil
 
 	case token.LOG_OR:
-		if l != 0 {
-			return 1, nil
-		}
-		if r != 0 {
-			return 1, nil
-		}
-		return 0, nil
+		return boolValue(asBool(lv, &expr.Token) || asBool(rv, &expr.Token)), nil
 
+	case token.EQUAL:
+		return boolValue(equal(lv, rv, &expr.Token)), nil
+
+	case token.NOT_EQUAL:
+		return boolValue(!equal(lv, rv, &expr.Token)), nil
+	}
+
+	// in big mode the int64 operators are used as long as they don't overflow,
+	// left shifts and >>> lose bits without overflowing
+	if ev.arithmetic == arith.Big && (lv.n != nil || rv.n != nil || expr.Operator == token.SHIFT_LEFT || expr.Operator == token.SHIFT_RIGHT_LOGICAL) {
+		return bigBinop(expr.Operator, asBig(lv, &expr.Token), asBig(rv, &expr.Token), &expr.Token), nil
+	}
+
+	l, r := asInt(lv, &expr.Token), asInt(rv, &expr.Token)
+
+	switch expr.Operator {
 	case token.LESS:
-		if l < r {
-			return 1, nil
+		return boolValue(ev.intType.Less(l, r)), nil
+
+	case token.GREATER:
+		return boolValue(ev.intType.Less(r, l)), nil
+
+	case token.LESS_EQUAL:
+		return boolValue(!ev.intType.Less(r, l)), nil
+
+	case token.GREATER_EQUAL:
+		return boolValue(!ev.intType.Less(l, r)), nil
+
+	case token.PLUS, token.MINUS, token.TIMES:
+		return ev.arithmeticOp(expr.Operator, l, r, &expr.Token), nil
+
+	// division truncates towards zero, the remainder has the sign of the dividend.
+	// The smallest value of a signed type divided by -1 overflows.
+	case token.SLASH:
+		if r == 0 {
+			throwError("division by zero", &expr.Token)
 		}
-		return 0, nil
+		return ev.arithmeticOp(expr.Operator, l, r, &expr.Token), nil
 
-	case token.EQUAL:
-		if l == r {
-			return 1, nil
+	case token.PERCENT:
+		if r == 0 {
+			throwError("modulo by zero", &expr.Token)
 		}
-		return 0, nil
+		return intValue(ev.intType.Rem(l, r)), nil
 
-	case token.PLUS:
-		return l + r, nil
+	case token.BIT_AND:
+		return intValue(l & r), nil
 
-	case token.TIMES:
-		return l * r, nil
+	case token.BIT_OR:
+		return intValue(l | r), nil
+
+	case token.BIT_XOR:
+		return intValue(l ^ r), nil
+
+	// shifting by the size of the type or more bits shifts out every bit
+	case token.SHIFT_LEFT:
+		return intValue(ev.intType.Shl(l, ev.shiftCount(r, &expr.Token))), nil
+
+	case token.SHIFT_RIGHT:
+		return intValue(ev.intType.Shr(l, ev.shiftCount(r, &expr.Token))), nil
+
+	case token.SHIFT_RIGHT_LOGICAL:
+		return intValue(ev.intType.Ushr(l, ev.shiftCount(r, &expr.Token))), nil
 
 	default:
-		throwError(fmt.Sprintf("invalid binary operator. Expected &&, ||, <, ==, + or -, got %s instead", expr.Operator), &expr.Token)
-		return 0, nil
+		throwError(fmt.Sprintf("invalid binary operator %s", expr.Operator), &expr.Token)
+		return value{}, nil
 	}
 }
 
-func interpreteLet(expr *ast.LetExpression, env *environment) (int64, []int64) {
+// arithmeticOp computes "l op r" for +, -, * and / in the arithmetic mode and
+// integer type of the interpreter
+func (ev *evaluation) arithmeticOp(op token.TokenType, l int64, r int64, t *token.Token) value {
 	var res int64
-	var isRec []int64
+	var ok bool
+
+	switch op {
+	case token.PLUS:
+		res, ok = ev.intType.Add(ev.arithmetic, l, r)
+	case token.MINUS:
+		res, ok = ev.intType.Sub(ev.arithmetic, l, r)
+	case token.TIMES:
+		res, ok = ev.intType.Mul(ev.arithmetic, l, r)
+	case token.SLASH:
+		res, ok = ev.intType.Div(ev.arithmetic, l, r)
+	}
+
+	if ok {
+		return intValue(res)
+	}
+	if ev.arithmetic == arith.Big {
+		return bigBinop(op, big.NewInt(l), big.NewInt(r), t)
+	}
+	overflow(fmt.Sprintf("%s %s %s", ev.intType.Format(l), op, ev.intType.Format(r)), t)
+	return value{}
+}
+
+// convert returns the integer v, which comes from Go, as a value of the integer
+// type. It has to fit unless it wraps around or saturates in the arithmetic mode.
+func (ev *evaluation) convert(v value, t *token.Token) value {
+	if ev.intType.Is64() {
+		return v
+	}
+
+	res, ok := ev.intType.Convert(ev.arithmetic, v.i)
+	if !ok {
+		overflow(fmt.Sprintf("converting %d to %s", v.i, ev.intType), t)
+	}
+	return intValue(res)
+}
+
+// overflow stops the evaluation at t with an overflow of the operation in checked mode
+func overflow(operation string, t *token.Token) {
+	panic(&Error{Msg: fmt.Sprintf("%s in %s", arith.ErrOverflow, operation), Token: t, Err: arith.ErrOverflow})
+}
+
+// equal compares two integers, booleans, strings or arrays of them, functions
+// can't be compared
+func equal(l value, r value, t *token.Token) bool {
+	if l.kind != r.kind {
+		throwError(fmt.Sprintf("cannot compare %s with %s", l.kind.article(), r.kind.article()), t)
+	}
+
+	switch l.kind {
+	case intKind:
+		return sameInt(l, r)
+	case boolKind:
+		return l.b == r.b
+	case stringKind:
+		return l.s == r.s
+	case arrayKind:
+		if len(l.a) != len(r.a) {
+			return false
+		}
+		for i := range l.a {
+			if !equal(l.a[i], r.a[i], t) {
+				return false
+			}
+		}
+		return true
+	}
+
+	throwError(fmt.Sprintf("cannot compare %s", l.kind.article()), t)
+	return false
+}
+
+func (ev *evaluation) shiftCount(r int64, t *token.Token) uint64 {
+	if r < 0 && !ev.intType.Unsigned {
+		throwError(fmt.Sprintf("negative shift count %d", r), t)
+	}
+	return uint64(r)
+}
+
+func (ev *evaluation) interpreteLet(expr *ast.LetExpression, env *environment) (value, []value) {
+	var res value
+	var isRec []value
 
 	for _, b := range expr.Bindings {
-		res, isRec = interpreteExpr(b.Expr, env)
+		if f, ok := b.Expr.(*ast.Function); ok {
+			// the closure sees itself, so local functions can be recursive
+			c := &closure{f: f}
+			env.appendElement(&element{name: b.Ident.Name, value: funcValue(c)})
+			c.env = env.snapshot()
+			continue
+		}
+
+		res, isRec = ev.interpreteExpr(b.Expr, env)
 
 		if isRec != nil {
 			throwError("recur may not appear as an argument. Is a loop missing?", &expr.Token)
@@ -225,18 +766,18 @@ func interpreteLet(expr *ast.LetExpression, env *environment) (int64, []int64) {
 		env.appendElement(&element{name: b.Ident.Name, value: res})
 	}
 
-	res, isRec = interpreteExpr(expr.Expr, env)
+	res, isRec = ev.interpreteExpr(expr.Expr, env)
 	env.removeElements(len(expr.Bindings))
 
 	return res, isRec
 }
 
-func interpreteLoop(expr *ast.LoopExpression, env *environment) (int64, []int64) {
-	var res int64
-	var isRec []int64
+func (ev *evaluation) interpreteLoop(expr *ast.LoopExpression, env *environment) (value, []value) {
+	var res value
+	var isRec []value
 
 	for _, b := range expr.Bindings {
-		res, isRec = interpreteExpr(b.Expr, env)
+		res, isRec = ev.interpreteExpr(b.Expr, env)
 
 		if isRec != nil {
 			throwError("recur may not appear as an argument. Is a loop missing?", &expr.Token)
@@ -245,7 +786,9 @@ func interpreteLoop(expr *ast.LoopExpression, env *environment) (int64, []int64)
 		env.appendElement(&element{name: b.Ident.Name, value: res})
 	}
 
-	for res, isRec = interpreteExpr(expr.Expr, env); isRec != nil; res, isRec = interpreteExpr(expr.Expr, env) {
+	for res, isRec = ev.interpreteExpr(expr.Expr, env); isRec != nil; res, isRec = ev.interpreteExpr(expr.Expr, env) {
+		ev.check(ev.counter.Step(), &expr.Token)
+
 		if len(isRec) != len(expr.Bindings) {
 			throwError(fmt.Sprintf("recur has wrong amount of arguments. expected=%d, got=%d", len(expr.Bindings), len(isRec)), &expr.Token)
 		}
@@ -0,0 +1,462 @@
+package interpreter
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io/ioutil"
+	"math"
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/simplang/arith"
+	"github.com/simplang/lexer"
+	"github.com/simplang/limits"
+	"github.com/simplang/parser"
+)
+
+func TestPrintOutput(t *testing.T) {
+	p := parser.New(lexer.New(`let main n =
+  loop i = 0 and s = 0 in
+    if i < n then
+      print ("i =") (i) ([i, i * i]) (["a", "b\n"]) (i == 1);
+      recur (i + 1) (s + print (i))
+    else
+      s
+    end
+  end
+end`))
+	prog := p.ParseProgram()
+	if len(p.Errors()) != 0 {
+		t.Fatalf("parser errors: %v", p.Errors())
+	}
+
+	in, err := New(prog)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	var buf bytes.Buffer
+	in.SetOutput(&buf)
+
+	if res, err := in.Call("main", []int64{2}); err != nil || res != 1 {
+		t.Fatalf("result wrong. expected=1, got=%d, err=%v", res, err)
+	}
+
+	expected := `i = 0 [0, 0] ["a", "b\n"] false
+0
+i = 1 [1, 1] ["a", "b\n"] true
+1
+`
+	if buf.String() != expected {
+		t.Fatalf("output wrong. expected=\n%s\ngot=\n%s", expected, buf.String())
+	}
+}
+
+func TestLimits(t *testing.T) {
+	p := parser.New(lexer.New(`let spin x = loop i = x in recur (i) end end
+let down n = if n == 0 then 0 else 1 + down (n - 1) end end
+let count n = loop i = 0 in if i < n then recur (i + 1) else i end end end`))
+	prog := p.ParseProgram()
+	if len(p.Errors()) != 0 {
+		t.Fatalf("parser errors: %v", p.Errors())
+	}
+
+	in, err := New(prog)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	in.SetLimits(limits.Limits{MaxSteps: 1000, MaxDepth: 100})
+
+	canceled, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	tests := []struct {
+		ctx      context.Context
+		name     string
+		arg      int64
+		expected error
+	}{
+		{context.Background(), "spin", 1, limits.ErrSteps},
+		{context.Background(), "down", 100, limits.ErrDepth},
+		{context.Background(), "count", 1000, limits.ErrSteps},
+		{context.Background(), "down", 99, nil},
+		{context.Background(), "count", 998, nil},
+		{canceled, "count", 1, context.Canceled},
+	}
+
+	for i, tt := range tests {
+		_, err := in.CallContext(tt.ctx, tt.name, []int64{tt.arg})
+		if !errors.Is(err, tt.expected) {
+			t.Fatalf("tests[%d] - %s (%d) error wrong. expected=%v, got=%v", i, tt.name, tt.arg, tt.expected, err)
+		}
+	}
+
+	// without a step limit only the context stops the loop
+	in.SetLimits(limits.Limits{})
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+
+	_, err = in.CallContext(ctx, "spin", []int64{1})
+	var runtimeErr *Error
+	if !errors.As(err, &runtimeErr) || !errors.Is(err, context.DeadlineExceeded) || runtimeErr.Token == nil {
+		t.Fatalf("expected the deadline to stop the loop at its position, got %v", err)
+	}
+}
+
+func TestArithmetic(t *testing.T) {
+	p := parser.New(lexer.New(`let add x y = x + y end
+let sub x y = x - y end
+let mul x y = x * y end
+let div x y = x / y end
+let neg x = -x end`))
+	prog := p.ParseProgram()
+	if len(p.Errors()) != 0 {
+		t.Fatalf("parser errors: %v", p.Errors())
+	}
+
+	in, err := New(prog)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	const max, min = math.MaxInt64, math.MinInt64
+
+	tests := []struct {
+		name      string
+		args      []int64
+		wrapped   int64
+		saturated int64
+		checked   string
+	}{
+		{"add", []int64{max, 1}, min, max, "integer overflow in 9223372036854775807 + 1 (line 1.16)"},
+		{"sub", []int64{min, 1}, max, min, "integer overflow in -9223372036854775808 - 1 (line 2.17)"},
+		{"mul", []int64{max, -2}, 2, min, "integer overflow in 9223372036854775807 * -2 (line 3.17)"},
+		{"div", []int64{min, -1}, min, max, "integer overflow in -9223372036854775808 / -1 (line 4.17)"},
+		{"neg", []int64{min}, min, max, "integer overflow in -(-9223372036854775808) (line 5.13)"},
+		{"mul", []int64{3, -4}, -12, -12, ""},
+	}
+
+	for i, tt := range tests {
+		for _, mode := range []arith.Mode{arith.Wrapping, arith.Saturating, arith.Checked} {
+			in.SetArithmetic(mode)
+			res, err := in.Call(tt.name, tt.args)
+
+			if mode == arith.Checked && tt.checked != "" {
+				if !errors.Is(err, arith.ErrOverflow) || err.Error() != tt.checked {
+					t.Fatalf("tests[%d] - expected the overflow %q, got %v", i, tt.checked, err)
+				}
+				continue
+			}
+
+			expected := tt.wrapped
+			if mode == arith.Saturating {
+				expected = tt.saturated
+			}
+			if err != nil || res != expected {
+				t.Fatalf("tests[%d] - %s%v in %s mode wrong. expected=%d, got=%d, err=%v", i, tt.name, tt.args, mode, expected, res, err)
+			}
+		}
+	}
+}
+
+func TestBigIntegers(t *testing.T) {
+	p := parser.NewWithOptions(lexer.New(`let fact n = if n < 2 then 1 else n * fact (n - 1) end end
+let div x y = x / y end
+let rem x y = x % y end
+let shl x y = x << y end
+let shr x y = x >> y end
+let bits x y = (x & y) ^ (x | ~y) end
+let less x y = if x < y then 1 else 0 end end
+let neg x = -x end
+let same x y = if [x] == [y] then 1 else 0 end end
+let pick -18446744073709551616 = 1 end
+let pick x = 0 end
+let lshr x = x >>> 1 end`), parser.Options{BigIntegers: true})
+	prog := p.ParseProgram()
+	if len(p.Errors()) != 0 {
+		t.Fatalf("parser errors: %v", p.Errors())
+	}
+
+	in, err := New(prog)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	in.SetArithmetic(arith.Big)
+
+	tests := []struct {
+		name     string
+		args     []string
+		expected string
+	}{
+		{"fact", []string{"30"}, "265252859812191058636308480000000"},
+		{"fact", []string{"5"}, "120"},
+		{"div", []string{"-265252859812191058636308480000001", "1000000000000000000000"}, "-265252859812"},
+		{"div", []string{"-9223372036854775808", "-1"}, "9223372036854775808"},
+		{"rem", []string{"-265252859812191058636308480000001", "1000000000000000000000"}, "-191058636308480000001"},
+		{"shl", []string{"3", "100"}, "3802951800684688204490109616128"},
+		{"shr", []string{"-3802951800684688204490109616129", "100"}, "-4"},
+		{"bits", []string{"18446744073709551615", "-18446744073709551616"}, "18446744073709551615"},
+		{"less", []string{"9223372036854775807", "9223372036854775808"}, "1"},
+		{"neg", []string{"-9223372036854775808"}, "9223372036854775808"},
+		{"same", []string{"18446744073709551616", "18446744073709551616"}, "1"},
+		{"same", []string{"18446744073709551616", "0"}, "0"},
+		{"pick", []string{"-18446744073709551616"}, "1"},
+		{"pick", []string{"18446744073709551616"}, "0"},
+	}
+
+	for i, tt := range tests {
+		args := make([]*big.Int, len(tt.args))
+		for j, a := range tt.args {
+			args[j], _ = new(big.Int).SetString(a, 10)
+		}
+
+		res, err := in.CallBig(context.Background(), tt.name, args)
+		if err != nil || res.String() != tt.expected {
+			t.Fatalf("tests[%d] - %s%v wrong. expected=%s, got=%v, err=%v", i, tt.name, tt.args, tt.expected, res, err)
+		}
+	}
+
+	if _, err := in.Call("fact", []int64{21}); err == nil || err.Error() != "the result 51090942171709440000 of fact does not fit into 64 bits" {
+		t.Fatalf("expected the result of fact (21) not to fit, got %v", err)
+	}
+
+	if _, err := in.Call("lshr", []int64{4}); err == nil || err.Error() != "the logical shift >>> is not defined for big integers (line 12.16)" {
+		t.Fatalf("expected >>> to fail, got %v", err)
+	}
+
+	// without big mode the integers beyond 64 bits can't be used
+	in.SetArithmetic(arith.Checked)
+	if _, err := in.CallBig(context.Background(), "neg", []*big.Int{new(big.Int).Lsh(big.NewInt(1), 64)}); err == nil || err.Error() != "integer 18446744073709551616 does not fit into 64 bits (line 8.13)" {
+		t.Fatalf("expected the argument not to fit, got %v", err)
+	}
+}
+
+func TestIntTypes(t *testing.T) {
+	src := `let add x y = x + y end
+let sub x y = x - y end
+let mul x y = x * y end
+let div x y = x / y end
+let neg x = -x end
+let less x y = if x < y then 1 else 0 end end
+let shr x y = x >> y end
+let not x = ~x end
+let show x = print (x); x end`
+
+	int8, uint8, uint64 := arith.Int{Bits: 8}, arith.Int{Bits: 8, Unsigned: true}, arith.Int{Bits: 64, Unsigned: true}
+
+	tests := []struct {
+		typ       arith.Int
+		name      string
+		args      []int64
+		wrapped   int64
+		saturated int64
+		checked   string
+	}{
+		{int8, "add", []int64{127, 1}, -128, 127, "integer overflow in 127 + 1 (line 1.16)"},
+		{int8, "sub", []int64{-128, 1}, 127, -128, "integer overflow in -128 - 1 (line 2.17)"},
+		{int8, "mul", []int64{16, -9}, 112, -128, "integer overflow in 16 * -9 (line 3.17)"},
+		{int8, "div", []int64{-128, -1}, -128, 127, "integer overflow in -128 / -1 (line 4.17)"},
+		{int8, "neg", []int64{-128}, -128, 127, "integer overflow in -(-128) (line 5.13)"},
+		{int8, "not", []int64{0}, -1, -1, ""},
+		{uint8, "add", []int64{255, 1}, 0, 255, "integer overflow in 255 + 1 (line 1.16)"},
+		{uint8, "sub", []int64{0, 1}, 255, 0, "integer overflow in 0 - 1 (line 2.17)"},
+		{uint8, "mul", []int64{16, 16}, 0, 255, "integer overflow in 16 * 16 (line 3.17)"},
+		{uint8, "neg", []int64{1}, 255, 0, "integer overflow in -(1) (line 5.13)"},
+		{uint8, "not", []int64{0}, 255, 255, ""},
+		{uint8, "shr", []int64{128, 7}, 1, 1, ""},
+		{uint64, "less", []int64{1, -1}, 1, 1, ""},
+		{uint64, "add", []int64{-1, 1}, 0, -1, "integer overflow in 18446744073709551615 + 1 (line 1.16)"},
+		{uint64, "div", []int64{-2, 2}, math.MaxInt64, math.MaxInt64, ""},
+		{uint64, "shr", []int64{-1, 63}, 1, 1, ""},
+	}
+
+	for i, tt := range tests {
+		p := parser.NewWithOptions(lexer.New(src), parser.Options{Int: tt.typ})
+		prog := p.ParseProgram()
+		if len(p.Errors()) != 0 {
+			t.Fatalf("parser errors: %v", p.Errors())
+		}
+
+		in, err := New(prog)
+		if err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+		in.SetIntType(tt.typ)
+
+		for _, mode := range []arith.Mode{arith.Wrapping, arith.Saturating, arith.Checked} {
+			in.SetArithmetic(mode)
+			res, err := in.Call(tt.name, tt.args)
+
+			if mode == arith.Checked && tt.checked != "" {
+				if !errors.Is(err, arith.ErrOverflow) || err.Error() != tt.checked {
+					t.Fatalf("tests[%d] - expected the overflow %q, got %v", i, tt.checked, err)
+				}
+				continue
+			}
+
+			expected := tt.wrapped
+			if mode == arith.Saturating {
+				expected = tt.saturated
+			}
+			if err != nil || res != expected {
+				t.Fatalf("tests[%d] - %s %s%v in %s mode wrong. expected=%d, got=%d, err=%v", i, tt.typ, tt.name, tt.args, mode, expected, res, err)
+			}
+		}
+	}
+
+	p := parser.NewWithOptions(lexer.New(src), parser.Options{Int: uint64})
+	in, err := New(p.ParseProgram())
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	in.SetIntType(uint64)
+	var out bytes.Buffer
+	in.SetOutput(&out)
+
+	max := new(big.Int).SetUint64(math.MaxUint64)
+	if res, err := in.CallBig(context.Background(), "show", []*big.Int{max}); err != nil || res.Cmp(max) != 0 || out.String() != "18446744073709551615\n" {
+		t.Fatalf("expected the largest uint64 to be printed and returned, got %v, %q, err=%v", res, out.String(), err)
+	}
+	if _, err := in.CallBig(context.Background(), "show", []*big.Int{big.NewInt(-1)}); err == nil || err.Error() != "argument 1 of 'show' does not fit into uint64" {
+		t.Fatalf("expected -1 not to fit, got %v", err)
+	}
+
+	in.SetIntType(int8)
+	if _, err := in.Call("show", []int64{128}); err == nil || err.Error() != "argument 1 of 'show' does not fit into int8" {
+		t.Fatalf("expected 128 not to fit, got %v", err)
+	}
+}
+
+// TestTestfile pins the results of testfile.txt. The operand of a unary minus
+// doesn't take the binary operators after it since there is a binary minus, so
+// n + -i + -1 in ispalindrome is n - i - 1 and no longer n - (i + -1).
+func TestTestfile(t *testing.T) {
+	file, err := ioutil.ReadFile("../testfile.txt")
+	if err != nil {
+		t.Fatalf("could not read testfile: %s", err)
+	}
+
+	p := parser.New(lexer.New(string(file)))
+	prog := p.ParseProgram()
+	if len(p.Errors()) != 0 {
+		t.Fatalf("parser errors: %v", p.Errors())
+	}
+
+	tests := []struct {
+		arg      int64
+		expected int64
+	}{
+		{10, 0},
+		{50, 2112},
+		{300, 84348},
+	}
+
+	for i, tt := range tests {
+		if res := Interprete(prog, []int64{tt.arg}); res != tt.expected {
+			t.Fatalf("tests[%d] - main (%d) wrong. expected=%d, got=%d", i, tt.arg, tt.expected, res)
+		}
+	}
+}
+
+func TestPrograms(t *testing.T) {
+	tests := []struct {
+		src      string
+		args     []int64
+		expected int64
+	}{
+		{"let main x = if x == 0 then 0 elif x < 0 then -1 elif x < 10 then 1 else 2 end end", []int64{-5}, -1},
+		{"let main x = if x == 0 then 0 elif x < 0 then -1 elif x < 10 then 1 else 2 end end", []int64{5}, 1},
+		{"let main x = if x == 0 then 0 elif x < 0 then -1 elif x < 10 then 1 else 2 end end", []int64{50}, 2},
+		{`let search pred max = loop i = max in if i < 0 then -1 elif pred (i) then i else recur (i - 1) end end end
+let main x = search (fn i -> i % x == 0 end) (100) end`, []int64{7}, 98},
+		{`let adder x = fn y -> x + y end end
+let twice f = fn x -> f (f (x)) end end
+let main x = let f = twice (adder (x)) in f (1) end end`, []int64{20}, 41},
+		{`let digits x = array (5) (fn i -> let p = loop p = 1 and n = i in if n == 0 then p else recur (p * 10) (n - 1) end end in x / p % 10 end end) end
+let main x = let d = digits (x) in if d[0] == d[4] && d[1] == d[3] then len (d) else 0 end end end`, []int64{12321}, 5},
+		{"let main x = [[x, 2], [3]][0][1] + len ([[x], []]) end", []int64{1}, 4},
+	}
+
+	for i, tt := range tests {
+		p := parser.New(lexer.New(tt.src))
+		prog := p.ParseProgram()
+		if len(p.Errors()) != 0 {
+			t.Fatalf("tests[%d] - parser errors: %v", i, p.Errors())
+		}
+
+		in, err := New(prog)
+		if err != nil {
+			t.Fatalf("tests[%d] - unexpected error: %s", i, err)
+		}
+
+		if res, err := in.Call("main", tt.args); err != nil || res != tt.expected {
+			t.Fatalf("tests[%d] - main%v wrong. expected=%d, got=%d, err=%v", i, tt.args, tt.expected, res, err)
+		}
+	}
+}
+
+func TestOperators(t *testing.T) {
+	tests := []struct {
+		input    string
+		args     []int64
+		expected int64
+		err      string
+	}{
+		{"x / y", []int64{7, -2}, -3, ""},
+		{"x % y", []int64{-7, 2}, -1, ""},
+		{"x % y", []int64{7, -2}, 1, ""},
+		{"x / y", []int64{math.MinInt64, -1}, math.MinInt64, ""},
+		{"x % y", []int64{math.MinInt64, -1}, 0, ""},
+		{"x / y", []int64{1, 0}, 0, "division by zero (line 1.17)"},
+		{"x % y", []int64{1, 0}, 0, "modulo by zero (line 1.17)"},
+		{"x - y", []int64{3, 5}, -2, ""},
+		{"x - y - 1", []int64{10, 3}, 6, ""},
+		{"if x > y then 1 else 0 end", []int64{2, 1}, 1, ""},
+		{"if x > y then 1 else 0 end", []int64{1, 1}, 0, ""},
+		{"if x <= y then 1 else 0 end", []int64{1, 1}, 1, ""},
+		{"if x <= y then 1 else 0 end", []int64{2, 1}, 0, ""},
+		{"if x >= y then 1 else 0 end", []int64{1, 1}, 1, ""},
+		{"if x >= y then 1 else 0 end", []int64{-1, 1}, 0, ""},
+		{"if x != y then 1 else 0 end", []int64{1, 2}, 1, ""},
+		{"if x != y then 1 else 0 end", []int64{2, 2}, 0, ""},
+		{"(x & y) | (x ^ y)", []int64{12, 10}, 14, ""},
+		{"~x", []int64{0, 0}, -1, ""},
+		{"x << y", []int64{3, 4}, 48, ""},
+		{"x >> y", []int64{-16, 2}, -4, ""},
+		{"x >>> y", []int64{-1, 60}, 15, ""},
+		{"x << y", []int64{1, 64}, 0, ""},
+		{"x >> y", []int64{-8, 70}, -1, ""},
+		{"x >>> y", []int64{-1, 64}, 0, ""},
+		{"x << y", []int64{1, -1}, 0, "negative shift count -1 (line 1.17)"},
+		{"x >>> y", []int64{1, -1}, 0, "negative shift count -1 (line 1.17)"},
+		{`if "ab" == "ab" then 1 else 0 end`, []int64{0, 0}, 1, ""},
+		{`if "ab" == "a" then 1 else 0 end`, []int64{0, 0}, 0, ""},
+		{`if "ab" != "b" then 1 else 0 end`, []int64{0, 0}, 1, ""},
+		{`if ["a", "b"] != ["a", "b"] then 1 else 0 end`, []int64{0, 0}, 0, ""},
+	}
+
+	for i, tt := range tests {
+		p := parser.New(lexer.New("let main x y = " + tt.input + " end"))
+		prog := p.ParseProgram()
+		if len(p.Errors()) != 0 {
+			t.Fatalf("tests[%d] - parser errors: %v", i, p.Errors())
+		}
+
+		in, err := New(prog)
+		if err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+
+		res, err := in.Call("main", tt.args)
+		if tt.err != "" {
+			if err == nil || err.Error() != tt.err {
+				t.Fatalf("tests[%d] - expected the error %q for %s with %v, got %v", i, tt.err, tt.input, tt.args, err)
+			}
+			continue
+		}
+		if err != nil || res != tt.expected {
+			t.Fatalf("tests[%d] - %s with %v wrong. expected=%d, got=%d, err=%v", i, tt.input, tt.args, tt.expected, res, err)
+		}
+	}
+}
@@ -0,0 +1,154 @@
+package interpreter
+
+import (
+	"fmt"
+	"math/big"
+	"strconv"
+	"strings"
+
+	"github.com/simplang/arith"
+	"github.com/simplang/ast"
+	"github.com/simplang/token"
+)
+
+// kind tells which field of a value is set
+type kind int
+
+const (
+	intKind kind = iota
+	boolKind
+	funcKind
+	arrayKind
+	stringKind
+)
+
+func (k kind) String() string {
+	switch k {
+	case intKind:
+		return "integer"
+	case boolKind:
+		return "boolean"
+	case funcKind:
+		return "function"
+	case arrayKind:
+		return "array"
+	case stringKind:
+		return "string"
+	}
+	return fmt.Sprintf("kind(%d)", int(k))
+}
+
+// article returns the name of k with its indefinite article for error messages
+func (k kind) article() string {
+	if k == intKind || k == arrayKind {
+		return "an " + k.String()
+	}
+	return "a " + k.String()
+}
+
+// value is the result of an expression
+type value struct {
+	kind kind
+	i    int64
+	n    *big.Int // an integer not fitting into i in big mode, see bigValue
+	b    bool
+	f    *closure
+	a    []value // arrays are never modified, so they can share their elements
+	s    string
+}
+
+// closure is a function along with the variables visible at its definition.
+// Top-level functions don't see any variables.
+type closure struct {
+	f   *ast.Function
+	env *environment
+}
+
+func intValue(i int64) value {
+	return value{kind: intKind, i: i}
+}
+
+func boolValue(b bool) value {
+	return value{kind: boolKind, b: b}
+}
+
+func funcValue(c *closure) value {
+	return value{kind: funcKind, f: c}
+}
+
+func arrayValue(a []value) value {
+	return value{kind: arrayKind, a: a}
+}
+
+func stringValue(s string) value {
+	return value{kind: stringKind, s: s}
+}
+
+// String formats v the way print writes it for int64 integers
+func (v value) String() string {
+	return v.format(arith.Int{})
+}
+
+// format formats v the way print writes it with integers of type t, strings inside
+// arrays are quoted
+func (v value) format(t arith.Int) string {
+	switch v.kind {
+	case intKind:
+		if v.n != nil {
+			return v.n.String()
+		}
+		return t.Format(v.i)
+	case boolKind:
+		return fmt.Sprint(v.b)
+	case funcKind:
+		return fmt.Sprintf("<function %s>", v.f.f.Name.Name)
+	case arrayKind:
+		elements := make([]string, len(v.a))
+		for i, e := range v.a {
+			if e.kind == stringKind {
+				elements[i] = strconv.Quote(e.s)
+			} else {
+				elements[i] = e.format(t)
+			}
+		}
+		return "[" + strings.Join(elements, ", ") + "]"
+	case stringKind:
+		return v.s
+	}
+	return "<invalid>"
+}
+
+// asInt returns the integer v holds, an error is raised at t for any other value
+func asInt(v value, t *token.Token) int64 {
+	if v.kind != intKind {
+		throwError(fmt.Sprintf("expected an integer, got %s", v.kind.article()), t)
+	}
+	if v.n != nil {
+		throwError(fmt.Sprintf("integer %s does not fit into 64 bits", v.n), t)
+	}
+	return v.i
+}
+
+// asBool returns the boolean v holds, an error is raised at t for any other value
+func asBool(v value, t *token.Token) bool {
+	if v.kind != boolKind {
+		throwError(fmt.Sprintf("expected a boolean, got %s", v.kind.article()), t)
+	}
+	return v.b
+}
+
+// asFunc returns the function v holds, an error is raised at t for any other value
+func asFunc(v value, t *token.Token) *closure {
+	if v.kind != funcKind {
+		throwError(fmt.Sprintf("expected a function, got %s", v.kind.article()), t)
+	}
+	return v.f
+}
+
+// asArray returns the elements of the array v holds, an error is raised at t for any other value
+func asArray(v value, t *token.Token) []value {
+	if v.kind != arrayKind {
+		throwError(fmt.Sprintf("expected an array, got %s", v.kind.article()), t)
+	}
+	return v.a
+}
@@ -2,7 +2,12 @@ package lexer
 
 import "github.com/simplang/token"
 
-import "fmt"
+import (
+	"fmt"
+	"math/big"
+	"strconv"
+	"strings"
+)
 
 type Lexer struct {
 	input        string
@@ -50,7 +55,8 @@ func (l *Lexer) NextToken() (token.Token, error) {
 	startL := l.line
 	startC := l.column
 
-	// Operators are (, ), =, &&, ||, !, <, ==, +, *, -
+	// Operators are (, ), [, ], ",", ;, =, &&, ||, !, <, >, <=, >=, ==, !=, +, *, /, %, -,
+	// &, |, ^, ~, <<, >>, >>>, ->
 	switch l.ch {
 	case '=':
 		if l.peekChar() != '=' {
@@ -64,34 +70,108 @@ func (l *Lexer) NextToken() (token.Token, error) {
 		tok = newToken(token.LPAREN, l.ch)
 	case ')':
 		tok = newToken(token.RPAREN, l.ch)
+	case '[':
+		tok = newToken(token.LBRACKET, l.ch)
+	case ']':
+		tok = newToken(token.RBRACKET, l.ch)
+	case ',':
+		tok = newToken(token.COMMA, l.ch)
 	case '&':
-		l.readChar()
-		if l.ch == '&' {
-			tok = token.Token{Type: token.LOG_AND, Literal: "&&"}
+		if l.peekChar() != '&' {
+			tok = newToken(token.BIT_AND, l.ch)
 		} else {
-			err = l.generateError(fmt.Sprint("Expected '&', got '", string(l.ch), "' instead"))
-			tok = newToken(token.ILLEGAL, l.ch)
+			l.readChar()
+			tok = token.Token{Type: token.LOG_AND, Literal: "&&"}
 		}
 
 	case '|':
-		l.readChar()
-		if l.ch == '|' {
-			tok = token.Token{Type: token.LOG_OR, Literal: "||"}
+		if l.peekChar() != '|' {
+			tok = newToken(token.BIT_OR, l.ch)
 		} else {
-			err = l.generateError(fmt.Sprint("Expected '|', got '", string(l.ch), "' instead"))
-			tok = newToken(token.ILLEGAL, l.ch)
+			l.readChar()
+			tok = token.Token{Type: token.LOG_OR, Literal: "||"}
 		}
 
+	case '^':
+		tok = newToken(token.BIT_XOR, l.ch)
+	case '~':
+		tok = newToken(token.BIT_NOT, l.ch)
+
 	case '!':
-		tok = newToken(token.NOT, l.ch)
+		if l.peekChar() != '=' {
+			tok = newToken(token.NOT, l.ch)
+		} else {
+			l.readChar()
+			tok = token.Token{Type: token.NOT_EQUAL, Literal: "!="}
+		}
+
 	case '<':
-		tok = newToken(token.LESS, l.ch)
+		switch l.peekChar() {
+		case '=':
+			l.readChar()
+			tok = token.Token{Type: token.LESS_EQUAL, Literal: "<="}
+		case '<':
+			l.readChar()
+			tok = token.Token{Type: token.SHIFT_LEFT, Literal: "<<"}
+		default:
+			tok = newToken(token.LESS, l.ch)
+		}
+
+	case '>':
+		switch l.peekChar() {
+		case '=':
+			l.readChar()
+			tok = token.Token{Type: token.GREATER_EQUAL, Literal: ">="}
+		case '>':
+			l.readChar()
+			if l.peekChar() != '>' {
+				tok = token.Token{Type: token.SHIFT_RIGHT, Literal: ">>"}
+			} else {
+				l.readChar()
+				tok = token.Token{Type: token.SHIFT_RIGHT_LOGICAL, Literal: ">>>"}
+			}
+		default:
+			tok = newToken(token.GREATER, l.ch)
+		}
+
 	case '+':
 		tok = newToken(token.PLUS, l.ch)
 	case '*':
 		tok = newToken(token.TIMES, l.ch)
+	case '/':
+		tok = newToken(token.SLASH, l.ch)
+	case '%':
+		tok = newToken(token.PERCENT, l.ch)
 	case '-':
-		tok = newToken(token.MINUS, l.ch)
+		if l.peekChar() != '>' {
+			tok = newToken(token.MINUS, l.ch)
+		} else {
+			l.readChar()
+			tok = token.Token{Type: token.ARROW, Literal: "->"}
+		}
+
+	case '\'':
+		tok.Line = l.line
+		tok.Column = l.column
+		tok.Type = token.CHAR
+		tok.Literal, err = l.readCharLiteral()
+		if err != nil {
+			tok.Type = token.ILLEGAL
+		}
+		return tok, err
+
+	case '"':
+		tok.Line = l.line
+		tok.Column = l.column
+		tok.Type = token.STRING
+		tok.Literal, err = l.readStringLiteral()
+		if err != nil {
+			tok.Type = token.ILLEGAL
+		}
+		return tok, err
+
+	case ';':
+		tok = newToken(token.SEMICOLON, l.ch)
 
 	case 0:
 		tok.Literal = ""
@@ -112,7 +192,10 @@ func (l *Lexer) NextToken() (token.Token, error) {
 			tok.Line = l.line
 			tok.Column = l.column
 			tok.Type = token.INT
-			tok.Literal = l.readNumber()
+			tok.Literal, err = l.readNumber()
+			if err != nil {
+				tok.Type = token.ILLEGAL
+			}
 			return tok, err
 		}
 
@@ -170,14 +253,215 @@ func (l *Lexer) readItentifier() string {
 	return l.input[position:l.position]
 }
 
-func (l *Lexer) readNumber() string {
+// integer = decimal | "0x" hexdigits | "0b" bindigits | "0o" octdigits
+// digits may be separated by single underscores: 1_000_000, 0xFF_FF
+func (l *Lexer) readNumber() (string, error) {
 	position := l.position
-	for isDigit(l.ch) {
+	line, column := l.line, l.column
+
+	if l.ch == '0' && strings.IndexByte("xXbBoO", l.peekChar()) >= 0 {
+		l.readChar()
 		l.readChar()
 	}
-	return l.input[position:l.position]
+
+	// everything that could continue the literal belongs to it, so 12ab is reported
+	// as a malformed number instead of the number 12 followed by the identifier ab
+	for isLetter(l.ch) || isDigit(l.ch) {
+		l.readChar()
+	}
+
+	// whether the value fits is up to the parser, which may accept big integers
+	literal := l.input[position:l.position]
+	if _, _, err := integerDigits(literal); err != nil {
+		return literal, l.generateErrorAt(err.Error(), line, column)
+	}
+
+	return literal, nil
+}
+
+// character = "'" (char | escape) "'"
+// escapes are the ones of Go, e.g. \n, \x41 or \u00e4, and \' for the quote itself
+func (l *Lexer) readCharLiteral() (string, error) {
+	position := l.position
+	line, column := l.line, l.column
+
+	// skip opening quote
+	l.readChar()
+
+	for l.ch != '\'' {
+		if l.ch == 0 || l.ch == '\n' {
+			return l.input[position:l.position], l.generateErrorAt("character literal not terminated", line, column)
+		}
+
+		if l.ch == '\\' && l.peekChar() != 0 && l.peekChar() != '\n' {
+			l.readChar()
+		}
+		l.readChar()
+	}
+
+	// skip closing quote
+	l.readChar()
+
+	literal := l.input[position:l.position]
+	if _, err := ParseChar(literal); err != nil {
+		return literal, l.generateErrorAt(err.Error(), line, column)
+	}
+
+	return literal, nil
+}
+
+// string = '"' {char | escape} '"'
+// escapes are the ones of Go, a string can't span several lines
+func (l *Lexer) readStringLiteral() (string, error) {
+	position := l.position
+	line, column := l.line, l.column
+
+	// skip opening quote
+	l.readChar()
+
+	for l.ch != '"' {
+		if l.ch == 0 || l.ch == '\n' {
+			return l.input[position:l.position], l.generateErrorAt("string literal not terminated", line, column)
+		}
+
+		if l.ch == '\\' && l.peekChar() != 0 && l.peekChar() != '\n' {
+			l.readChar()
+		}
+		l.readChar()
+	}
+
+	// skip closing quote
+	l.readChar()
+
+	literal := l.input[position:l.position]
+	if _, err := ParseString(literal); err != nil {
+		return literal, l.generateErrorAt(err.Error(), line, column)
+	}
+
+	return literal, nil
+}
+
+// ParseInteger returns the value of an integer literal as read by the lexer.
+// Values up to 1<<63 are accepted, so that the parser can handle -9223372036854775808.
+func ParseInteger(literal string) (uint64, error) {
+	digits, base, err := integerDigits(literal)
+	if err != nil {
+		return 0, err
+	}
+
+	val, err := strconv.ParseUint(digits, base, 64)
+	if err != nil || val > 1<<63 {
+		return 0, fmt.Errorf("integer literal %s does not fit into 64 bits", literal)
+	}
+
+	return val, nil
+}
+
+// ParseBigInteger returns the value of an integer literal of any size as read by the lexer
+func ParseBigInteger(literal string) (*big.Int, error) {
+	digits, base, err := integerDigits(literal)
+	if err != nil {
+		return nil, err
+	}
+
+	val, ok := new(big.Int).SetString(digits, base)
+	if !ok {
+		return nil, fmt.Errorf("invalid integer literal %s", literal)
+	}
+
+	return val, nil
+}
+
+// integerDigits checks the digits of an integer literal and returns them without
+// the prefix and the separators along with their base
+func integerDigits(literal string) (string, int, error) {
+	base, name, digits := 10, "decimal", literal
+
+	if len(literal) >= 2 && literal[0] == '0' {
+		switch literal[1] {
+		case 'x', 'X':
+			base, name, digits = 16, "hexadecimal", literal[2:]
+		case 'b', 'B':
+			base, name, digits = 2, "binary", literal[2:]
+		case 'o', 'O':
+			base, name, digits = 8, "octal", literal[2:]
+		}
+	}
+
+	if digits == "" {
+		return "", 0, fmt.Errorf("%s literal %s has no digits", name, literal)
+	}
+
+	for i := 0; i < len(digits); i++ {
+		if digits[i] == '_' {
+			if i+1 == len(digits) || digits[i+1] == '_' {
+				return "", 0, fmt.Errorf("'_' must separate successive digits in %s", literal)
+			}
+			continue
+		}
+
+		if digitValue(digits[i]) >= base {
+			return "", 0, fmt.Errorf("invalid digit '%c' in %s literal %s", digits[i], name, literal)
+		}
+	}
+
+	return strings.Replace(digits, "_", "", -1), base, nil
+}
+
+// ParseChar returns the code point of a character literal as read by the lexer
+func ParseChar(literal string) (int64, error) {
+	if len(literal) < 2 || literal[0] != '\'' || literal[len(literal)-1] != '\'' {
+		return 0, fmt.Errorf("character literal not terminated")
+	}
+
+	content := literal[1 : len(literal)-1]
+	if content == "" {
+		return 0, fmt.Errorf("empty character literal")
+	}
+
+	val, _, tail, err := strconv.UnquoteChar(content, '\'')
+	if err != nil {
+		return 0, fmt.Errorf("invalid escape sequence in character literal %s", literal)
+	}
+
+	if tail != "" {
+		return 0, fmt.Errorf("character literal %s contains more than one character", literal)
+	}
+
+	return int64(val), nil
+}
+
+// ParseString returns the content of a string literal as read by the lexer
+func ParseString(literal string) (string, error) {
+	if len(literal) < 2 || literal[0] != '"' || literal[len(literal)-1] != '"' {
+		return "", fmt.Errorf("string literal not terminated")
+	}
+
+	val, err := strconv.Unquote(literal)
+	if err != nil {
+		return "", fmt.Errorf("invalid escape sequence in string literal %s", literal)
+	}
+
+	return val, nil
+}
+
+// digitValue returns the value of a digit in bases up to 16, or 16 for any other byte
+func digitValue(ch byte) int {
+	switch {
+	case ch >= '0' && ch <= '9':
+		return int(ch - '0')
+	case ch >= 'a' && ch <= 'f':
+		return int(ch-'a') + 10
+	case ch >= 'A' && ch <= 'F':
+		return int(ch-'A') + 10
+	}
+	return 16
 }
 
 func (l *Lexer) generateError(msg string) error {
-	return fmt.Errorf("Syntax error: %s (line %d.%d)", msg, l.line, l.column)
+	return l.generateErrorAt(msg, l.line, l.column)
+}
+
+func (l *Lexer) generateErrorAt(msg string, line int, column int) error {
+	return fmt.Errorf("Syntax error: %s (line %d.%d)", msg, line, column)
 }
@@ -7,7 +7,7 @@ func TestNextToken(t *testing.T) {
 	input := `let a = 1 and
 	loopy = a+-1
 in
-	loopy
+	loopy / 2 % a
 end`
 	tests := []struct {
 		expectedType    token.TokenType
@@ -26,13 +26,21 @@ end`
 		{token.INT, "1"},
 		{token.IN, "in"},
 		{token.IDENT, "loopy"},
+		{token.SLASH, "/"},
+		{token.INT, "2"},
+		{token.PERCENT, "%"},
+		{token.IDENT, "a"},
 		{token.END, "end"},
 	}
 
 	l := New(input)
 
 	for i, tt := range tests {
-		tok := l.NextToken()
+		tok, err := l.NextToken()
+
+		if err != nil {
+			t.Fatalf("tests[%d] - unexpected error: %s", i, err)
+		}
 
 		if tok.Type != tt.expectedType {
 			t.Fatalf("tests[%d] - tokentype wrong. expected=%q, got=%q", i, tt.expectedType, tok.Type)
@@ -43,3 +51,236 @@ end`
 		}
 	}
 }
+
+func TestComparisonOperators(t *testing.T) {
+	input := `a<b>c<=d>=e==f!=g!h-i=j`
+	tests := []token.TokenType{
+		token.IDENT, token.LESS, token.IDENT, token.GREATER, token.IDENT, token.LESS_EQUAL,
+		token.IDENT, token.GREATER_EQUAL, token.IDENT, token.EQUAL, token.IDENT, token.NOT_EQUAL,
+		token.IDENT, token.NOT, token.IDENT, token.MINUS, token.IDENT, token.ASSIGN, token.IDENT, token.EOF,
+	}
+
+	l := New(input)
+
+	for i, expected := range tests {
+		tok, err := l.NextToken()
+
+		if err != nil {
+			t.Fatalf("tests[%d] - unexpected error: %s", i, err)
+		}
+
+		if tok.Type != expected {
+			t.Fatalf("tests[%d] - tokentype wrong. expected=%q, got=%q", i, expected, tok.Type)
+		}
+	}
+}
+
+func TestBitwiseOperators(t *testing.T) {
+	input := `a&b&&c|d||e^~f<<g>>h>>>i>=j<=k`
+	tests := []token.TokenType{
+		token.IDENT, token.BIT_AND, token.IDENT, token.LOG_AND, token.IDENT, token.BIT_OR, token.IDENT,
+		token.LOG_OR, token.IDENT, token.BIT_XOR, token.BIT_NOT, token.IDENT, token.SHIFT_LEFT, token.IDENT,
+		token.SHIFT_RIGHT, token.IDENT, token.SHIFT_RIGHT_LOGICAL, token.IDENT, token.GREATER_EQUAL,
+		token.IDENT, token.LESS_EQUAL, token.IDENT, token.EOF,
+	}
+
+	l := New(input)
+
+	for i, expected := range tests {
+		tok, err := l.NextToken()
+
+		if err != nil {
+			t.Fatalf("tests[%d] - unexpected error: %s", i, err)
+		}
+
+		if tok.Type != expected {
+			t.Fatalf("tests[%d] - tokentype wrong. expected=%q, got=%q", i, expected, tok.Type)
+		}
+	}
+}
+
+func TestLambdaTokens(t *testing.T) {
+	input := `fn x -> x-1 end`
+	tests := []token.TokenType{
+		token.FN, token.IDENT, token.ARROW, token.IDENT, token.MINUS, token.INT, token.END, token.EOF,
+	}
+
+	l := New(input)
+
+	for i, expected := range tests {
+		tok, err := l.NextToken()
+
+		if err != nil {
+			t.Fatalf("tests[%d] - unexpected error: %s", i, err)
+		}
+
+		if tok.Type != expected {
+			t.Fatalf("tests[%d] - tokentype wrong. expected=%q, got=%q", i, expected, tok.Type)
+		}
+	}
+}
+
+func TestArrayTokens(t *testing.T) {
+	input := `len ([a, 1][0]) array`
+	tests := []token.TokenType{
+		token.LEN, token.LPAREN, token.LBRACKET, token.IDENT, token.COMMA, token.INT, token.RBRACKET,
+		token.LBRACKET, token.INT, token.RBRACKET, token.RPAREN, token.ARRAY, token.EOF,
+	}
+
+	l := New(input)
+
+	for i, expected := range tests {
+		tok, err := l.NextToken()
+
+		if err != nil {
+			t.Fatalf("tests[%d] - unexpected error: %s", i, err)
+		}
+
+		if tok.Type != expected {
+			t.Fatalf("tests[%d] - tokentype wrong. expected=%q, got=%q", i, expected, tok.Type)
+		}
+	}
+}
+
+func TestNumberLiterals(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected int64
+	}{
+		{"42", 42},
+		{"007", 7},
+		{"1_000_000", 1000000},
+		{"0x2A", 42},
+		{"0Xff_ff", 65535},
+		{"0b101010", 42},
+		{"0o52", 42},
+		{"0x_2a", 42},
+		{"'a'", 97},
+		{"'\\n'", 10},
+		{"'\\''", 39},
+		{"'\\x41'", 65},
+		{"'ä'", 228},
+		{"9223372036854775807", 9223372036854775807},
+	}
+
+	for i, tt := range tests {
+		tok, err := New(tt.input).NextToken()
+		if err != nil {
+			t.Fatalf("tests[%d] - unexpected error: %s", i, err)
+		}
+
+		if tok.Literal != tt.input {
+			t.Fatalf("tests[%d] - literal wrong. expected=%q, got=%q", i, tt.input, tok.Literal)
+		}
+
+		var val int64
+		if tok.Type == token.CHAR {
+			val, err = ParseChar(tok.Literal)
+		} else {
+			var u uint64
+			u, err = ParseInteger(tok.Literal)
+			val = int64(u)
+		}
+
+		if err != nil || val != tt.expected {
+			t.Fatalf("tests[%d] - value of %s wrong. expected=%d, got=%d (%v)", i, tt.input, tt.expected, val, err)
+		}
+	}
+}
+
+func TestBigNumberLiterals(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"9223372036854775808", "9223372036854775808"},
+		{"0x1_0000_0000_0000_0000", "18446744073709551616"},
+		{"0o1_0000_0000_0000_0000_0000_0000_0000_0000", "79228162514264337593543950336"},
+		{"42", "42"},
+	}
+
+	for i, tt := range tests {
+		tok, err := New(tt.input).NextToken()
+		if err != nil || tok.Type != token.INT {
+			t.Fatalf("tests[%d] - expected an integer, got %v (%v)", i, tok, err)
+		}
+
+		val, err := ParseBigInteger(tok.Literal)
+		if err != nil || val.String() != tt.expected {
+			t.Fatalf("tests[%d] - value of %s wrong. expected=%s, got=%v (%v)", i, tt.input, tt.expected, val, err)
+		}
+	}
+
+	if _, err := ParseInteger("0x1_0000_0000_0000_0000"); err == nil || err.Error() != "integer literal 0x1_0000_0000_0000_0000 does not fit into 64 bits" {
+		t.Fatalf("expected 0x1_0000_0000_0000_0000 not to fit into 64 bits, got %v", err)
+	}
+}
+
+func TestStringLiterals(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`""`, ""},
+		{`"x = "`, "x = "},
+		{`"a\tb\n"`, "a\tb\n"},
+		{`"\"quoted\" \\"`, `"quoted" \`},
+		{`"ä\x41"`, "äA"},
+	}
+
+	for i, tt := range tests {
+		l := New(tt.input + ";")
+		tok, err := l.NextToken()
+		if err != nil {
+			t.Fatalf("tests[%d] - unexpected error: %s", i, err)
+		}
+
+		if tok.Type != token.STRING {
+			t.Fatalf("tests[%d] - tokentype wrong. expected=%q, got=%q", i, token.STRING, tok.Type)
+		}
+
+		val, err := ParseString(tok.Literal)
+		if err != nil || val != tt.expected {
+			t.Fatalf("tests[%d] - value wrong. expected=%q, got=%q (%v)", i, tt.expected, val, err)
+		}
+
+		if tok, _ := l.NextToken(); tok.Type != token.SEMICOLON {
+			t.Fatalf("tests[%d] - expected the string to end before ;, got %q", i, tok.Type)
+		}
+	}
+}
+
+func TestMalformedLiterals(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"0x", "Syntax error: hexadecimal literal 0x has no digits (line 1.0)"},
+		{" 0b102", "Syntax error: invalid digit '2' in binary literal 0b102 (line 1.1)"},
+		{"0o8", "Syntax error: invalid digit '8' in octal literal 0o8 (line 1.0)"},
+		{"12ab", "Syntax error: invalid digit 'a' in decimal literal 12ab (line 1.0)"},
+		{"1__0", "Syntax error: '_' must separate successive digits in 1__0 (line 1.0)"},
+		{"10_", "Syntax error: '_' must separate successive digits in 10_ (line 1.0)"},
+		{"''", "Syntax error: empty character literal (line 1.0)"},
+		{"'ab'", "Syntax error: character literal 'ab' contains more than one character (line 1.0)"},
+		{"'\\q'", "Syntax error: invalid escape sequence in character literal '\\q' (line 1.0)"},
+		{"'a", "Syntax error: character literal not terminated (line 1.0)"},
+		{"\"ab\ncd\"", "Syntax error: string literal not terminated (line 1.0)"},
+		{"\"a\\q\"", "Syntax error: invalid escape sequence in string literal \"a\\q\" (line 1.0)"},
+	}
+
+	for i, tt := range tests {
+		tok, err := New(tt.input).NextToken()
+		if err == nil {
+			t.Fatalf("tests[%d] - expected an error for %q, got token %v", i, tt.input, tok)
+		}
+
+		if tok.Type != token.ILLEGAL {
+			t.Fatalf("tests[%d] - tokentype wrong. expected=%q, got=%q", i, token.ILLEGAL, tok.Type)
+		}
+
+		if err.Error() != tt.expected {
+			t.Fatalf("tests[%d] - error wrong. expected=%q, got=%q", i, tt.expected, err.Error())
+		}
+	}
+}
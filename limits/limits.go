@@ -0,0 +1,74 @@
+package limits
+
+import (
+	"context"
+	"errors"
+	"fmt"
+)
+
+// Limits bound the work of a single run, so untrusted programs can't run forever.
+// A limit of zero means there is no limit.
+type Limits struct {
+	MaxSteps int64 // steps, what a step is depends on whether the interpreter or the vm runs
+	MaxDepth int   // calls that have not returned yet
+}
+
+// DefaultMaxDepth is the call depth the driver and the simplang package allow
+// unless another limit is set, deeper calls could overflow the Go stack of the
+// interpreter and crash the process
+const DefaultMaxDepth = 100000
+
+// the errors returned when a limit is exceeded, a canceled run returns the error
+// of its context
+var (
+	ErrSteps = errors.New("step limit exceeded")
+	ErrDepth = errors.New("call depth limit exceeded")
+)
+
+// steps between two looks at the context, Err of a context takes a lock
+const checkEvery = 1024
+
+// Counter counts the steps and calls of a run and reports when a limit is exceeded
+// or the context of the run is done
+type Counter struct {
+	Limits
+	ctx   context.Context
+	steps int64
+	depth int
+}
+
+// NewCounter returns a counter for a run with the limits l which ends when ctx is done
+func NewCounter(ctx context.Context, l Limits) *Counter {
+	return &Counter{Limits: l, ctx: ctx}
+}
+
+// Start reports whether the context is done already
+func (c *Counter) Start() error {
+	return c.ctx.Err()
+}
+
+// Step counts a step
+func (c *Counter) Step() error {
+	c.steps++
+	if c.MaxSteps > 0 && c.steps > c.MaxSteps {
+		return fmt.Errorf("%w, the limit is %d", ErrSteps, c.MaxSteps)
+	}
+	if c.steps%checkEvery == 0 {
+		return c.ctx.Err()
+	}
+	return nil
+}
+
+// Enter counts a call which has not returned yet
+func (c *Counter) Enter() error {
+	c.depth++
+	if c.MaxDepth > 0 && c.depth > c.MaxDepth {
+		return fmt.Errorf("%w, the limit is %d", ErrDepth, c.MaxDepth)
+	}
+	return nil
+}
+
+// Leave counts the return of a call
+func (c *Counter) Leave() {
+	c.depth--
+}
@@ -0,0 +1,142 @@
+package loader
+
+import (
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+
+	"github.com/simplang/ast"
+	"github.com/simplang/lexer"
+	"github.com/simplang/parser"
+)
+
+// Load parses the program in path along with every file it imports, directly or
+// indirectly, and merges all their functions into one program. Import paths are
+// relative to the directory of the importing file.
+//
+// Every file is parsed once, even if several files import it. Import cycles and
+// functions defined more than once are reported as errors, each error names the
+// file it belongs to. If there are errors, the program is nil.
+func Load(path string) (*ast.Program, []string) {
+	return LoadWithOptions(path, parser.Options{})
+}
+
+// LoadWithOptions is like Load, but every file is parsed with opts
+func LoadWithOptions(path string, opts parser.Options) (*ast.Program, []string) {
+	l := &loader{files: map[string]*file{}, errors: []string{}, options: opts}
+
+	root := l.load(filepath.Clean(path), nil)
+	if len(l.errors) != 0 {
+		return nil, l.errors
+	}
+
+	prog := l.merge(root)
+	if len(l.errors) != 0 {
+		return nil, l.errors
+	}
+
+	return prog, nil
+}
+
+type file struct {
+	path string
+	prog *ast.Program
+}
+
+type loader struct {
+	files   map[string]*file // every file that has been parsed, by path
+	loading []string         // the chain of imports being loaded, for reporting cycles
+	order   []*file          // files after all the files they import
+	options parser.Options
+	errors  []string
+}
+
+// load parses the file at path and the files it imports. imp is the import
+// declaration naming the file, nil for the main file.
+func (l *loader) load(path string, imp *ast.Import) *file {
+	for i, p := range l.loading {
+		if p == path {
+			cycle := append(append([]string{}, l.loading[i:]...), path)
+			l.errorf(l.loading[len(l.loading)-1], imp, "import cycle %s", strings.Join(cycle, " -> "))
+			return nil
+		}
+	}
+
+	if f, ok := l.files[path]; ok {
+		return f
+	}
+
+	// a file that can't be loaded is only reported once
+	l.files[path] = nil
+
+	source, err := ioutil.ReadFile(path)
+	if err != nil {
+		if imp == nil {
+			l.errors = append(l.errors, fmt.Sprintf("could not read file: %s", err.Error()))
+		} else {
+			l.errorf(l.loading[len(l.loading)-1], imp, "could not import %q: %s", imp.Path, err.Error())
+		}
+		return nil
+	}
+
+	p := parser.NewWithOptions(lexer.New(string(source)), l.options)
+	prog := p.ParseProgram()
+
+	for _, msg := range p.Errors() {
+		l.errors = append(l.errors, fmt.Sprintf("%s: %s", path, msg))
+	}
+	if len(p.Errors()) != 0 {
+		return nil
+	}
+
+	f := &file{path: path, prog: prog}
+	l.files[path] = f
+
+	l.loading = append(l.loading, path)
+	for _, i := range prog.Imports {
+		target := i.Path
+		if !filepath.IsAbs(target) {
+			target = filepath.Join(filepath.Dir(path), target)
+		}
+		l.load(target, i)
+	}
+	l.loading = l.loading[:len(l.loading)-1]
+
+	l.order = append(l.order, f)
+	return f
+}
+
+// merge returns a program with the functions of all files, the ones of imported
+// files come first
+func (l *loader) merge(root *file) *ast.Program {
+	type definition struct {
+		file *file
+		fn   *ast.Function
+	}
+
+	prog := &ast.Program{Token: root.prog.Token, Functions: []*ast.Function{}}
+	defined := map[string]definition{}
+
+	for _, f := range l.order {
+		for _, fn := range f.prog.Functions {
+			name := fn.Name.Name
+
+			if first, ok := defined[name]; ok {
+				l.errors = append(l.errors, fmt.Sprintf("function '%s' is defined twice, in %s (line %d.%d) and in %s (line %d.%d)",
+					name, first.file.path, first.fn.Token.Line, first.fn.Token.Column, f.path, fn.Token.Line, fn.Token.Column))
+				continue
+			}
+
+			defined[name] = definition{file: f, fn: fn}
+			prog.Functions = append(prog.Functions, fn)
+		}
+	}
+
+	return prog
+}
+
+func (l *loader) errorf(path string, imp *ast.Import, format string, args ...interface{}) {
+	msg := fmt.Sprintf(format, args...)
+	l.errors = append(l.errors, fmt.Sprintf("%s: %s (line %d.%d)", path, msg, imp.Token.Line, imp.Token.Column))
+}
@@ -0,0 +1,115 @@
+package loader
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// writeFiles creates the files below a new temporary directory and returns it
+func writeFiles(t *testing.T, files map[string]string) string {
+	dir := t.TempDir()
+
+	for name, content := range files {
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatalf("could not create directory: %s", err)
+		}
+		if err := ioutil.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatalf("could not write file: %s", err)
+		}
+	}
+
+	return dir
+}
+
+func TestLoad(t *testing.T) {
+	dir := writeFiles(t, map[string]string{
+		"main.simp":     "import \"lib/math.simp\"\nimport \"lib/digits.simp\"\nlet main x = numdigits (square (x)) end",
+		"lib/math.simp": "let square x = x * x end\nlet abs x = if x < 0 then -x else x end end",
+		"lib/digits.simp": `import "math.simp"
+let numdigits x = loop x = abs (x) and n = 1 in if x < 10 then n else recur (x / 10) (n + 1) end end end`,
+	})
+
+	prog, errs := Load(filepath.Join(dir, "main.simp"))
+	if len(errs) != 0 {
+		t.Fatalf("unexpected errors: %v", errs)
+	}
+
+	names := []string{}
+	for _, f := range prog.Functions {
+		names = append(names, f.Name.Name)
+	}
+
+	// math.simp is imported twice, but its functions are only merged once
+	expected := []string{"square", "abs", "numdigits", "main"}
+	if !reflect.DeepEqual(names, expected) {
+		t.Fatalf("functions wrong. expected=%v, got=%v", expected, names)
+	}
+}
+
+func TestLoadErrors(t *testing.T) {
+	tests := []struct {
+		files    map[string]string
+		expected []string
+	}{
+		{
+			map[string]string{
+				"main.simp": "import \"a.simp\"\nlet main x = x end",
+				"a.simp":    "import \"b.simp\"\nlet a x = x end",
+				"b.simp":    "import \"a.simp\"\nlet b x = x end",
+			},
+			[]string{"b.simp: import cycle", "a.simp -> ", "b.simp -> ", "a.simp (line 1.0)"},
+		},
+		{
+			map[string]string{
+				"main.simp": "import \"main.simp\"\nlet main x = x end",
+			},
+			[]string{"main.simp: import cycle", "main.simp -> ", "main.simp (line 1.0)"},
+		},
+		{
+			map[string]string{
+				"main.simp": "import \"lib.simp\"\n\nlet f x = x end\nlet main x = f (x) end",
+				"lib.simp":  "let g x = x end\nlet f x = x + 1 end",
+			},
+			[]string{"function 'f' is defined twice, in ", "lib.simp (line 2.1) and in ", "main.simp (line 3.1)"},
+		},
+		{
+			map[string]string{
+				"main.simp": "let f x = x end\nlet main x = f (x) end\nlet f x = 2 end",
+			},
+			[]string{"function 'f' is defined twice, in ", "main.simp (line 1.0) and in ", "main.simp (line 3.1)"},
+		},
+		{
+			map[string]string{
+				"main.simp": "import \"missing.simp\"\nlet main x = x end",
+			},
+			[]string{"main.simp: could not import \"missing.simp\": ", "(line 1.0)"},
+		},
+		{
+			map[string]string{
+				"main.simp": "import \"lib.simp\"\nlet main x = x end",
+				"lib.simp":  "let f x = x +",
+			},
+			[]string{"lib.simp: "},
+		},
+	}
+
+	for i, tt := range tests {
+		dir := writeFiles(t, tt.files)
+
+		prog, errs := Load(filepath.Join(dir, "main.simp"))
+		if prog != nil || len(errs) == 0 {
+			t.Fatalf("tests[%d] - expected errors, got none", i)
+		}
+
+		for _, part := range tt.expected {
+			if !strings.Contains(errs[0], part) {
+				t.Fatalf("tests[%d] - error wrong. expected it to contain %q, got %q", i, part, errs[0])
+			}
+		}
+	}
+}
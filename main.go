@@ -1,51 +1,270 @@
 package main
 
 import (
+	"context"
 	"fmt"
-	"io/ioutil"
+	"math/big"
 	"os"
 
 	"strconv"
+	"strings"
 
+	"github.com/simplang/arith"
+	"github.com/simplang/ast"
+	"github.com/simplang/checker"
+	"github.com/simplang/dot"
 	"github.com/simplang/interpreter"
-	"github.com/simplang/lexer"
+	"github.com/simplang/limits"
+	"github.com/simplang/loader"
+	"github.com/simplang/optimizer"
 	"github.com/simplang/parser"
+	"github.com/simplang/prelude"
 )
 
+func usage() {
+	fmt.Println("Usage: simplang [-O] [--inline=N] [--no-prelude] [--arithmetic=wrapping|checked|saturating|big] [--int=int8|...|int64|uint8|...|uint64]")
+	fmt.Println("                [--max-steps=N] [--max-depth=N] <filename> [args]")
+	fmt.Println("       simplang ast [--json] <filename>")
+	fmt.Println("       simplang dot [--calls] <filename> [function]")
+}
+
 func main() {
-	if len(os.Args) < 3 {
-		fmt.Println("Usage: simplang <filename> [args]")
+	if len(os.Args) >= 2 && os.Args[1] == "ast" {
+		printAST(os.Args[2:])
+		return
+	}
+
+	if len(os.Args) >= 2 && os.Args[1] == "dot" {
+		printDot(os.Args[2:])
 		return
 	}
 
-	path := os.Args[1]
-	file, err := ioutil.ReadFile(path)
+	args := os.Args[1:]
+	optimize := false
+	inline := -1
+	usePrelude := true
+	mode := arith.Wrapping
+	intType := arith.Int{}
+	lim := limits.Limits{MaxDepth: limits.DefaultMaxDepth}
 
-	if err != nil {
-		fmt.Println("Could not read file:", err.Error())
+	for len(args) > 0 && strings.HasPrefix(args[0], "-") {
+		switch {
+		case args[0] == "-O":
+			optimize = true
+		case args[0] == "--no-prelude":
+			usePrelude = false
+		case strings.HasPrefix(args[0], "--inline="):
+			n, err := strconv.Atoi(strings.TrimPrefix(args[0], "--inline="))
+			if err != nil || n < 0 {
+				fmt.Println("Invalid inline threshold:", args[0])
+				return
+			}
+			inline = n
+		case strings.HasPrefix(args[0], "--arithmetic="):
+			m, err := arith.ParseMode(strings.TrimPrefix(args[0], "--arithmetic="))
+			if err != nil {
+				fmt.Println(err.Error())
+				return
+			}
+			mode = m
+		case strings.HasPrefix(args[0], "--int="):
+			t, err := arith.ParseInt(strings.TrimPrefix(args[0], "--int="))
+			if err != nil {
+				fmt.Println(err.Error())
+				return
+			}
+			intType = t
+		case strings.HasPrefix(args[0], "--max-steps="):
+			n, err := strconv.ParseInt(strings.TrimPrefix(args[0], "--max-steps="), 10, 64)
+			if err != nil || n < 0 {
+				fmt.Println("Invalid step limit:", args[0])
+				return
+			}
+			lim.MaxSteps = n
+		case strings.HasPrefix(args[0], "--max-depth="):
+			n, err := strconv.Atoi(strings.TrimPrefix(args[0], "--max-depth="))
+			if err != nil || n < 0 {
+				fmt.Println("Invalid depth limit:", args[0])
+				return
+			}
+			lim.MaxDepth = n
+		default:
+			usage()
+			return
+		}
+		args = args[1:]
+	}
+
+	// -O inlines small functions unless a threshold is given explicitly
+	if optimize && inline < 0 {
+		inline = optimizer.DefaultInlineThreshold
+	}
+
+	if len(args) < 1 {
+		usage()
+		return
+	}
+
+	if mode == arith.Big && !intType.Is64() {
+		fmt.Println("big arithmetic can't be used with", intType)
+		return
+	}
+
+	// literals beyond 64 bits are only accepted in big mode
+	a := parseFile(args[0], parser.Options{BigIntegers: mode == arith.Big, Int: intType})
+	if a == nil {
 		return
 	}
 
-	l := lexer.New(string(file))
-	p := parser.New(l)
-	a := p.ParseProgram()
+	if usePrelude {
+		prelude.Add(a)
+	}
 
-	if len(p.Errors()) != 0 {
-		fmt.Println("Generated", len(p.Errors()), "error(s):")
-		for i, val := range p.Errors() {
+	if _, errs := checker.Types(a); len(errs) != 0 {
+		fmt.Println("Generated", len(errs), "type error(s):")
+		for i, val := range errs {
 			fmt.Printf("%d: %s\n", i+1, val)
 		}
 		return
 	}
 
-	params := make([]int64, len(os.Args)-2)
-	for i := 2; i < len(os.Args); i++ {
-		params[i-2], err = strconv.ParseInt(os.Args[i], 10, 64)
-		if err != nil {
-			fmt.Println(os.Args, "could not be converted to an integer")
+	if optimize {
+		optimizer.TailCalls(a)
+	}
+
+	if inline > 0 {
+		optimizer.Inline(a, inline)
+	}
+
+	if optimize {
+		optimizer.FoldWith(a, mode, intType)
+	}
+
+	params, err := parseParams(args[1:], mode, intType)
+	if err != nil {
+		fmt.Println(err.Error())
+		return
+	}
+	//a.Print(0)
+	in, err := interpreter.New(a)
+	if err == nil {
+		in.SetArithmetic(mode)
+		in.SetIntType(intType)
+		in.SetLimits(lim)
+		var res *big.Int
+		if res, err = in.CallBig(context.Background(), "main", params); err == nil {
+			fmt.Println(res)
 			return
 		}
 	}
-	//a.Print(0)
-	fmt.Println(interpreter.Interprete(a, params))
+
+	fmt.Printf("Error: %s\n", err)
+	os.Exit(1)
+}
+
+// parseParams converts the arguments of main, they must fit in the integer type
+// unless the arithmetic is big
+func parseParams(args []string, mode arith.Mode, t arith.Int) ([]*big.Int, error) {
+	params := make([]*big.Int, len(args))
+	for i, arg := range args {
+		p, ok := new(big.Int).SetString(arg, 10)
+		if !ok {
+			return nil, fmt.Errorf("%s could not be converted to an integer", arg)
+		}
+		if _, fits := t.FromBig(p); mode != arith.Big && !fits {
+			return nil, fmt.Errorf("%s does not fit in %s", arg, t)
+		}
+		params[i] = p
+	}
+	return params, nil
+}
+
+// simplang ast [--json] <filename>
+func printAST(args []string) {
+	asJSON := false
+	if len(args) > 0 && args[0] == "--json" {
+		asJSON = true
+		args = args[1:]
+	}
+
+	if len(args) != 1 {
+		usage()
+		return
+	}
+
+	a := parseFile(args[0], parser.Options{BigIntegers: true})
+	if a == nil {
+		return
+	}
+
+	if !asJSON {
+		a.Print(0)
+		return
+	}
+
+	out, err := ast.MarshalExpression(a)
+	if err != nil {
+		fmt.Println("Could not encode ast:", err.Error())
+		return
+	}
+
+	fmt.Println(string(out))
+}
+
+// simplang dot [--calls] <filename> [function]
+func printDot(args []string) {
+	calls := false
+	if len(args) > 0 && args[0] == "--calls" {
+		calls = true
+		args = args[1:]
+	}
+
+	if len(args) < 1 || len(args) > 2 || (calls && len(args) != 1) {
+		usage()
+		return
+	}
+
+	a := parseFile(args[0], parser.Options{BigIntegers: true})
+	if a == nil {
+		return
+	}
+
+	if calls {
+		dot.CallGraph(os.Stdout, a)
+		return
+	}
+
+	if len(args) == 1 {
+		dot.Tree(os.Stdout, a)
+		return
+	}
+
+	for _, f := range a.Functions {
+		if f.Name.Name == args[1] {
+			dot.Tree(os.Stdout, f)
+			return
+		}
+	}
+
+	fmt.Printf("Function '%s' could not be found\n", args[1])
+}
+
+// parseFile returns the program in path along with the files it imports, or nil
+// if a file could not be read or parsed
+func parseFile(path string, opts parser.Options) *ast.Program {
+	a, errs := loader.LoadWithOptions(path, opts)
+
+	if len(errs) != 0 {
+		fmt.Println("Generated", len(errs), "error(s):")
+		for i, val := range errs {
+			fmt.Printf("%d: %s\n", i+1, val)
+		}
+		return nil
+	}
+
+	for _, w := range checker.CheckWith(a, opts.Int) {
+		fmt.Fprintln(os.Stderr, w)
+	}
+
+	return a
 }
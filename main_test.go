@@ -0,0 +1,49 @@
+package main
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/simplang/arith"
+)
+
+func TestParseParams(t *testing.T) {
+	uint8Type, err := arith.ParseInt("uint8")
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	tests := []struct {
+		args     []string
+		mode     arith.Mode
+		intType  arith.Int
+		expected string
+		err      string
+	}{
+		{[]string{"12", "-3"}, arith.Wrapping, arith.Int{}, "[12 -3]", ""},
+		{[]string{"abc"}, arith.Wrapping, arith.Int{}, "", "abc could not be converted to an integer"},
+		{[]string{"1", "abc"}, arith.Big, arith.Int{}, "", "abc could not be converted to an integer"},
+		{[]string{"12abc"}, arith.Wrapping, uint8Type, "", "12abc could not be converted to an integer"},
+		{[]string{"255"}, arith.Wrapping, uint8Type, "[255]", ""},
+		{[]string{"256"}, arith.Wrapping, uint8Type, "", "256 does not fit in uint8"},
+		{[]string{"-1"}, arith.Checked, uint8Type, "", "-1 does not fit in uint8"},
+		{[]string{"9223372036854775808"}, arith.Wrapping, arith.Int{}, "", "9223372036854775808 does not fit in int64"},
+		{[]string{"9223372036854775808"}, arith.Big, arith.Int{}, "[9223372036854775808]", ""},
+	}
+
+	for i, tt := range tests {
+		params, err := parseParams(tt.args, tt.mode, tt.intType)
+		if tt.err != "" {
+			if err == nil || err.Error() != tt.err {
+				t.Fatalf("tests[%d] - error wrong. expected=%q, got=%v", i, tt.err, err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Fatalf("tests[%d] - unexpected error: %s", i, err)
+		}
+		if got := fmt.Sprint(params); got != tt.expected {
+			t.Fatalf("tests[%d] - params wrong. expected=%s, got=%s", i, tt.expected, got)
+		}
+	}
+}
@@ -0,0 +1,487 @@
+package optimizer
+
+import (
+	"github.com/simplang/arith"
+	"github.com/simplang/ast"
+	"github.com/simplang/token"
+)
+
+// Fold evaluates constant subexpressions of every function in prog, simplifies
+// algebraic identities and removes if branches whose condition is constant.
+// The functions are rewritten in place.
+//
+// Arithmetic follows mode like in the interpreter. An operation which fails with
+// an overflow is left for the interpreter to report, in arith.Big mode the ones
+// whose result doesn't fit into 64 bits are left for it to compute.
+func Fold(prog *ast.Program, mode arith.Mode) {
+	FoldWith(prog, mode, arith.Int{})
+}
+
+// FoldWith is like Fold for a program whose integers have the type t
+func FoldWith(prog *ast.Program, mode arith.Mode, t arith.Int) {
+	fo := &folder{mode: mode, t: t, globals: map[string]bool{}}
+	for _, f := range prog.Functions {
+		fo.globals[f.Name.Name] = true
+	}
+
+	for _, f := range prog.Functions {
+		if len(f.Clauses) != 0 {
+			for _, c := range f.Clauses {
+				c.Body = fo.fold(c.Body)
+			}
+			continue
+		}
+
+		f.Body = fo.fold(f.Body)
+	}
+}
+
+// FoldExpression returns expr with all constant subexpressions evaluated
+func FoldExpression(expr ast.Expression, mode arith.Mode) ast.Expression {
+	return (&folder{mode: mode}).fold(expr)
+}
+
+type folder struct {
+	mode    arith.Mode
+	t       arith.Int
+	globals map[string]bool // names of the top-level functions
+}
+
+// fold returns the simplified version of expr
+func (fo *folder) fold(expr ast.Expression) ast.Expression {
+	switch e := expr.(type) {
+	case *ast.IfExpression:
+		e.Condition = fo.fold(e.Condition)
+		e.Consequence = fo.fold(e.Consequence)
+		e.Alternative = fo.fold(e.Alternative)
+
+		if c, ok := e.Condition.(*ast.Boolean); ok {
+			if c.Value {
+				return e.Consequence
+			}
+			return e.Alternative
+		}
+
+	case *ast.CondExpression:
+		return fo.foldCond(e)
+
+	case *ast.UnaryExpression:
+		return fo.foldUnary(e)
+
+	case *ast.BinaryExpression:
+		return fo.foldBinary(e)
+
+	case *ast.FunctionCall:
+		for i, p := range e.Params {
+			e.Params[i] = fo.fold(p)
+		}
+
+	case *ast.LetExpression:
+		fo.foldBindings(e.Bindings)
+		e.Expr = fo.fold(e.Expr)
+
+	case *ast.Function:
+		// local function
+		e.Body = fo.fold(e.Body)
+
+	case *ast.Lambda:
+		e.Body = fo.fold(e.Body)
+
+	case *ast.CallExpression:
+		e.Function = fo.fold(e.Function)
+		for i, p := range e.Params {
+			e.Params[i] = fo.fold(p)
+		}
+
+	case *ast.ArrayLiteral:
+		for i, el := range e.Elements {
+			e.Elements[i] = fo.fold(el)
+		}
+
+	case *ast.ArrayExpression:
+		e.Length = fo.fold(e.Length)
+		e.Function = fo.fold(e.Function)
+
+	case *ast.IndexExpression:
+		return fo.foldIndex(e)
+
+	case *ast.LengthExpression:
+		e.Array = fo.fold(e.Array)
+
+		// the elements can be dropped if evaluating them has no effect
+		if a, ok := e.Array.(*ast.ArrayLiteral); ok && fo.isPure(a) {
+			if n, ok := fo.t.Convert(arith.Checked, int64(len(a.Elements))); ok {
+				return fo.integer(e.Token, n)
+			}
+		}
+
+	case *ast.LoopExpression:
+		fo.foldBindings(e.Bindings)
+		e.Expr = fo.fold(e.Expr)
+
+	case *ast.PrintExpression:
+		for i, a := range e.Args {
+			e.Args[i] = fo.fold(a)
+		}
+
+	case *ast.Sequence:
+		return fo.foldSequence(e)
+
+	case *ast.Recur:
+		for i, a := range e.Args {
+			e.Args[i] = fo.fold(a)
+		}
+	}
+
+	return expr
+}
+
+// foldSequence drops the expressions before the last one which have no effect
+func (fo *folder) foldSequence(e *ast.Sequence) ast.Expression {
+	last := len(e.Exprs) - 1
+	exprs := []ast.Expression{}
+
+	for i, expr := range e.Exprs {
+		expr = fo.fold(expr)
+		if i == last || !fo.isPure(expr) {
+			exprs = append(exprs, expr)
+		}
+	}
+
+	if len(exprs) == 1 {
+		return exprs[0]
+	}
+
+	e.Exprs = exprs
+	return e
+}
+
+func (fo *folder) foldBindings(binds []*ast.Binding) {
+	for _, b := range binds {
+		b.Expr = fo.fold(b.Expr)
+	}
+}
+
+// foldCond drops branches whose condition is constantly false and cuts off the
+// expression at the first branch whose condition is constantly true
+func (fo *folder) foldCond(e *ast.CondExpression) ast.Expression {
+	branches := []*ast.CondBranch{}
+	cut := false
+
+	for _, b := range e.Branches {
+		b.Condition = fo.fold(b.Condition)
+		b.Consequence = fo.fold(b.Consequence)
+
+		if c, ok := b.Condition.(*ast.Boolean); ok {
+			if !c.Value {
+				continue
+			}
+
+			// the remaining branches are unreachable
+			e.Alternative = b.Consequence
+			cut = true
+			break
+		}
+
+		branches = append(branches, b)
+	}
+
+	if !cut {
+		e.Alternative = fo.fold(e.Alternative)
+	}
+
+	switch len(branches) {
+	case 0:
+		return e.Alternative
+	case 1:
+		return &ast.IfExpression{Token: e.Token, Condition: branches[0].Condition, Consequence: branches[0].Consequence, Alternative: e.Alternative}
+	}
+
+	e.Branches = branches
+	return e
+}
+
+// foldIndex replaces a constant index into an array literal by the element,
+// an index out of range is left to fail at runtime
+func (fo *folder) foldIndex(e *ast.IndexExpression) ast.Expression {
+	e.Array = fo.fold(e.Array)
+	e.Index = fo.fold(e.Index)
+
+	a, ok := e.Array.(*ast.ArrayLiteral)
+	i, isInt := smallInt(e.Index)
+	if !ok || !isInt || !fo.isPure(a) || i.Value < 0 || i.Value >= int64(len(a.Elements)) {
+		return e
+	}
+
+	return a.Elements[i.Value]
+}
+
+func (fo *folder) foldUnary(e *ast.UnaryExpression) ast.Expression {
+	e.Operand = fo.fold(e.Operand)
+
+	if c, ok := e.Operand.(*ast.Boolean); ok && e.Operator == token.NOT {
+		return boolean(e.Token, !c.Value)
+	}
+
+	if c, ok := smallInt(e.Operand); ok {
+		switch e.Operator {
+		case token.MINUS:
+			if res, ok := fo.t.Neg(fo.mode, c.Value); ok {
+				return fo.integer(e.Token, res)
+			}
+			return e
+		case token.BIT_NOT:
+			return fo.integer(e.Token, fo.t.Not(c.Value))
+		}
+	}
+
+	// !!x == x, ~~x == x and --x == x, also for the smallest int64 if it wraps around
+	if inner, ok := e.Operand.(*ast.UnaryExpression); ok && inner.Operator == e.Operator && (e.Operator != token.MINUS || fo.mode == arith.Wrapping) {
+		return inner.Operand
+	}
+
+	return e
+}
+
+func (fo *folder) foldBinary(e *ast.BinaryExpression) ast.Expression {
+	e.Left = fo.fold(e.Left)
+	e.Right = fo.fold(e.Right)
+
+	if l, ok := smallInt(e.Left); ok {
+		if r, ok := smallInt(e.Right); ok {
+			if res := fo.evalInts(e.Token, e.Operator, l.Value, r.Value); res != nil {
+				return res
+			}
+			return e
+		}
+	}
+
+	if l, ok := e.Left.(*ast.Boolean); ok {
+		if r, ok := e.Right.(*ast.Boolean); ok {
+			if res := evalBools(e.Token, e.Operator, l.Value, r.Value); res != nil {
+				return res
+			}
+			return e
+		}
+	}
+
+	switch e.Operator {
+	case token.PLUS:
+		if isConst(e.Left, 0) {
+			return e.Right
+		}
+		if isConst(e.Right, 0) {
+			return e.Left
+		}
+
+	case token.MINUS:
+		if isConst(e.Right, 0) {
+			return e.Left
+		}
+
+	case token.TIMES:
+		if isConst(e.Left, 1) {
+			return e.Right
+		}
+		if isConst(e.Right, 1) {
+			return e.Left
+		}
+		if (isConst(e.Left, 0) && fo.isPure(e.Right)) || (isConst(e.Right, 0) && fo.isPure(e.Left)) {
+			return fo.integer(e.Token, 0)
+		}
+
+	case token.SLASH:
+		if isConst(e.Right, 1) {
+			return e.Left
+		}
+
+	case token.LOG_AND, token.LOG_OR:
+		// both operands are always evaluated, so a constant operand can only
+		// replace the whole expression if the other one has no effect
+		absorbing := e.Operator == token.LOG_OR
+
+		for _, side := range [][2]ast.Expression{{e.Left, e.Right}, {e.Right, e.Left}} {
+			c, ok := side[0].(*ast.Boolean)
+			if !ok {
+				continue
+			}
+
+			if c.Value == absorbing {
+				if fo.isPure(side[1]) {
+					return boolean(e.Token, absorbing)
+				}
+				return e
+			}
+
+			// neutral constant
+			return side[1]
+		}
+	}
+
+	return e
+}
+
+// evalInts computes the constant binary expression "l op r", it returns nil if the
+// expression has to be left for the interpreter
+func (fo *folder) evalInts(t token.Token, op token.TokenType, l int64, r int64) ast.Expression {
+	switch op {
+	case token.LESS:
+		return boolean(t, fo.t.Less(l, r))
+	case token.GREATER:
+		return boolean(t, fo.t.Less(r, l))
+	case token.LESS_EQUAL:
+		return boolean(t, !fo.t.Less(r, l))
+	case token.GREATER_EQUAL:
+		return boolean(t, !fo.t.Less(l, r))
+	case token.EQUAL:
+		return boolean(t, l == r)
+	case token.NOT_EQUAL:
+		return boolean(t, l != r)
+	case token.PLUS, token.MINUS, token.TIMES, token.SLASH:
+		// division by zero is left for the interpreter to report
+		if op == token.SLASH && r == 0 {
+			return nil
+		}
+		return fo.arithmetic(t, op, l, r)
+	case token.PERCENT:
+		if r == 0 {
+			return nil
+		}
+		return fo.integer(t, fo.t.Rem(l, r))
+	case token.BIT_AND:
+		return fo.integer(t, l&r)
+	case token.BIT_OR:
+		return fo.integer(t, l|r)
+	case token.BIT_XOR:
+		return fo.integer(t, l^r)
+	case token.SHIFT_LEFT, token.SHIFT_RIGHT, token.SHIFT_RIGHT_LOGICAL:
+		// a negative shift count is left for the interpreter to report, in big mode
+		// shifts to the left may need more than 64 bits and >>> fails
+		if (r < 0 && !fo.t.Unsigned) || (fo.mode == arith.Big && op != token.SHIFT_RIGHT) {
+			return nil
+		}
+		switch op {
+		case token.SHIFT_LEFT:
+			return fo.integer(t, fo.t.Shl(l, uint64(r)))
+		case token.SHIFT_RIGHT:
+			return fo.integer(t, fo.t.Shr(l, uint64(r)))
+		}
+		return fo.integer(t, fo.t.Ushr(l, uint64(r)))
+	}
+
+	return nil
+}
+
+// arithmetic computes "l op r" for +, -, * and /, it returns nil if the operation
+// overflows in checked mode
+func (fo *folder) arithmetic(t token.Token, op token.TokenType, l int64, r int64) ast.Expression {
+	var res int64
+	var ok bool
+
+	switch op {
+	case token.PLUS:
+		res, ok = fo.t.Add(fo.mode, l, r)
+	case token.MINUS:
+		res, ok = fo.t.Sub(fo.mode, l, r)
+	case token.TIMES:
+		res, ok = fo.t.Mul(fo.mode, l, r)
+	case token.SLASH:
+		res, ok = fo.t.Div(fo.mode, l, r)
+	}
+
+	if !ok {
+		return nil
+	}
+	return fo.integer(t, res)
+}
+
+// evalBools computes the constant binary expression "l op r" on booleans
+func evalBools(t token.Token, op token.TokenType, l bool, r bool) ast.Expression {
+	switch op {
+	case token.LOG_AND:
+		return boolean(t, l && r)
+	case token.LOG_OR:
+		return boolean(t, l || r)
+	case token.EQUAL:
+		return boolean(t, l == r)
+	case token.NOT_EQUAL:
+		return boolean(t, l != r)
+	}
+
+	return nil
+}
+
+// isPure reports whether expr can be dropped without changing the behaviour of the
+// program. Function calls and loops might not terminate or fail, so they are not.
+// Neither are identifiers naming a top-level function, a constant is evaluated
+// like a call when it is used.
+func (fo *folder) isPure(expr ast.Expression) bool {
+	switch e := expr.(type) {
+	case *ast.Integer, *ast.Boolean, *ast.String:
+		return true
+	case *ast.Ident:
+		return !fo.globals[e.Name]
+	case *ast.UnaryExpression:
+		// may fail with an overflow
+		if e.Operator == token.MINUS && fo.mode == arith.Checked {
+			return false
+		}
+		return fo.isPure(e.Operand)
+	case *ast.BinaryExpression:
+		switch e.Operator {
+		case token.PLUS, token.MINUS, token.TIMES:
+			// may fail with an overflow
+			if fo.mode == arith.Checked {
+				return false
+			}
+		case token.SLASH, token.PERCENT:
+			// may fail with a division by zero, or with an overflow when dividing by -1
+			if r, ok := smallInt(e.Right); !ok || r.Value == 0 || (r.Value == -1 && e.Operator == token.SLASH && fo.mode == arith.Checked) {
+				return false
+			}
+		case token.SHIFT_LEFT, token.SHIFT_RIGHT, token.SHIFT_RIGHT_LOGICAL:
+			// may fail with a negative shift count, or with >>> in big mode
+			if r, ok := smallInt(e.Right); !ok || r.Value < 0 || (e.Operator == token.SHIFT_RIGHT_LOGICAL && fo.mode == arith.Big) {
+				return false
+			}
+		}
+		return fo.isPure(e.Left) && fo.isPure(e.Right)
+	case *ast.ArrayLiteral:
+		for _, el := range e.Elements {
+			if !fo.isPure(el) {
+				return false
+			}
+		}
+		return true
+	}
+
+	return false
+}
+
+func isConst(expr ast.Expression, val int64) bool {
+	i, ok := smallInt(expr)
+	return ok && i.Value == val
+}
+
+// smallInt returns expr if it is an integer literal fitting into 64 bits, the
+// folder leaves larger ones to the interpreter
+func smallInt(expr ast.Expression) (*ast.Integer, bool) {
+	i, ok := expr.(*ast.Integer)
+	return i, ok && i.Big == nil
+}
+
+// integer returns the literal val of the integer type at t
+func (fo *folder) integer(t token.Token, val int64) *ast.Integer {
+	return &ast.Integer{
+		Token: token.Token{Type: token.INT, Literal: fo.t.Format(val), Line: t.Line, Column: t.Column},
+		Value: val,
+	}
+}
+
+func boolean(t token.Token, val bool) *ast.Boolean {
+	tok := token.Token{Type: token.FALSE, Literal: "false", Line: t.Line, Column: t.Column}
+	if val {
+		tok.Type, tok.Literal = token.TRUE, "true"
+	}
+	return &ast.Boolean{Token: tok, Value: val}
+}
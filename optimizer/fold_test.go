@@ -0,0 +1,269 @@
+package optimizer
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/simplang/arith"
+	"github.com/simplang/ast"
+	"github.com/simplang/lexer"
+	"github.com/simplang/parser"
+)
+
+func TestFold(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"1 + 2 * 3", "7"},
+		{"x + -1", "(+ x -1)"},
+		{"9223372036854775807 + 1", "-9223372036854775808"},
+		{"9223372036854775807 * 2", "-2"},
+		{"x * 1 + 0", "x"},
+		{"x * 0", "0"},
+		{"(0 + -7) / 2", "-3"},
+		{"(0 + -7) % 2", "-1"},
+		{"7 % (0 + -2)", "1"},
+		{"x / 0", "(/ x 0)"},
+		{"~5 & 12 | 1 ^ 3", "10"},
+		{"1 << 63 >> 62", "-2"},
+		{"1 << 63 >>> 62", "2"},
+		{"1 << 64", "0"},
+		{"x << -1", "(<< x -1)"},
+		{"~~x", "x"},
+		{"(x << 2) * 0", "0"},
+		{"(x << y) * 0", "(* (<< x y) 0)"},
+		{"x / 1", "x"},
+		{"(x / y) * 0", "(* (/ x y) 0)"},
+		{"(x % 3) * 0", "0"},
+		{"f (x) * 0", "(* (f x) 0)"},
+		{"- - x", "x"},
+		{"!!x", "x"},
+		{"!(1 == 2)", "true"},
+		{"3 < 4 == true", "true"},
+		{"if !!x then 1 else 2 end", "(if x 1 2)"},
+		{"if 3 < 4 then x else f (x) end", "x"},
+		{"if 1 == 2 then x else f (1 + 1) end", "(f 2)"},
+		{"true && x", "x"},
+		{"true && x < y", "(< x y)"},
+		{"x || true", "true"},
+		{"f (x) || true", "(|| (f x) true)"},
+		{"if false || x then y else z end", "(if x y z)"},
+		{"if x then 1 elif y then 2 else 3 end", "(cond (x 1) (y 2) 3)"},
+		{"if false then 1 elif y then 2 elif !!z then 3 else 4 end", "(cond (y 2) (z 3) 4)"},
+		{"if false then 1 elif y then 2 elif 1 > 2 then 3 else 4 end", "(if y 2 4)"},
+		{"if x then 1 elif true then 2 elif y then 3 else 4 end", "(if x 1 2)"},
+		{"if false then 1 elif 2 != 3 then x else 4 end", "x"},
+		{"len ([x, y + 1, 2])", "3"},
+		{"len ([x, f (y)])", "(len ([] x (f y)))"},
+		{"[x, 1 + 2][1] * y", "(* 3 y)"},
+		{"[x, y][2]", "([] ([] x y) 2)"},
+		{"[x, y / z][0]", "([] ([] x (/ y z)) 0)"},
+		{"1; x; \"a\"; 2 + 3", "5"},
+		{"print (x); y; print (1 + 1); y", "(; (print x) (print 2) y)"},
+		{"loop i = 0 + 0 in if i < 10 then recur (i + 1) else i end end", "(loop (i 0) (if (< i 10) (recur (+ i 1)) i))"},
+	}
+
+	for i, tt := range tests {
+		p := parser.New(lexer.New("let main x y z = " + tt.input + " end"))
+		prog := p.ParseProgram()
+		if len(p.Errors()) != 0 {
+			t.Fatalf("tests[%d] - parser errors: %v", i, p.Errors())
+		}
+
+		Fold(prog, arith.Wrapping)
+
+		if got := sexpr(prog.Functions[0].Body); got != tt.expected {
+			t.Fatalf("tests[%d] - %q folded wrong. expected=%s, got=%s", i, tt.input, tt.expected, got)
+		}
+	}
+}
+
+func TestFoldArithmetic(t *testing.T) {
+	tests := []struct {
+		input     string
+		checked   string
+		saturated string
+	}{
+		{"1 + 2 * 3", "7", "7"},
+		{"9223372036854775807 + 1", "(+ 9223372036854775807 1)", "9223372036854775807"},
+		{"9223372036854775807 * -2", "(* 9223372036854775807 -2)", "-9223372036854775808"},
+		{"(-9223372036854775807 - 1) / -1", "(/ -9223372036854775808 -1)", "9223372036854775807"},
+		{"-(-9223372036854775807 - 1)", "(- -9223372036854775808)", "9223372036854775807"},
+		{"- - x", "(- (- x))", "(- (- x))"},
+		{"(x + 1) * 0", "(* (+ x 1) 0)", "0"},
+		{"(x / -1) * 0", "(* (/ x -1) 0)", "0"},
+		{"(x / 2) * 0", "0", "0"},
+		{"x - y; 1", "(; (- x y) 1)", "1"},
+	}
+
+	for i, tt := range tests {
+		for _, mode := range []arith.Mode{arith.Checked, arith.Saturating} {
+			p := parser.New(lexer.New("let main x y z = " + tt.input + " end"))
+			prog := p.ParseProgram()
+			if len(p.Errors()) != 0 {
+				t.Fatalf("tests[%d] - parser errors: %v", i, p.Errors())
+			}
+
+			Fold(prog, mode)
+
+			expected := tt.checked
+			if mode == arith.Saturating {
+				expected = tt.saturated
+			}
+			if got := sexpr(prog.Functions[0].Body); got != expected {
+				t.Fatalf("tests[%d] - %q folded wrong in %s mode. expected=%s, got=%s", i, tt.input, mode, expected, got)
+			}
+		}
+	}
+}
+
+func TestFoldBig(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"9223372036854775807 + 1", "(+ 9223372036854775807 1)"},
+		{"-(-9223372036854775807 - 1)", "(- -9223372036854775808)"},
+		{"18446744073709551616 - 1", "(- 18446744073709551616 1)"},
+		{"18446744073709551616 + 0", "18446744073709551616"},
+		{"(x + 1) * 0", "0"},
+		{"1 << 70", "(<< 1 70)"},
+		{"(1 << 4) + (256 >> 4)", "(+ (<< 1 4) 16)"},
+		{"x >>> 1; y", "(; (>>> x 1) y)"},
+	}
+
+	for i, tt := range tests {
+		p := parser.NewWithOptions(lexer.New("let main x y z = "+tt.input+" end"), parser.Options{BigIntegers: true})
+		prog := p.ParseProgram()
+		if len(p.Errors()) != 0 {
+			t.Fatalf("tests[%d] - parser errors: %v", i, p.Errors())
+		}
+
+		Fold(prog, arith.Big)
+
+		if got := sexpr(prog.Functions[0].Body); got != tt.expected {
+			t.Fatalf("tests[%d] - %q folded wrong. expected=%s, got=%s", i, tt.input, tt.expected, got)
+		}
+	}
+}
+
+func TestFoldIntTypes(t *testing.T) {
+	int8, uint8 := arith.Int{Bits: 8}, arith.Int{Bits: 8, Unsigned: true}
+
+	tests := []struct {
+		typ      arith.Int
+		mode     arith.Mode
+		input    string
+		expected string
+	}{
+		{int8, arith.Wrapping, "127 + 1", "-128"},
+		{int8, arith.Checked, "127 + 1", "(+ 127 1)"},
+		{int8, arith.Saturating, "-100 - 100", "-128"},
+		{int8, arith.Wrapping, "1 << 7", "-128"},
+		{uint8, arith.Wrapping, "0 - 1", "255"},
+		{uint8, arith.Checked, "-1", "(- 1)"},
+		{uint8, arith.Wrapping, "if 200 > 100 then ~15 else 0 end", "240"},
+		{uint8, arith.Wrapping, "x >> -1", "(>> x 255)"},
+	}
+
+	for i, tt := range tests {
+		p := parser.NewWithOptions(lexer.New("let main x y z = "+tt.input+" end"), parser.Options{Int: tt.typ})
+		prog := p.ParseProgram()
+		if len(p.Errors()) != 0 {
+			t.Fatalf("tests[%d] - parser errors: %v", i, p.Errors())
+		}
+
+		FoldWith(prog, tt.mode, tt.typ)
+
+		if got := sexpr(prog.Functions[0].Body); got != tt.expected {
+			t.Fatalf("tests[%d] - %q folded wrong as %s in %s mode. expected=%s, got=%s", i, tt.input, tt.typ, tt.mode, tt.expected, got)
+		}
+	}
+}
+
+func TestFoldGlobals(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"let g x = x end let main x = g * 0 end", "(* g 0)"},
+		{"let g x = x end let main x = 0 * g + x * 0 end", "(* 0 g)"},
+		{"let c = loop i = 0 in recur (i) end end let main x = c * 0 end", "(* c 0)"},
+		{"let c = 1 / 0 end let main x = [c, x][1] end", "([] ([] c x) 1)"},
+	}
+
+	for i, tt := range tests {
+		p := parser.New(lexer.New(tt.input))
+		prog := p.ParseProgram()
+		if len(p.Errors()) != 0 {
+			t.Fatalf("tests[%d] - parser errors: %v", i, p.Errors())
+		}
+
+		Fold(prog, arith.Wrapping)
+
+		if got := sexpr(prog.Functions[1].Body); got != tt.expected {
+			t.Fatalf("tests[%d] - %q folded wrong. expected=%s, got=%s", i, tt.input, tt.expected, got)
+		}
+	}
+}
+
+func sexpr(expr ast.Expression) string {
+	switch e := expr.(type) {
+	case *ast.Integer:
+		return e.BigValue().String()
+	case *ast.Boolean:
+		return fmt.Sprint(e.Value)
+	case *ast.Ident:
+		return e.Name
+	case *ast.UnaryExpression:
+		return fmt.Sprintf("(%s %s)", e.Operator, sexpr(e.Operand))
+	case *ast.BinaryExpression:
+		return fmt.Sprintf("(%s %s %s)", e.Operator, sexpr(e.Left), sexpr(e.Right))
+	case *ast.IfExpression:
+		return fmt.Sprintf("(if %s %s %s)", sexpr(e.Condition), sexpr(e.Consequence), sexpr(e.Alternative))
+	case *ast.CondExpression:
+		s := "(cond"
+		for _, b := range e.Branches {
+			s += fmt.Sprintf(" (%s %s)", sexpr(b.Condition), sexpr(b.Consequence))
+		}
+		return s + " " + sexpr(e.Alternative) + ")"
+	case *ast.FunctionCall:
+		return fmt.Sprintf("(%s %s)", e.Name, sexprs(e.Params))
+	case *ast.Recur:
+		return fmt.Sprintf("(recur %s)", sexprs(e.Args))
+	case *ast.LetExpression:
+		return fmt.Sprintf("(let %s %s)", bindings(e.Bindings), sexpr(e.Expr))
+	case *ast.LoopExpression:
+		return fmt.Sprintf("(loop %s %s)", bindings(e.Bindings), sexpr(e.Expr))
+	case *ast.ArrayLiteral:
+		return fmt.Sprintf("([] %s)", sexprs(e.Elements))
+	case *ast.IndexExpression:
+		return fmt.Sprintf("([] %s %s)", sexpr(e.Array), sexpr(e.Index))
+	case *ast.LengthExpression:
+		return fmt.Sprintf("(len %s)", sexpr(e.Array))
+	case *ast.PrintExpression:
+		return fmt.Sprintf("(print %s)", sexprs(e.Args))
+	case *ast.Sequence:
+		return fmt.Sprintf("(; %s)", sexprs(e.Exprs))
+	}
+
+	return fmt.Sprintf("%T", expr)
+}
+
+func sexprs(exprs []ast.Expression) string {
+	s := make([]string, len(exprs))
+	for i, e := range exprs {
+		s[i] = sexpr(e)
+	}
+	return strings.Join(s, " ")
+}
+
+func bindings(binds []*ast.Binding) string {
+	s := make([]string, len(binds))
+	for i, b := range binds {
+		s[i] = fmt.Sprintf("(%s %s)", b.Ident.Name, sexpr(b.Expr))
+	}
+	return strings.Join(s, " ")
+}
@@ -0,0 +1,431 @@
+package optimizer
+
+import (
+	"fmt"
+
+	"github.com/simplang/ast"
+	"github.com/simplang/callgraph"
+	"github.com/simplang/interpreter"
+	"github.com/simplang/token"
+)
+
+// DefaultInlineThreshold is the largest function body (counted in ast nodes)
+// that is inlined if no other threshold is given
+const DefaultInlineThreshold = 40
+
+// Inline replaces calls to non-recursive functions whose body has at most
+// threshold nodes by a let expression binding the arguments to the parameters.
+//
+//	sign (x*y)   =>   let x.1 = x*y in if x.1 == 0 then ... end end
+//
+// All names bound inside the inlined body get a fresh name (which can't appear
+// in source code), so the arguments and the body can never capture each other.
+// A call is not inlined where a variable hides the callee or a function called by
+// its body. Callees are processed before their callers, so inlined bodies are
+// inlined as well.
+func Inline(prog *ast.Program, threshold int) {
+	g := callgraph.New(prog)
+	recursive := g.RecursiveEdges()
+
+	functions := map[string]*ast.Function{}
+	for _, f := range prog.Functions {
+		functions[f.Name.Name] = f
+	}
+
+	in := &inliner{candidates: map[string]*ast.Function{}, callees: map[string][]string{}, bound: map[string]int{}}
+
+	for _, name := range g.Order() {
+		f := functions[name]
+		if len(f.Clauses) != 0 {
+			// functions defined by clauses choose their body at runtime, so they
+			// are never inlined themselves
+			for _, c := range f.Clauses {
+				names := []string{}
+				for _, p := range c.Patterns {
+					if ident, ok := p.(*ast.Ident); ok {
+						names = append(names, ident.Name)
+					}
+				}
+
+				in.bind(names...)
+				c.Body = in.inline(c.Body)
+				in.unbind(names...)
+			}
+			continue
+		}
+
+		in.bind(identNames(f.Params)...)
+		f.Body = in.inline(f.Body)
+		in.unbind(identNames(f.Params)...)
+
+		// a constant used in the body could be hidden by a variable of the caller,
+		// calls of a function hidden by a builtin go to the builtin
+		if recursive[name] == nil && ast.Size(f.Body) <= threshold && isClosed(f.Body, paramScope(f)) && interpreter.LookupBuiltin(name) == nil {
+			in.candidates[name] = f
+			in.callees[name] = calledNames(f.Body)
+		}
+	}
+}
+
+// calledNames returns the names of all functions called in expr, including the ones
+// already inlined into it
+func calledNames(expr ast.Expression) []string {
+	names := []string{}
+	ast.Inspect(expr, func(e ast.Expression) bool {
+		if fc, ok := e.(*ast.FunctionCall); ok {
+			names = append(names, fc.Name)
+		}
+		return true
+	})
+	return names
+}
+
+func paramScope(f *ast.Function) map[string]bool {
+	scope := map[string]bool{}
+	for _, p := range f.Params {
+		scope[p.Name] = true
+	}
+	return scope
+}
+
+// isClosed reports whether every identifier in expr is bound in scope or inside expr.
+// Local functions and lambdas are never copied, so expressions defining one are not
+// closed either.
+func isClosed(expr ast.Expression, scope map[string]bool) bool {
+	switch e := expr.(type) {
+	case *ast.Ident:
+		return scope[e.Name]
+
+	case *ast.Function, *ast.Lambda:
+		return false
+
+	case *ast.LetExpression:
+		return isClosedBindings(e.Bindings, e.Expr, scope)
+
+	case *ast.LoopExpression:
+		return isClosedBindings(e.Bindings, e.Expr, scope)
+	}
+
+	for _, c := range ast.Children(expr) {
+		if !isClosed(c, scope) {
+			return false
+		}
+	}
+	return true
+}
+
+func isClosedBindings(binds []*ast.Binding, body ast.Expression, scope map[string]bool) bool {
+	inner := make(map[string]bool, len(scope)+len(binds))
+	for k := range scope {
+		inner[k] = true
+	}
+
+	for _, b := range binds {
+		if !isClosed(b.Expr, inner) {
+			return false
+		}
+		inner[b.Ident.Name] = true
+	}
+
+	return isClosed(body, inner)
+}
+
+type inliner struct {
+	candidates map[string]*ast.Function
+	callees    map[string][]string // functions called by the body of each candidate
+	bound      map[string]int      // variables in scope, with their nesting count
+	fresh      int
+}
+
+// inline replaces all calls to candidates inside expr
+func (in *inliner) inline(expr ast.Expression) ast.Expression {
+	switch e := expr.(type) {
+	case *ast.FunctionCall:
+		for i, p := range e.Params {
+			e.Params[i] = in.inline(p)
+		}
+
+		f, ok := in.candidates[e.Name]
+		if !ok || len(f.Params) != len(e.Params) || in.hidden(e.Name) {
+			return e
+		}
+
+		return in.expand(e, f)
+
+	case *ast.IfExpression:
+		e.Condition = in.inline(e.Condition)
+		e.Consequence = in.inline(e.Consequence)
+		e.Alternative = in.inline(e.Alternative)
+
+	case *ast.CondExpression:
+		for _, b := range e.Branches {
+			b.Condition = in.inline(b.Condition)
+			b.Consequence = in.inline(b.Consequence)
+		}
+		e.Alternative = in.inline(e.Alternative)
+
+	case *ast.UnaryExpression:
+		e.Operand = in.inline(e.Operand)
+
+	case *ast.BinaryExpression:
+		e.Left = in.inline(e.Left)
+		e.Right = in.inline(e.Right)
+
+	case *ast.LetExpression:
+		names := in.inlineBindings(e.Bindings)
+		e.Expr = in.inline(e.Expr)
+		in.unbind(names...)
+
+	case *ast.LoopExpression:
+		names := in.inlineBindings(e.Bindings)
+		e.Expr = in.inline(e.Expr)
+		in.unbind(names...)
+
+	case *ast.Function:
+		// local function
+		in.bind(identNames(e.Params)...)
+		e.Body = in.inline(e.Body)
+		in.unbind(identNames(e.Params)...)
+
+	case *ast.Lambda:
+		in.bind(identNames(e.Params)...)
+		e.Body = in.inline(e.Body)
+		in.unbind(identNames(e.Params)...)
+
+	case *ast.CallExpression:
+		e.Function = in.inline(e.Function)
+		for i, p := range e.Params {
+			e.Params[i] = in.inline(p)
+		}
+
+	case *ast.ArrayLiteral:
+		for i, el := range e.Elements {
+			e.Elements[i] = in.inline(el)
+		}
+
+	case *ast.ArrayExpression:
+		e.Length = in.inline(e.Length)
+		e.Function = in.inline(e.Function)
+
+	case *ast.IndexExpression:
+		e.Array = in.inline(e.Array)
+		e.Index = in.inline(e.Index)
+
+	case *ast.LengthExpression:
+		e.Array = in.inline(e.Array)
+
+	case *ast.PrintExpression:
+		for i, a := range e.Args {
+			e.Args[i] = in.inline(a)
+		}
+
+	case *ast.Sequence:
+		for i, expr := range e.Exprs {
+			e.Exprs[i] = in.inline(expr)
+		}
+
+	case *ast.Recur:
+		for i, a := range e.Args {
+			e.Args[i] = in.inline(a)
+		}
+	}
+
+	return expr
+}
+
+// hidden reports whether a call of the candidate name can't be inlined at the current
+// position, since a variable hides it or one of the functions its body calls
+func (in *inliner) hidden(name string) bool {
+	if in.bound[name] > 0 {
+		return true
+	}
+
+	for _, callee := range in.callees[name] {
+		if in.bound[callee] > 0 {
+			return true
+		}
+	}
+
+	return false
+}
+
+// inlineBindings inlines sequential bindings, each binding sees the ones before it
+// and a local function also sees itself. The caller has to unbind the returned names.
+func (in *inliner) inlineBindings(binds []*ast.Binding) []string {
+	names := make([]string, len(binds))
+	for i, b := range binds {
+		names[i] = b.Ident.Name
+
+		if _, ok := b.Expr.(*ast.Function); ok {
+			in.bind(b.Ident.Name)
+			b.Expr = in.inline(b.Expr)
+			continue
+		}
+
+		b.Expr = in.inline(b.Expr)
+		in.bind(b.Ident.Name)
+	}
+	return names
+}
+
+func (in *inliner) bind(names ...string) {
+	for _, n := range names {
+		in.bound[n]++
+	}
+}
+
+func (in *inliner) unbind(names ...string) {
+	for _, n := range names {
+		in.bound[n]--
+	}
+}
+
+func identNames(idents []*ast.Ident) []string {
+	names := make([]string, len(idents))
+	for i, ident := range idents {
+		names[i] = ident.Name
+	}
+	return names
+}
+
+// expand returns the body of f bound to the arguments of fc
+func (in *inliner) expand(fc *ast.FunctionCall, f *ast.Function) ast.Expression {
+	scope := map[string]string{}
+	binds := make([]*ast.Binding, len(f.Params))
+
+	// the arguments are evaluated in the scope of the caller, the parameters are only
+	// visible in the body. Since every parameter gets a fresh name, the sequential
+	// let bindings behave exactly like the simultaneous binding of a call.
+	for i, p := range f.Params {
+		binds[i] = &ast.Binding{Token: fc.Token, Ident: in.rename(p, scope), Expr: fc.Params[i]}
+	}
+
+	let := token.Token{Type: token.LET, Literal: "let", Line: fc.Token.Line, Column: fc.Token.Column}
+	return &ast.LetExpression{Token: let, Bindings: binds, Expr: in.copy(f.Body, scope)}
+}
+
+// rename binds ident to a fresh name in scope and returns the renamed identifier
+func (in *inliner) rename(ident *ast.Ident, scope map[string]string) *ast.Ident {
+	in.fresh++
+	name := fmt.Sprintf("%s.%d", ident.Name, in.fresh)
+	scope[ident.Name] = name
+
+	t := ident.Token
+	t.Literal = name
+	return &ast.Ident{Token: t, Name: name}
+}
+
+// copy returns a deep copy of expr where every identifier is replaced according to scope
+// and every binding inside expr gets a fresh name
+func (in *inliner) copy(expr ast.Expression, scope map[string]string) ast.Expression {
+	switch e := expr.(type) {
+	case *ast.Integer:
+		c := *e
+		return &c
+
+	case *ast.Boolean:
+		c := *e
+		return &c
+
+	case *ast.String:
+		c := *e
+		return &c
+
+	case *ast.Ident:
+		c := *e
+		if name, ok := scope[e.Name]; ok {
+			c.Name = name
+			c.Token.Literal = name
+		}
+		return &c
+
+	case *ast.FunctionCall:
+		// calls of variables go to the renamed variable
+		c := &ast.FunctionCall{Token: e.Token, Name: e.Name, Params: in.copyAll(e.Params, scope)}
+		if name, ok := scope[e.Name]; ok {
+			c.Name = name
+			c.Token.Literal = name
+		}
+		return c
+
+	case *ast.CallExpression:
+		return &ast.CallExpression{Token: e.Token, Function: in.copy(e.Function, scope), Params: in.copyAll(e.Params, scope)}
+
+	case *ast.IfExpression:
+		return &ast.IfExpression{
+			Token:       e.Token,
+			Condition:   in.copy(e.Condition, scope),
+			Consequence: in.copy(e.Consequence, scope),
+			Alternative: in.copy(e.Alternative, scope),
+		}
+
+	case *ast.CondExpression:
+		branches := make([]*ast.CondBranch, len(e.Branches))
+		for i, b := range e.Branches {
+			branches[i] = &ast.CondBranch{Token: b.Token, Condition: in.copy(b.Condition, scope), Consequence: in.copy(b.Consequence, scope)}
+		}
+		return &ast.CondExpression{Token: e.Token, Branches: branches, Alternative: in.copy(e.Alternative, scope)}
+
+	case *ast.UnaryExpression:
+		return &ast.UnaryExpression{Token: e.Token, Operator: e.Operator, Operand: in.copy(e.Operand, scope)}
+
+	case *ast.BinaryExpression:
+		return &ast.BinaryExpression{Token: e.Token, Left: in.copy(e.Left, scope), Operator: e.Operator, Right: in.copy(e.Right, scope)}
+
+	case *ast.LetExpression:
+		inner, binds := in.copyBindings(e.Bindings, scope)
+		return &ast.LetExpression{Token: e.Token, Bindings: binds, Expr: in.copy(e.Expr, inner)}
+
+	case *ast.LoopExpression:
+		inner, binds := in.copyBindings(e.Bindings, scope)
+		return &ast.LoopExpression{Token: e.Token, Bindings: binds, Expr: in.copy(e.Expr, inner)}
+
+	case *ast.ArrayLiteral:
+		return &ast.ArrayLiteral{Token: e.Token, Elements: in.copyAll(e.Elements, scope)}
+
+	case *ast.ArrayExpression:
+		return &ast.ArrayExpression{Token: e.Token, Length: in.copy(e.Length, scope), Function: in.copy(e.Function, scope)}
+
+	case *ast.IndexExpression:
+		return &ast.IndexExpression{Token: e.Token, Array: in.copy(e.Array, scope), Index: in.copy(e.Index, scope)}
+
+	case *ast.LengthExpression:
+		return &ast.LengthExpression{Token: e.Token, Array: in.copy(e.Array, scope)}
+
+	case *ast.PrintExpression:
+		return &ast.PrintExpression{Token: e.Token, Args: in.copyAll(e.Args, scope)}
+
+	case *ast.Sequence:
+		return &ast.Sequence{Token: e.Token, Exprs: in.copyAll(e.Exprs, scope)}
+
+	case *ast.Recur:
+		return &ast.Recur{Token: e.Token, Args: in.copyAll(e.Args, scope)}
+	}
+
+	return expr
+}
+
+func (in *inliner) copyAll(exprs []ast.Expression, scope map[string]string) []ast.Expression {
+	res := make([]ast.Expression, len(exprs))
+	for i, e := range exprs {
+		res[i] = in.copy(e, scope)
+	}
+	return res
+}
+
+// copyBindings copies sequential bindings, each binding sees the ones before it.
+// It returns the scope of the body along with the copied bindings.
+func (in *inliner) copyBindings(binds []*ast.Binding, scope map[string]string) (map[string]string, []*ast.Binding) {
+	inner := make(map[string]string, len(scope)+len(binds))
+	for k, v := range scope {
+		inner[k] = v
+	}
+
+	res := make([]*ast.Binding, len(binds))
+	for i, b := range binds {
+		expr := in.copy(b.Expr, inner)
+		res[i] = &ast.Binding{Token: b.Token, Ident: in.rename(b.Ident, inner), Expr: expr}
+	}
+
+	return inner, res
+}
@@ -0,0 +1,180 @@
+package optimizer
+
+import (
+	"io/ioutil"
+	"testing"
+
+	"github.com/simplang/arith"
+	"github.com/simplang/ast"
+	"github.com/simplang/interpreter"
+	"github.com/simplang/lexer"
+	"github.com/simplang/parser"
+)
+
+// parseTestfile parses testfile.txt with main replaced by the main of src, the
+// other functions of src are added
+func parseTestfile(t *testing.T, src string) *ast.Program {
+	file, err := ioutil.ReadFile("../testfile.txt")
+	if err != nil {
+		t.Fatalf("could not read testfile: %s", err)
+	}
+
+	p := parser.New(lexer.New(string(file)))
+	prog := p.ParseProgram()
+	if len(p.Errors()) != 0 {
+		t.Fatalf("parser errors: %v", p.Errors())
+	}
+
+	mp := parser.New(lexer.New(src))
+	m := mp.ParseProgram()
+	if len(mp.Errors()) != 0 {
+		t.Fatalf("parser errors: %v", mp.Errors())
+	}
+
+	for _, f := range m.Functions {
+		if f.Name.Name != "main" {
+			prog.Functions = append(prog.Functions, f)
+			continue
+		}
+		for i, g := range prog.Functions {
+			if g.Name.Name == "main" {
+				prog.Functions[i] = f
+			}
+		}
+	}
+
+	return prog
+}
+
+func TestInlinePreservesResults(t *testing.T) {
+	tests := []struct {
+		main   string
+		inputs [][]int64
+	}{
+		{"let main x y = div (x) (y) end", [][]int64{{100, 7}, {-100, 7}, {100, -7}, {7, 100}, {0, 3}}},
+		{"let main x y = rem (x) (y) end", [][]int64{{100, 7}, {-100, 7}, {12345, 10}}},
+		{"let main x y = shiftr (x) (y) end", [][]int64{{1024, 3}, {-1, 60}}},
+		{"let main x y = nthdigit (x) (y) + numdigits (x) end", [][]int64{{987654321, 0}, {987654321, 4}}},
+		{"let main x = if ispalindrome (x) then 1 else 0 end end", [][]int64{{12321}, {12345}}},
+		{"let main max = largestpalindrome (max) end", [][]int64{{12}}},
+		{"let digits x = array (numdigits (x)) (fn i -> nthdigit (x) (i) end) end\nlet main x i = digits (x)[i] + len ([x, i]) end", [][]int64{{12345, 0}, {12345, 4}}},
+	}
+
+	for i, tt := range tests {
+		for _, input := range tt.inputs {
+			expected := interpreter.Interprete(parseTestfile(t, tt.main), input)
+
+			for _, threshold := range []int{10, DefaultInlineThreshold, 1000} {
+				prog := parseTestfile(t, tt.main)
+				Inline(prog, threshold)
+
+				if got := interpreter.Interprete(prog, input); got != expected {
+					t.Fatalf("tests[%d] - %v with threshold %d: expected=%d, got=%d", i, input, threshold, expected, got)
+				}
+
+				Fold(prog, arith.Wrapping)
+
+				if got := interpreter.Interprete(prog, input); got != expected {
+					t.Fatalf("tests[%d] - %v with threshold %d and folding: expected=%d, got=%d", i, input, threshold, expected, got)
+				}
+			}
+		}
+	}
+}
+
+func TestInlineRenaming(t *testing.T) {
+	p := parser.New(lexer.New(`let swap x y = let x = y + 1 and y = x in x * 10 + y end end
+let main x y = swap (y) (x) + (let t = x in swap (t) (y) end) end`))
+	prog := p.ParseProgram()
+	if len(p.Errors()) != 0 {
+		t.Fatalf("parser errors: %v", p.Errors())
+	}
+
+	expected := interpreter.Interprete(prog, []int64{3, 5})
+	Inline(prog, 1000)
+
+	if _, ok := prog.Functions[1].Body.(*ast.BinaryExpression).Left.(*ast.LetExpression); !ok {
+		t.Fatalf("swap was not inlined")
+	}
+
+	if got := interpreter.Interprete(prog, []int64{3, 5}); got != expected {
+		t.Fatalf("inlining changed result. expected=%d, got=%d", expected, got)
+	}
+}
+
+func TestInlineConstants(t *testing.T) {
+	p := parser.New(lexer.New(`let base = 10 end
+let scale x = x * base end
+let twice x = 2 * x end
+let main = let base = 2 in scale (base) + twice (base) end end`))
+	prog := p.ParseProgram()
+	if len(p.Errors()) != 0 {
+		t.Fatalf("parser errors: %v", p.Errors())
+	}
+
+	expected := interpreter.Interprete(prog, nil)
+	Inline(prog, 1000)
+
+	sum := prog.Functions[3].Body.(*ast.LetExpression).Expr.(*ast.BinaryExpression)
+	if _, ok := sum.Left.(*ast.FunctionCall); !ok {
+		t.Fatalf("scale uses the hidden constant base and must not be inlined")
+	}
+	if _, ok := sum.Right.(*ast.LetExpression); !ok {
+		t.Fatalf("twice was not inlined")
+	}
+
+	if got := interpreter.Interprete(prog, nil); got != expected {
+		t.Fatalf("inlining changed result. expected=%d, got=%d", expected, got)
+	}
+}
+
+func TestInlineLocalFunctions(t *testing.T) {
+	p := parser.New(lexer.New(`let g x = if x < 1 then 1 else g (x - 5) + 1 end end
+let h x = g (x) * 2 end
+let main x = let g y = y * 100 in h (x) + g (x) end end`))
+	prog := p.ParseProgram()
+	if len(p.Errors()) != 0 {
+		t.Fatalf("parser errors: %v", p.Errors())
+	}
+
+	expected := interpreter.Interprete(prog, []int64{3})
+	Inline(prog, 1000)
+
+	sum := prog.Functions[2].Body.(*ast.LetExpression).Expr.(*ast.BinaryExpression)
+	if _, ok := sum.Left.(*ast.FunctionCall); !ok {
+		t.Fatalf("h calls g, which is hidden by the local g, and must not be inlined")
+	}
+	if _, ok := sum.Right.(*ast.FunctionCall); !ok {
+		t.Fatalf("the call of the local g must not be replaced by the global g")
+	}
+
+	if got := interpreter.Interprete(prog, []int64{3}); got != expected {
+		t.Fatalf("inlining changed result. expected=%d, got=%d", expected, got)
+	}
+}
+
+func TestInlineFunctionValues(t *testing.T) {
+	p := parser.New(lexer.New(`let g x = if x < 1 then 1 else g (x - 5) + 1 end end
+let h x = g (x) * 2 end
+let apply f x = f (x) + 1 end
+let main x = let g = fn y -> y - x end in h (3) + g (4) + apply (fn y -> y * 10 end) (5) end end`))
+	prog := p.ParseProgram()
+	if len(p.Errors()) != 0 {
+		t.Fatalf("parser errors: %v", p.Errors())
+	}
+
+	expected := interpreter.Interprete(prog, []int64{0})
+	Inline(prog, 1000)
+
+	sum := prog.Functions[3].Body.(*ast.LetExpression).Expr.(*ast.BinaryExpression)
+	if _, ok := sum.Left.(*ast.BinaryExpression).Left.(*ast.FunctionCall); !ok {
+		t.Fatalf("h calls g, which is hidden by the variable g, and must not be inlined")
+	}
+	if _, ok := sum.Right.(*ast.LetExpression); !ok {
+		t.Fatalf("apply was not inlined")
+	}
+
+	if got := interpreter.Interprete(prog, []int64{0}); got != expected {
+		t.Fatalf("inlining changed result. expected=%d, got=%d", expected, got)
+	}
+}
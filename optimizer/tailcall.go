@@ -0,0 +1,118 @@
+package optimizer
+
+import (
+	"github.com/simplang/ast"
+	"github.com/simplang/interpreter"
+	"github.com/simplang/token"
+)
+
+// TailCalls rewrites every function that calls itself in tail position into a loop
+// over its parameters, so the tail calls don't grow the call stack.
+//
+//	let f x = if x == 0 then 0 else f (x + -1) end end
+//	=>
+//	let f x = loop x = x in if x == 0 then 0 else recur (x + -1) end end end
+//
+// Self calls that are not in tail position stay ordinary calls.
+func TailCalls(prog *ast.Program) {
+	for _, f := range prog.Functions {
+		tailCalls(f)
+	}
+}
+
+// tailCalls reports whether f was rewritten. Functions defined by clauses are
+// left alone, a loop can't choose between their bodies.
+func tailCalls(f *ast.Function) bool {
+	if len(f.Clauses) != 0 {
+		return false
+	}
+
+	// calls in the body go to the builtin of the same name
+	if interpreter.LookupBuiltin(f.Name.Name) != nil {
+		return false
+	}
+
+	// calls in the body go to the parameter
+	for _, p := range f.Params {
+		if p.Name == f.Name.Name {
+			return false
+		}
+	}
+
+	if !rewriteTailChild(f, &f.Body, false) {
+		return false
+	}
+
+	rewriteTailChild(f, &f.Body, true)
+
+	t := token.Token{Type: token.LOOP, Literal: "loop", Line: f.Token.Line, Column: f.Token.Column}
+	loop := &ast.LoopExpression{Token: t, Bindings: make([]*ast.Binding, len(f.Params)), Expr: f.Body}
+
+	for i, p := range f.Params {
+		ident := *p
+		loop.Bindings[i] = &ast.Binding{Token: p.Token, Ident: &ident, Expr: &ast.Ident{Token: p.Token, Name: p.Name}}
+	}
+
+	f.Body = loop
+	return true
+}
+
+// rewriteTail reports whether expr, which is in tail position of f, contains a self call
+// of f in tail position. If replace is true, those calls are replaced by a recur.
+//
+// Tail positions are the body itself, all branches of an if, the body of a let and
+// the last expression of a sequence.
+// The body of a loop is not, since a recur there would continue the inner loop.
+func rewriteTail(f *ast.Function, expr ast.Expression, replace bool) bool {
+	switch e := expr.(type) {
+	case *ast.IfExpression:
+		cons := rewriteTailChild(f, &e.Consequence, replace)
+		alt := rewriteTailChild(f, &e.Alternative, replace)
+		return cons || alt
+
+	case *ast.CondExpression:
+		found := false
+		for _, b := range e.Branches {
+			if rewriteTailChild(f, &b.Consequence, replace) {
+				found = true
+			}
+		}
+		if rewriteTailChild(f, &e.Alternative, replace) {
+			found = true
+		}
+		return found
+
+	case *ast.LetExpression:
+		// calls in the body go to the variable of the same name
+		for _, b := range e.Bindings {
+			if b.Ident.Name == f.Name.Name {
+				return false
+			}
+		}
+		return rewriteTailChild(f, &e.Expr, replace)
+
+	case *ast.Sequence:
+		return rewriteTailChild(f, &e.Exprs[len(e.Exprs)-1], replace)
+	}
+
+	return false
+}
+
+// rewriteTailChild is rewriteTail for an expression which may itself be the self call
+func rewriteTailChild(f *ast.Function, expr *ast.Expression, replace bool) bool {
+	fc, ok := (*expr).(*ast.FunctionCall)
+	if !ok {
+		return rewriteTail(f, *expr, replace)
+	}
+
+	if fc.Name != f.Name.Name || len(fc.Params) != len(f.Params) {
+		return false
+	}
+
+	if replace {
+		t := token.Token{Type: token.RECUR, Literal: "recur", Line: fc.Token.Line, Column: fc.Token.Column}
+		*expr = &ast.Recur{Token: t, Args: fc.Params}
+	}
+
+	return true
+}
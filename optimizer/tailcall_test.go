@@ -0,0 +1,93 @@
+package optimizer
+
+import (
+	"testing"
+
+	"github.com/simplang/ast"
+	"github.com/simplang/interpreter"
+	"github.com/simplang/lexer"
+	"github.com/simplang/parser"
+)
+
+func TestTailCalls(t *testing.T) {
+	tests := []struct {
+		input     string
+		rewritten bool
+		expected  string
+	}{
+		{
+			"let f x n = if x < 10 then n + 1 else f (x * 0) (n + 1) end end",
+			true,
+			"(loop (x x) (n n) (if (< x 10) (+ n 1) (recur (* x 0) (+ n 1))))",
+		},
+		{
+			"let f x = let y = x + -1 in if y < 0 then 0 else f (y) end end end",
+			true,
+			"(loop (x x) (let (y (+ x (- 1))) (if (< y 0) 0 (recur y))))",
+		},
+		{
+			"let f x = if x < 0 then f (0 - x) elif x == 0 then 0 else f (x - 1) end end",
+			true,
+			"(loop (x x) (cond ((< x 0) (recur (- 0 x))) ((== x 0) 0) (recur (- x 1))))",
+		},
+		{
+			"let f x = if x == 0 then 64 else 1 + f (x * 2) end end",
+			false,
+			"(if (== x 0) 64 (+ 1 (f (* x 2))))",
+		},
+		{
+			"let f x = if x == 0 then 0 else g (f (x)) end end",
+			false,
+			"(if (== x 0) 0 (g (f x)))",
+		},
+		{
+			"let f x = loop i = x in if i < 0 then f (i) else recur (i + -1) end end end",
+			false,
+			"(loop (i x) (if (< i 0) (f i) (recur (+ i (- 1)))))",
+		},
+		{
+			"let pow x n = if n == 0 then 1 else pow (x) (n - 1) end end",
+			false,
+			"(if (== n 0) 1 (pow x (- n 1)))",
+		},
+	}
+
+	for i, tt := range tests {
+		p := parser.New(lexer.New(tt.input))
+		prog := p.ParseProgram()
+		if len(p.Errors()) != 0 {
+			t.Fatalf("tests[%d] - parser errors: %v", i, p.Errors())
+		}
+
+		if rewritten := tailCalls(prog.Functions[0]); rewritten != tt.rewritten {
+			t.Fatalf("tests[%d] - expected rewritten=%v, got=%v", i, tt.rewritten, rewritten)
+		}
+
+		if got := sexpr(prog.Functions[0].Body); got != tt.expected {
+			t.Fatalf("tests[%d] - wrong body. expected=%s, got=%s", i, tt.expected, got)
+		}
+	}
+}
+
+func TestTailCallsDeepRecursion(t *testing.T) {
+	p := parser.New(lexer.New(`let numdigits x n = if x < 10 then n + 1 else numdigits (div (x) (10)) (n + 1) end end
+let div x y = loop q = 0 and r = x in if r < y then q else recur (q + 1) (r + -y) end end end
+let count i acc = if i == 0 then acc else count (i + -1) (acc + 2) end end
+let main x = numdigits (x) (0) + count (1000000) (0) end`))
+	prog := p.ParseProgram()
+	if len(p.Errors()) != 0 {
+		t.Fatalf("parser errors: %v", p.Errors())
+	}
+
+	TailCalls(prog)
+
+	for _, f := range prog.Functions[:3] {
+		if _, ok := f.Body.(*ast.LoopExpression); !ok {
+			t.Fatalf("%s was not rewritten into a loop", f.Name.Name)
+		}
+	}
+
+	if got := interpreter.Interprete(prog, []int64{123456}); got != 2000006 {
+		t.Fatalf("wrong result. expected=2000006, got=%d", got)
+	}
+}
@@ -2,8 +2,9 @@ package parser
 
 import (
 	"fmt"
-	"strconv"
+	"math/big"
 
+	"github.com/simplang/arith"
 	"github.com/simplang/ast"
 	"github.com/simplang/lexer"
 	"github.com/simplang/token"
@@ -15,10 +16,22 @@ type Parser struct {
 	curToken  token.Token
 	peekToken token.Token
 	errors    []string
+	options   Options
+}
+
+// Options change which programs the parser accepts
+type Options struct {
+	BigIntegers bool      // integer literals of any size, the ones not fitting into 64 bits are stored in Big
+	Int         arith.Int // the type integer literals have to fit into, ignored with BigIntegers
 }
 
 func New(l *lexer.Lexer) *Parser {
-	p := &Parser{l: l, errors: []string{}}
+	return NewWithOptions(l, Options{})
+}
+
+// NewWithOptions returns a parser accepting the programs allowed by opts
+func NewWithOptions(l *lexer.Lexer, opts Options) *Parser {
+	p := &Parser{l: l, errors: []string{}, options: opts}
 
 	// read two tokens so curToken and peekToken is set
 	p.nextToken()
@@ -30,6 +43,16 @@ func New(l *lexer.Lexer) *Parser {
 func (p *Parser) ParseProgram() *ast.Program {
 	program := &ast.Program{Token: p.curToken}
 
+	for p.curToken.Type == token.IMPORT {
+		imp := p.parseImport()
+		if imp == nil {
+			return nil
+		}
+
+		program.Imports = append(program.Imports, imp)
+		p.nextToken()
+	}
+
 	if p.curToken.Type != token.LET {
 		p.errors = append(p.errors, fmt.Sprintf("function has to start with 'let' (line %d.%d)", p.curToken.Line, p.curToken.Column))
 		return nil
@@ -42,17 +65,54 @@ func (p *Parser) ParseProgram() *ast.Program {
 			return nil
 		}
 
-		program.Functions = append(program.Functions, p.parseFunction())
+		f := p.parseFunction()
+		last := program.Functions[len(program.Functions)-1]
+
+		// consecutive definitions of the same function are its clauses
+		if f != nil && last != nil && f.Name.Name == last.Name.Name {
+			p.mergeClauses(last, f)
+			continue
+		}
+
+		program.Functions = append(program.Functions, f)
 	}
 
 	return program
 }
 
+// "import" string
+func (p *Parser) parseImport() *ast.Import {
+	imp := &ast.Import{Token: p.curToken}
+
+	if !p.expectPeek(token.STRING) {
+		return nil
+	}
+
+	path, err := lexer.ParseString(p.curToken.Literal)
+	if err != nil {
+		p.errors = append(p.errors, fmt.Sprintf("%s (line %d.%d)", err.Error(), p.curToken.Line, p.curToken.Column))
+		return nil
+	}
+
+	if path == "" {
+		p.errors = append(p.errors, fmt.Sprintf("import path is empty (line %d.%d)", p.curToken.Line, p.curToken.Column))
+		return nil
+	}
+
+	imp.Path = path
+	return imp
+}
+
 func (p *Parser) Errors() []string {
 	return p.errors
 }
 
+// peekError reports that the next token isn't of type t. Illegal tokens aren't
+// reported again, the lexer already did.
 func (p *Parser) peekError(t token.TokenType) {
+	if p.peekToken.Type == token.ILLEGAL {
+		return
+	}
 	msg := fmt.Sprintf("expected next token to be %s, got %s instead (line %d.%d)", t, p.peekToken.Type, p.peekToken.Line, p.peekToken.Column)
 	p.errors = append(p.errors, msg)
 }
@@ -81,6 +141,8 @@ func (p *Parser) nextToken() {
 	}
 }
 
+// "let" ident {pattern} "=" expr "end"
+// a function without parameters is a constant
 func (p *Parser) parseFunction() *ast.Function {
 	f := &ast.Function{Token: p.curToken}
 
@@ -90,15 +152,20 @@ func (p *Parser) parseFunction() *ast.Function {
 
 	f.Name = &ast.Ident{Token: p.curToken, Name: p.curToken.Literal}
 
-	if !p.expectPeek(token.IDENT) {
-		return nil
-	}
+	clause := &ast.Clause{Token: f.Token}
+	isPattern := false
 
-	f.Params = []*ast.Ident{&ast.Ident{Token: p.curToken, Name: p.curToken.Literal}}
+	for !p.peekTokenIs(token.ASSIGN) {
+		pattern := p.parsePattern()
+		if pattern == nil {
+			return nil
+		}
 
-	for p.peekTokenIs(token.IDENT) {
-		p.nextToken()
-		f.Params = append(f.Params, &ast.Ident{Token: p.curToken, Name: p.curToken.Literal})
+		if _, ok := pattern.(*ast.Integer); ok {
+			isPattern = true
+		}
+
+		clause.Patterns = append(clause.Patterns, pattern)
 	}
 
 	if !p.expectPeek(token.ASSIGN) {
@@ -106,31 +173,158 @@ func (p *Parser) parseFunction() *ast.Function {
 	}
 
 	p.nextToken()
-	f.Body = p.parseExpression()
+	clause.Body = p.parseExpression()
 
 	if !p.expectPeek(token.END) {
 		return nil
 	}
 
+	if isPattern {
+		f.Clauses = []*ast.Clause{clause}
+		f.Params = clauseParams(f.Clauses)
+		return f
+	}
+
+	f.Params = make([]*ast.Ident, len(clause.Patterns))
+	for i, pattern := range clause.Patterns {
+		f.Params[i] = pattern.(*ast.Ident)
+	}
+	f.Body = clause.Body
+
 	return f
 }
 
-// if precedence is not set, we know we're not inside a binary expression
+// pattern = ident | integer | "-" integer | character
+func (p *Parser) parsePattern() ast.Expression {
+	p.nextToken()
+
+	switch p.curToken.Type {
+	case token.IDENT:
+		return &ast.Ident{Token: p.curToken, Name: p.curToken.Literal}
+
+	case token.INT, token.CHAR:
+		if i := p.parseInteger(false); i != nil {
+			return i
+		}
+
+	case token.MINUS:
+		t := p.curToken
+		if !p.expectPeek(token.INT) {
+			return nil
+		}
+
+		if i := p.parseInteger(true); i != nil {
+			if n := p.negative(t, i); n != nil {
+				return n
+			}
+		}
+
+	case token.ILLEGAL:
+		// reported by the lexer
+
+	default:
+		p.errors = append(p.errors, fmt.Sprintf("expected a parameter name or an integer, got %s instead (line %d.%d)", p.curToken.Type, p.curToken.Line, p.curToken.Column))
+	}
+
+	return nil
+}
+
+// mergeClauses adds the clauses of next to f. Clauses without any integer
+// patterns are a function defined twice, the second one could never be chosen.
+func (p *Parser) mergeClauses(f *ast.Function, next *ast.Function) {
+	clauses := append(functionClauses(f), functionClauses(next)...)
+
+	if !hasIntegerPattern(clauses) {
+		p.errors = append(p.errors, fmt.Sprintf("function '%s' is defined twice, first at line %d.%d (line %d.%d)", f.Name.Name, f.Token.Line, f.Token.Column, next.Token.Line, next.Token.Column))
+		return
+	}
+
+	for _, c := range clauses[1:] {
+		if len(c.Patterns) != len(clauses[0].Patterns) {
+			p.errors = append(p.errors, fmt.Sprintf("clause of function '%s' has %d parameters, expected %d (line %d.%d)", f.Name.Name, len(c.Patterns), len(clauses[0].Patterns), c.Token.Line, c.Token.Column))
+			return
+		}
+	}
+
+	f.Clauses = clauses
+	f.Params = clauseParams(clauses)
+	f.Body = nil
+}
+
+func hasIntegerPattern(clauses []*ast.Clause) bool {
+	for _, c := range clauses {
+		for _, pattern := range c.Patterns {
+			if _, ok := pattern.(*ast.Integer); ok {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// functionClauses returns the clauses of f. A function without clauses is a single clause.
+func functionClauses(f *ast.Function) []*ast.Clause {
+	if len(f.Clauses) != 0 {
+		return f.Clauses
+	}
+
+	patterns := make([]ast.Expression, len(f.Params))
+	for i, param := range f.Params {
+		patterns[i] = param
+	}
+
+	return []*ast.Clause{{Token: f.Token, Patterns: patterns, Body: f.Body}}
+}
+
+// clauseParams names the parameters of a function defined by clauses after the
+// first clause binding the argument to a name, or arg1, arg2, ... if there is none
+func clauseParams(clauses []*ast.Clause) []*ast.Ident {
+	params := make([]*ast.Ident, len(clauses[0].Patterns))
+
+	for i := range params {
+		for _, c := range clauses {
+			if ident, ok := c.Patterns[i].(*ast.Ident); ok {
+				params[i] = &ast.Ident{Token: ident.Token, Name: ident.Name}
+				break
+			}
+		}
+
+		if params[i] == nil {
+			t := clauses[0].Patterns[i].(*ast.Integer).Token
+			name := fmt.Sprintf("arg%d", i+1)
+			params[i] = &ast.Ident{Token: token.Token{Type: token.IDENT, Literal: name, Line: t.Line, Column: t.Column}, Name: name}
+		}
+	}
+
+	return params
+}
+
+// if precedence is not set, we know we're not inside a binary expression.
+// Otherwise only binary operators binding tighter than precedence are consumed.
 func (p *Parser) parseExpression(precedence ...int) ast.Expression {
 	var expr ast.Expression
 
 	switch p.curToken.Type {
-	case token.INT:
-		expr = p.parseInteger()
+	case token.INT, token.CHAR:
+		expr = p.parseInteger(false)
+
+	case token.TRUE, token.FALSE:
+		expr = &ast.Boolean{Token: p.curToken, Value: p.curToken.Type == token.TRUE}
+
+	case token.STRING:
+		expr = p.parseString()
 
 	case token.IF:
 		expr = p.parseIf()
 
-	case token.NOT, token.MINUS:
+	case token.NOT, token.MINUS, token.BIT_NOT:
 		expr = p.parseUnary()
 
 	case token.LPAREN:
-		expr = p.parseLParen()
+		expr = p.parseCallExpression(p.parseLParen())
+
+	case token.FN:
+		expr = p.parseCallExpression(p.parseLambda())
 
 	case token.LET, token.LOOP:
 		expr = p.parseLet()
@@ -145,45 +339,189 @@ func (p *Parser) parseExpression(precedence ...int) ast.Expression {
 	case token.RECUR:
 		expr = p.parseRecur()
 
+	case token.PRINT:
+		expr = p.parsePrint()
+
+	case token.LBRACKET:
+		expr = p.parseArrayLiteral()
+
+	case token.ARRAY:
+		expr = p.parseArrayExpression()
+
+	case token.LEN:
+		expr = p.parseLength()
+
+	case token.ILLEGAL:
+		// reported by the lexer
+		return nil
+
 	default:
 		p.errors = append(p.errors, fmt.Sprintf("parser encountered an unexpected token type: %s (line %d.%d)", p.curToken.Type, p.curToken.Line, p.curToken.Column))
 		return nil
 	}
 
-	if token.IsBinaryOperator(p.peekToken.Type) {
+	for expr != nil && p.peekTokenIs(token.LBRACKET) {
+		p.nextToken()
+		expr = p.parseIndex(expr)
+	}
+
+	// if we don't have a precedence set as a parameter, then we're at the top of the "calculation tree"
+	// from there we need to continue eating all the operators until we're at the end
+	lowest := token.PREC_LOWEST
+	if len(precedence) != 0 {
+		lowest = precedence[0]
+	}
 
-		// if we don't have a precedence set as a parameter, then we're at the top of the "calculation tree"
-		// from there we need to continue eating all the operators until we're at the end
-		if len(precedence) == 0 {
-			for token.IsBinaryOperator(p.peekToken.Type) {
-				p.nextToken()
-				expr = p.parseBinaryOperator(expr)
-			}
-		} else if token.GetPrecedence(p.peekToken.Type) > precedence[0] {
-			p.nextToken()
-			expr = p.parseBinaryOperator(expr)
-		}
+	// operators of the same precedence are left associative: a - b - c = (a - b) - c,
+	// since the right operand only consumes operators that bind tighter
+	for token.IsBinaryOperator(p.peekToken.Type) && token.GetPrecedence(p.peekToken.Type) > lowest {
+		p.nextToken()
+		expr = p.parseBinaryOperator(expr)
+	}
+
+	// a sequence binds looser than every operator, so it only continues a
+	// complete expression
+	if len(precedence) == 0 && p.peekTokenIs(token.SEMICOLON) {
+		return p.parseSequence(expr)
 	}
 
 	return expr
 }
 
-// expr = integer
-func (p *Parser) parseInteger() *ast.Integer {
+// expr = expr ";" expr {";" expr}
+func (p *Parser) parseSequence(first ast.Expression) *ast.Sequence {
+	seq := &ast.Sequence{Token: p.peekToken, Exprs: []ast.Expression{first}}
+
+	for p.peekTokenIs(token.SEMICOLON) {
+		p.nextToken()
+		p.nextToken()
+		seq.Exprs = append(seq.Exprs, p.parseExpression(token.PREC_LOWEST))
+	}
+
+	return seq
+}
+
+// expr = integer | character
+// if negated is set, the integer is the operand of a unary minus and may be the
+// negation of the smallest value of a signed type, like 9223372036854775808, which
+// is stored as that value. Only negative is able to turn it into a literal.
+func (p *Parser) parseInteger(negated bool) *ast.Integer {
 	i := &ast.Integer{Token: p.curToken}
-	val, err := strconv.ParseInt(p.curToken.Literal, 10, 64)
+
+	if p.curToken.Type == token.CHAR {
+		val, err := lexer.ParseChar(p.curToken.Literal)
+		if err == nil && !p.options.BigIntegers && !p.options.Int.Is64() && p.options.Int.Wrap(val) != val {
+			err = fmt.Errorf("character literal %s does not fit into %s", p.curToken.Literal, p.options.Int)
+		}
+		if err != nil {
+			p.errors = append(p.errors, fmt.Sprintf("%s (line %d.%d)", err.Error(), p.curToken.Line, p.curToken.Column))
+			return nil
+		}
+
+		i.Value = val
+		return i
+	}
+
+	if p.options.BigIntegers {
+		val, err := lexer.ParseBigInteger(p.curToken.Literal)
+		if err != nil {
+			p.errors = append(p.errors, fmt.Sprintf("%s (line %d.%d)", err.Error(), p.curToken.Line, p.curToken.Column))
+			return nil
+		}
+
+		if val.IsInt64() {
+			i.Value = val.Int64()
+		} else {
+			i.Big = val
+		}
+		return i
+	}
+
+	if !p.options.Int.Is64() {
+		return p.parseTypedInteger(i, negated)
+	}
+
+	val, err := lexer.ParseInteger(p.curToken.Literal)
+	if err == nil && val == 1<<63 && !negated {
+		err = fmt.Errorf("integer literal %s does not fit into 64 bits, only -%s does", p.curToken.Literal, p.curToken.Literal)
+	}
+
+	if err != nil {
+		p.errors = append(p.errors, fmt.Sprintf("%s (line %d.%d)", err.Error(), p.curToken.Line, p.curToken.Column))
+		return nil
+	}
+
+	i.Value = int64(val)
+	return i
+}
+
+// parseTypedInteger sets the value of the integer literal i of a type other than
+// int64, see parseInteger
+func (p *Parser) parseTypedInteger(i *ast.Integer, negated bool) *ast.Integer {
+	t := p.options.Int
+
+	val, err := lexer.ParseBigInteger(p.curToken.Literal)
+	if err == nil {
+		var ok bool
+		if i.Value, ok = t.FromBig(val); !ok && negated {
+			i.Value, ok = t.FromBig(new(big.Int).Neg(val))
+		}
+		if !ok {
+			err = fmt.Errorf("integer literal %s does not fit into %s", p.curToken.Literal, t)
+		}
+	}
 
 	if err != nil {
-		p.errors = append(p.errors, fmt.Sprintf("Parser could not convert string to int. Value=%s, Error message=%s (line %d.%d)", p.curToken.Literal, err.Error(), p.curToken.Line, p.curToken.Column))
+		p.errors = append(p.errors, fmt.Sprintf("%s (line %d.%d)", err.Error(), p.curToken.Line, p.curToken.Column))
 		return nil
 	}
 
-	i.Value = val
 	return i
 }
 
+// negative returns the literal -i, i is the integer after the minus at t
+func (p *Parser) negative(t token.Token, i *ast.Integer) *ast.Integer {
+	t.Type = token.INT
+	t.Literal += i.Token.Literal
+
+	if i.Big != nil {
+		n := new(big.Int).Neg(i.Big)
+		if n.IsInt64() {
+			return &ast.Integer{Token: t, Value: n.Int64()}
+		}
+		return &ast.Integer{Token: t, Big: n}
+	}
+
+	switch {
+	case p.options.BigIntegers:
+		return &ast.Integer{Token: t, Value: -i.Value}
+
+	// the smallest value of a signed type is stored negated already
+	case !p.options.Int.Unsigned && i.Value < 0:
+		return &ast.Integer{Token: t, Value: i.Value}
+
+	case p.options.Int.Unsigned && i.Value != 0:
+		p.errors = append(p.errors, fmt.Sprintf("integer literal %s does not fit into %s (line %d.%d)", t.Literal, p.options.Int, t.Line, t.Column))
+		return nil
+	}
+
+	return &ast.Integer{Token: t, Value: -i.Value}
+}
+
+// expr = string
+func (p *Parser) parseString() *ast.String {
+	val, err := lexer.ParseString(p.curToken.Literal)
+	if err != nil {
+		p.errors = append(p.errors, fmt.Sprintf("%s (line %d.%d)", err.Error(), p.curToken.Line, p.curToken.Column))
+		return nil
+	}
+
+	return &ast.String{Token: p.curToken, Value: val}
+}
+
 // expr = "if" expr "then" expr "else" expr "end"
-func (p *Parser) parseIf() *ast.IfExpression {
+// expr = "if" expr "then" expr {"elif" expr "then" expr}+ "else" expr "end"
+func (p *Parser) parseIf() ast.Expression {
 	ifexpr := &ast.IfExpression{Token: p.curToken}
 
 	p.nextToken()
@@ -196,6 +534,10 @@ func (p *Parser) parseIf() *ast.IfExpression {
 	p.nextToken()
 	ifexpr.Consequence = p.parseExpression()
 
+	if p.peekTokenIs(token.ELIF) {
+		return p.parseCond(ifexpr)
+	}
+
 	if !p.expectPeek(token.ELSE) {
 		return nil
 	}
@@ -210,27 +552,79 @@ func (p *Parser) parseIf() *ast.IfExpression {
 	return ifexpr
 }
 
+// continues parsing an if expression at its first "elif"
+func (p *Parser) parseCond(first *ast.IfExpression) *ast.CondExpression {
+	cond := &ast.CondExpression{Token: first.Token}
+	cond.Branches = []*ast.CondBranch{{Token: first.Token, Condition: first.Condition, Consequence: first.Consequence}}
+
+	for p.peekTokenIs(token.ELIF) {
+		p.nextToken()
+		branch := &ast.CondBranch{Token: p.curToken}
+
+		p.nextToken()
+		branch.Condition = p.parseExpression()
+
+		if !p.expectPeek(token.THEN) {
+			return nil
+		}
+
+		p.nextToken()
+		branch.Consequence = p.parseExpression()
+		cond.Branches = append(cond.Branches, branch)
+	}
+
+	if !p.expectPeek(token.ELSE) {
+		return nil
+	}
+
+	p.nextToken()
+	cond.Alternative = p.parseExpression()
+
+	if !p.expectPeek(token.END) {
+		return nil
+	}
+
+	return cond
+}
+
 // expr = unop expr
-// unop = "!" | "-"
-func (p *Parser) parseUnary() *ast.UnaryExpression {
+// unop = "!" | "-" | "~"
+// the operand doesn't contain binary operators, so -a + b = (-a) + b
+func (p *Parser) parseUnary() ast.Expression {
 	unexpr := &ast.UnaryExpression{Token: p.curToken}
 
 	unexpr.Operator = p.curToken.Type
 	p.nextToken()
 
-	unexpr.Operand = p.parseExpression()
+	if unexpr.Operator == token.MINUS && p.curToken.Type == token.INT {
+		// an integer doesn't consume any binary operators either
+		i := p.parseInteger(true)
+
+		// the smallest value of a signed type is a literal, negating its
+		// negation would overflow in checked mode
+		if i != nil && i.Value < 0 && !p.options.Int.Unsigned {
+			return p.negative(unexpr.Token, i)
+		}
+		unexpr.Operand = i
+	} else {
+		unexpr.Operand = p.parseExpression(token.PREC_PREFIX)
+	}
 
 	return unexpr
 }
 
 // expr = "(" expr binop expr ")"
-// binop = "&&" | "||" | "<" | "==" | "+" | "*"
+// binop = "&&" | "||" | "<" | ">" | "<=" | ">=" | "==" | "!=" | "|" | "^" | "&" |
+// "<<" | ">>" | ">>>" | "+" | "-" | "*" | "/" | "%"
 func (p *Parser) parseBinaryOperator(left ast.Expression) *ast.BinaryExpression {
 	biexpr := &ast.BinaryExpression{Token: p.curToken, Operator: p.curToken.Type, Left: left}
 
 	// expect binary op
 	if !token.IsBinaryOperator(p.curToken.Type) {
-		p.errors = append(p.errors, fmt.Sprintf("expected token to be a binary operator (+ * && || == <), instead got=%s (line %d.%d)", p.curToken.Type, p.curToken.Line, p.curToken.Column))
+		if p.curToken.Type == token.ILLEGAL {
+			return nil
+		}
+		p.errors = append(p.errors, fmt.Sprintf("expected token to be a binary operator, instead got=%s (line %d.%d)", p.curToken.Type, p.curToken.Line, p.curToken.Column))
 		return nil
 	}
 
@@ -239,10 +633,6 @@ func (p *Parser) parseBinaryOperator(left ast.Expression) *ast.BinaryExpression
 	p.nextToken()
 	biexpr.Right = p.parseExpression(precedence)
 
-	if token.IsBinaryOperator(p.curToken.Type) && token.GetPrecedence(p.curToken.Type) > precedence {
-		biexpr = p.parseBinaryOperator(biexpr)
-	}
-
 	return biexpr
 }
 
@@ -262,6 +652,52 @@ func (p *Parser) parseLParen() ast.Expression {
 	return e
 }
 
+// expr = "fn" ident {ident} "->" expr "end"
+func (p *Parser) parseLambda() ast.Expression {
+	l := &ast.Lambda{Token: p.curToken}
+
+	if !p.expectPeek(token.IDENT) {
+		return nil
+	}
+
+	l.Params = []*ast.Ident{{Token: p.curToken, Name: p.curToken.Literal}}
+	for p.peekTokenIs(token.IDENT) {
+		p.nextToken()
+		l.Params = append(l.Params, &ast.Ident{Token: p.curToken, Name: p.curToken.Literal})
+	}
+
+	if !p.expectPeek(token.ARROW) {
+		return nil
+	}
+
+	p.nextToken()
+	l.Body = p.parseExpression()
+
+	if !p.expectPeek(token.END) {
+		return nil
+	}
+
+	return l
+}
+
+// expr = function arg {arg}
+// function = "(" expr ")" | lambda
+// If no argument follows, function is returned as it is.
+func (p *Parser) parseCallExpression(function ast.Expression) ast.Expression {
+	if function == nil || !p.peekTokenIs(token.LPAREN) {
+		return function
+	}
+
+	ce := &ast.CallExpression{Token: p.peekToken, Function: function}
+
+	for p.peekTokenIs(token.LPAREN) {
+		p.nextToken()
+		ce.Params = append(ce.Params, p.parseLParen())
+	}
+
+	return ce
+}
+
 func (p *Parser) parseFunctionCall() *ast.FunctionCall {
 	fc := &ast.FunctionCall{Token: p.curToken, Name: p.curToken.Literal}
 
@@ -290,10 +726,12 @@ func (p *Parser) parseLet() ast.Expression {
 
 	bind := []*ast.Binding{}
 
-	bind = append(bind, p.parseBinding())
+	local := t.Type == token.LET
+
+	bind = append(bind, p.parseBinding(local))
 	for p.peekToken.Type == token.AND {
 		p.nextToken()
-		bind = append(bind, p.parseBinding())
+		bind = append(bind, p.parseBinding(local))
 	}
 
 	if !p.expectPeek(token.IN) {
@@ -314,7 +752,9 @@ func (p *Parser) parseLet() ast.Expression {
 }
 
 // ident "=" expr
-func (p *Parser) parseBinding() *ast.Binding {
+// ident {ident} "=" expr
+// parameters define a local function, which is only allowed if local is set
+func (p *Parser) parseBinding(local bool) *ast.Binding {
 	b := &ast.Binding{Token: p.curToken}
 
 	if !p.expectPeek(token.IDENT) {
@@ -323,15 +763,120 @@ func (p *Parser) parseBinding() *ast.Binding {
 
 	b.Ident = &ast.Ident{Token: p.curToken, Name: p.curToken.Literal}
 
+	params := []*ast.Ident{}
+	for p.peekTokenIs(token.IDENT) {
+		p.nextToken()
+		params = append(params, &ast.Ident{Token: p.curToken, Name: p.curToken.Literal})
+	}
+
+	if len(params) != 0 && !local {
+		p.errors = append(p.errors, fmt.Sprintf("loop cannot bind the function '%s' (line %d.%d)", b.Ident.Name, b.Ident.Token.Line, b.Ident.Token.Column))
+		return nil
+	}
+
 	if !p.expectPeek(token.ASSIGN) {
 		return nil
 	}
 
 	p.nextToken()
 	b.Expr = p.parseExpression()
+
+	if len(params) != 0 {
+		name := *b.Ident
+		b.Expr = &ast.Function{Token: b.Token, Name: &name, Params: params, Body: b.Expr}
+	}
+
 	return b
 }
 
+// expr = "[" [expr {"," expr}] "]"
+func (p *Parser) parseArrayLiteral() *ast.ArrayLiteral {
+	al := &ast.ArrayLiteral{Token: p.curToken, Elements: []ast.Expression{}}
+
+	if p.peekTokenIs(token.RBRACKET) {
+		p.nextToken()
+		return al
+	}
+
+	for {
+		p.nextToken()
+		al.Elements = append(al.Elements, p.parseExpression())
+
+		if !p.peekTokenIs(token.COMMA) {
+			break
+		}
+		p.nextToken()
+	}
+
+	if !p.expectPeek(token.RBRACKET) {
+		return nil
+	}
+
+	return al
+}
+
+// expr = expr "[" expr "]"
+// an index binds tighter than every operator, so -a[i] = -(a[i])
+func (p *Parser) parseIndex(array ast.Expression) *ast.IndexExpression {
+	ie := &ast.IndexExpression{Token: p.curToken, Array: array}
+
+	p.nextToken()
+	ie.Index = p.parseExpression()
+
+	if !p.expectPeek(token.RBRACKET) {
+		return nil
+	}
+
+	return ie
+}
+
+// expr = "array" arg arg
+func (p *Parser) parseArrayExpression() *ast.ArrayExpression {
+	ae := &ast.ArrayExpression{Token: p.curToken}
+
+	if !p.expectPeek(token.LPAREN) {
+		return nil
+	}
+	ae.Length = p.parseLParen()
+
+	if !p.expectPeek(token.LPAREN) {
+		return nil
+	}
+	ae.Function = p.parseLParen()
+
+	return ae
+}
+
+// expr = "len" arg
+func (p *Parser) parseLength() *ast.LengthExpression {
+	le := &ast.LengthExpression{Token: p.curToken}
+
+	if !p.expectPeek(token.LPAREN) {
+		return nil
+	}
+	le.Array = p.parseLParen()
+
+	return le
+}
+
+// expr = "print" arg {arg}
+func (p *Parser) parsePrint() *ast.PrintExpression {
+	pe := &ast.PrintExpression{Token: p.curToken}
+
+	if !p.expectPeek(token.LPAREN) {
+		return nil
+	}
+
+	pe.Args = []ast.Expression{p.parseLParen()}
+
+	for p.peekTokenIs(token.LPAREN) {
+		p.nextToken()
+		pe.Args = append(pe.Args, p.parseLParen())
+	}
+
+	return pe
+}
+
 // "recur" arg {arg}
 func (p *Parser) parseRecur() *ast.Recur {
 	rec := &ast.Recur{Token: p.curToken, Args: []ast.Expression{}}
@@ -0,0 +1,418 @@
+package parser
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/simplang/arith"
+	"github.com/simplang/ast"
+	"github.com/simplang/lexer"
+)
+
+func TestOperatorPrecedence(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"a + b * c * d", "(a + ((b * c) * d))"},
+		{"a - b - c", "((a - b) - c)"},
+		{"a < b + c + d", "(a < ((b + c) + d))"},
+		{"a + -b + c", "((a + (-b)) + c)"},
+		{"-a + b", "((-a) + b)"},
+		{"a + -b + -c", "((a + (-b)) + (-c))"},
+		{"a + -(b * c) + d", "((a + (-(b * c))) + d)"},
+		{"-a - b", "((-a) - b)"},
+		{"a - -1", "(a - (-1))"},
+		{"!a == b && c != d", "(((!a) == b) && (c != d))"},
+		{"a <= b || a >= b", "((a <= b) || (a >= b))"},
+		{"!(a > b)", "(!(a > b))"},
+		{"f (a - 1) - 1", "(f(a - 1) - 1)"},
+		{"a & 1 == 0", "((a & 1) == 0)"},
+		{"a | b ^ c & d", "(a | (b ^ (c & d)))"},
+		{"a << 1 + b", "(a << (1 + b))"},
+		{"a >> b >>> c & d", "(((a >> b) >>> c) & d)"},
+		{"~a & b", "((~a) & b)"},
+		{"!true || a == false", "((!true) || (a == false))"},
+		{"-a[0] + b[c + 1]", "((-a[0]) + b[(c + 1)])"},
+		{"[a, b * c][d][0]", "[a, (b * c)][d][0]"},
+		{"len (a) * len ([])", "(len(a) * len([]))"},
+		{"print (a) (\"b\"); c + 1; f (d; e)", "print(a, \"b\"); (c + 1); fd; e"},
+	}
+
+	for i, tt := range tests {
+		p := New(lexer.New("let main a b c d = " + tt.input + " end"))
+		prog := p.ParseProgram()
+		if len(p.Errors()) != 0 {
+			t.Fatalf("tests[%d] - parser errors: %v", i, p.Errors())
+		}
+
+		if got := infix(prog.Functions[0].Body); got != tt.expected {
+			t.Fatalf("tests[%d] - %q parsed wrong. expected=%s, got=%s", i, tt.input, tt.expected, got)
+		}
+	}
+}
+
+func infix(expr ast.Expression) string {
+	switch e := expr.(type) {
+	case *ast.Integer:
+		return e.BigValue().String()
+	case *ast.Boolean:
+		return fmt.Sprint(e.Value)
+	case *ast.Ident:
+		return e.Name
+	case *ast.UnaryExpression:
+		return fmt.Sprintf("(%s%s)", e.Operator, infix(e.Operand))
+	case *ast.BinaryExpression:
+		return fmt.Sprintf("(%s %s %s)", infix(e.Left), e.Operator, infix(e.Right))
+	case *ast.FunctionCall:
+		s := e.Name
+		for _, p := range e.Params {
+			s += infix(p)
+		}
+		return s
+	case *ast.ArrayLiteral:
+		elements := make([]string, len(e.Elements))
+		for i, el := range e.Elements {
+			elements[i] = infix(el)
+		}
+		return "[" + strings.Join(elements, ", ") + "]"
+	case *ast.IndexExpression:
+		return fmt.Sprintf("%s[%s]", infix(e.Array), infix(e.Index))
+	case *ast.LengthExpression:
+		return fmt.Sprintf("len(%s)", infix(e.Array))
+	case *ast.String:
+		return fmt.Sprintf("%q", e.Value)
+	case *ast.PrintExpression:
+		args := make([]string, len(e.Args))
+		for i, a := range e.Args {
+			args[i] = infix(a)
+		}
+		return "print(" + strings.Join(args, ", ") + ")"
+	case *ast.Sequence:
+		exprs := make([]string, len(e.Exprs))
+		for i, expr := range e.Exprs {
+			exprs[i] = infix(expr)
+		}
+		return strings.Join(exprs, "; ")
+	}
+
+	return fmt.Sprintf("%T", expr)
+}
+
+func TestIntegerLiterals(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"-9223372036854775808", "-9223372036854775808"},
+		{"-9223372036854775808 + 1", "(-9223372036854775808 + 1)"},
+		{"0xff + 'a'", "(255 + 97)"},
+		{"-0b1", "(-1)"},
+	}
+
+	for i, tt := range tests {
+		p := New(lexer.New("let main a = " + tt.input + " end"))
+		prog := p.ParseProgram()
+		if len(p.Errors()) != 0 {
+			t.Fatalf("tests[%d] - parser errors: %v", i, p.Errors())
+		}
+
+		if got := infix(prog.Functions[0].Body); got != tt.expected {
+			t.Fatalf("tests[%d] - %q parsed wrong. expected=%s, got=%s", i, tt.input, tt.expected, got)
+		}
+	}
+
+	p := New(lexer.New("let main a = 9223372036854775808 end"))
+	p.ParseProgram()
+	if len(p.Errors()) != 1 {
+		t.Fatalf("expected 1 error for 9223372036854775808, got %v", p.Errors())
+	}
+}
+
+func TestBigIntegerLiterals(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"9223372036854775808", "9223372036854775808"},
+		{"-9223372036854775808", "(-9223372036854775808)"},
+		{"0x1_0000_0000_0000_0000 * 2", "(18446744073709551616 * 2)"},
+	}
+
+	for i, tt := range tests {
+		p := NewWithOptions(lexer.New("let main a = "+tt.input+" end"), Options{BigIntegers: true})
+		prog := p.ParseProgram()
+		if len(p.Errors()) != 0 {
+			t.Fatalf("tests[%d] - parser errors: %v", i, p.Errors())
+		}
+
+		if got := infix(prog.Functions[0].Body); got != tt.expected {
+			t.Fatalf("tests[%d] - %q parsed wrong. expected=%s, got=%s", i, tt.input, tt.expected, got)
+		}
+	}
+
+	p := NewWithOptions(lexer.New("let f -18446744073709551616 = 1 end let f x = x end"), Options{BigIntegers: true})
+	prog := p.ParseProgram()
+	if len(p.Errors()) != 0 {
+		t.Fatalf("parser errors: %v", p.Errors())
+	}
+	if pattern, ok := prog.Functions[0].Clauses[0].Patterns[0].(*ast.Integer); !ok || pattern.BigValue().String() != "-18446744073709551616" {
+		t.Fatalf("pattern wrong, got %#v", prog.Functions[0].Clauses[0].Patterns[0])
+	}
+
+	p = New(lexer.New("let main a = 0x1_0000_0000_0000_0000 end"))
+	p.ParseProgram()
+	if len(p.Errors()) != 1 || p.Errors()[0] != "integer literal 0x1_0000_0000_0000_0000 does not fit into 64 bits (line 1.13)" {
+		t.Fatalf("expected an error for 0x1_0000_0000_0000_0000 without big integers, got %v", p.Errors())
+	}
+}
+
+func TestTypedIntegerLiterals(t *testing.T) {
+	tests := []struct {
+		typ      arith.Int
+		input    string
+		expected string
+		err      string
+	}{
+		{arith.Int{Bits: 8}, "-128 + 127", "(-128 + 127)", ""},
+		{arith.Int{Bits: 8}, "128", "", "integer literal 128 does not fit into int8 (line 1.13)"},
+		{arith.Int{Bits: 8}, "-129", "", "integer literal 129 does not fit into int8 (line 1.14)"},
+		{arith.Int{Bits: 8}, "'ä'", "", "character literal 'ä' does not fit into int8 (line 1.13)"},
+		{arith.Int{Bits: 8, Unsigned: true}, "0xff", "255", ""},
+		{arith.Int{Bits: 8, Unsigned: true}, "256", "", "integer literal 256 does not fit into uint8 (line 1.13)"},
+		{arith.Int{Bits: 8, Unsigned: true}, "-1", "(-1)", ""},
+		// a uint64 is stored as its bits
+		{arith.Int{Bits: 64, Unsigned: true}, "18446744073709551615", "-1", ""},
+		{arith.Int{Bits: 32}, "-2147483648", "-2147483648", ""},
+	}
+
+	for i, tt := range tests {
+		p := NewWithOptions(lexer.New("let main a = "+tt.input+" end"), Options{Int: tt.typ})
+		prog := p.ParseProgram()
+
+		if tt.err != "" {
+			if len(p.Errors()) != 1 || p.Errors()[0] != tt.err {
+				t.Fatalf("tests[%d] - expected the error %q for %s, got %v", i, tt.err, tt.input, p.Errors())
+			}
+			continue
+		}
+		if len(p.Errors()) != 0 {
+			t.Fatalf("tests[%d] - parser errors: %v", i, p.Errors())
+		}
+		if got := infix(prog.Functions[0].Body); got != tt.expected {
+			t.Fatalf("tests[%d] - %q parsed wrong. expected=%s, got=%s", i, tt.input, tt.expected, got)
+		}
+	}
+
+	// negative patterns are literals, unlike negations in expressions
+	p := NewWithOptions(lexer.New("let f -1 = 1 end let f x = x end"), Options{Int: arith.Int{Bits: 8, Unsigned: true}})
+	p.ParseProgram()
+	if len(p.Errors()) == 0 || p.Errors()[0] != "integer literal -1 does not fit into uint8 (line 1.6)" {
+		t.Fatalf("expected an error for the pattern -1, got %v", p.Errors())
+	}
+}
+
+func TestLexerErrors(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"let main x = @ end", "Syntax error: Invalid byte: got '@' (line 1.13)"},
+		{"let main x = x + 1 $ end", "Syntax error: Invalid byte: got '$' (line 1.19)"},
+		{"let main x = 0x end", "Syntax error: hexadecimal literal 0x has no digits (line 1.13)"},
+		{"let main x = x + 'ab' end", "Syntax error: character literal 'ab' contains more than one character (line 1.17)"},
+	}
+
+	// the parser doesn't report the illegal tokens again
+	for i, tt := range tests {
+		p := New(lexer.New(tt.input))
+		p.ParseProgram()
+		if len(p.Errors()) != 1 || p.Errors()[0] != tt.expected {
+			t.Fatalf("tests[%d] - expected the error %q, got %q", i, tt.expected, p.Errors())
+		}
+	}
+}
+
+func TestCondExpression(t *testing.T) {
+	p := New(lexer.New("let main a = if a < 0 then 1 elif a == 0 then 2 elif a < 10 then 3 else 4 end end"))
+	prog := p.ParseProgram()
+	if len(p.Errors()) != 0 {
+		t.Fatalf("parser errors: %v", p.Errors())
+	}
+
+	cond, ok := prog.Functions[0].Body.(*ast.CondExpression)
+	if !ok {
+		t.Fatalf("expected a CondExpression, got %T", prog.Functions[0].Body)
+	}
+
+	expected := []string{"(a < 0)", "1", "(a == 0)", "2", "(a < 10)", "3"}
+	got := []string{}
+	for _, b := range cond.Branches {
+		got = append(got, infix(b.Condition), infix(b.Consequence))
+	}
+
+	if fmt.Sprint(got) != fmt.Sprint(expected) || infix(cond.Alternative) != "4" {
+		t.Fatalf("branches wrong. expected=%v else 4, got=%v else %s", expected, got, infix(cond.Alternative))
+	}
+
+	p = New(lexer.New("let main a = if a then 1 end end"))
+	p.ParseProgram()
+	if len(p.Errors()) == 0 {
+		t.Fatalf("expected an error for an if without else")
+	}
+}
+
+func TestFunctionClauses(t *testing.T) {
+	p := New(lexer.New("let f 0 y = y end let f x -1 = x end let f x y = x * y end let main a = f (a) (a) end"))
+	prog := p.ParseProgram()
+	if len(p.Errors()) != 0 {
+		t.Fatalf("parser errors: %v", p.Errors())
+	}
+
+	if len(prog.Functions) != 2 {
+		t.Fatalf("expected the clauses to be grouped into 2 functions, got %d", len(prog.Functions))
+	}
+
+	f := prog.Functions[0]
+	if f.Name.Name != "f" || len(f.Clauses) != 3 || len(f.Params) != 2 {
+		t.Fatalf("expected f with 3 clauses of 2 parameters, got %s with %d clauses of %d parameters", f.Name.Name, len(f.Clauses), len(f.Params))
+	}
+
+	expected := []string{"0 y", "x -1", "x y"}
+	for i, c := range f.Clauses {
+		got := []string{}
+		for _, pattern := range c.Patterns {
+			got = append(got, infix(pattern))
+		}
+		if strings.Join(got, " ") != expected[i] {
+			t.Errorf("clause %d has wrong patterns. expected=%q, got=%q", i+1, expected[i], strings.Join(got, " "))
+		}
+	}
+
+	p = New(lexer.New("let f 0 = 1 end let f x y = x end"))
+	p.ParseProgram()
+	if len(p.Errors()) == 0 {
+		t.Fatalf("expected an error for clauses with different numbers of parameters")
+	}
+
+	// without integer patterns the second definition could never be chosen
+	p = New(lexer.New("let f x = x end let f y = 2 end"))
+	p.ParseProgram()
+	if len(p.Errors()) != 1 || p.Errors()[0] != "function 'f' is defined twice, first at line 1.0 (line 1.16)" {
+		t.Fatalf("expected an error for f defined twice, got %v", p.Errors())
+	}
+}
+
+func TestConstants(t *testing.T) {
+	p := New(lexer.New("let maxdigits = 18 end let main = maxdigits + 1 end"))
+	prog := p.ParseProgram()
+	if len(p.Errors()) != 0 {
+		t.Fatalf("parser errors: %v", p.Errors())
+	}
+
+	for _, f := range prog.Functions {
+		if len(f.Params) != 0 {
+			t.Errorf("expected %s to have no parameters, got %d", f.Name.Name, len(f.Params))
+		}
+	}
+
+	if got := infix(prog.Functions[1].Body); got != "(maxdigits + 1)" {
+		t.Fatalf("body of main wrong. expected=%q, got=%q", "(maxdigits + 1)", got)
+	}
+}
+
+func TestLocalFunctions(t *testing.T) {
+	p := New(lexer.New("let main a = let k = 2 and sq y = y * y + k in sq (a) end end"))
+	prog := p.ParseProgram()
+	if len(p.Errors()) != 0 {
+		t.Fatalf("parser errors: %v", p.Errors())
+	}
+
+	let := prog.Functions[0].Body.(*ast.LetExpression)
+	f, ok := let.Bindings[1].Expr.(*ast.Function)
+	if !ok {
+		t.Fatalf("expected sq to be bound to a Function, got %T", let.Bindings[1].Expr)
+	}
+
+	if f.Name.Name != "sq" || len(f.Params) != 1 || infix(f.Body) != "((y * y) + k)" {
+		t.Fatalf("local function wrong. got %s with %d parameters and body %s", f.Name.Name, len(f.Params), infix(f.Body))
+	}
+
+	p = New(lexer.New("let main a = loop sq y = y in sq (a) end end"))
+	p.ParseProgram()
+	if len(p.Errors()) == 0 {
+		t.Fatalf("expected an error for a function bound by a loop")
+	}
+}
+
+func TestLambda(t *testing.T) {
+	p := New(lexer.New("let main a = (fn x y -> x * y end) (a) (2) + (f (a)) (1) end"))
+	prog := p.ParseProgram()
+	if len(p.Errors()) != 0 {
+		t.Fatalf("parser errors: %v", p.Errors())
+	}
+
+	sum := prog.Functions[0].Body.(*ast.BinaryExpression)
+
+	call, ok := sum.Left.(*ast.CallExpression)
+	if !ok || len(call.Params) != 2 {
+		t.Fatalf("expected a call with 2 arguments, got %T", sum.Left)
+	}
+
+	lambda, ok := call.Function.(*ast.Lambda)
+	if !ok || len(lambda.Params) != 2 || infix(lambda.Body) != "(x * y)" {
+		t.Fatalf("expected the lambda fn x y -> (x * y), got %T", call.Function)
+	}
+
+	call, ok = sum.Right.(*ast.CallExpression)
+	if !ok || len(call.Params) != 1 {
+		t.Fatalf("expected a call with 1 argument, got %T", sum.Right)
+	}
+
+	if _, ok := call.Function.(*ast.FunctionCall); !ok {
+		t.Fatalf("expected the called function to be the result of a call, got %T", call.Function)
+	}
+}
+
+func TestArrayExpression(t *testing.T) {
+	p := New(lexer.New("let main n = array (n + 1) (fn i -> i * i end)[n] end"))
+	prog := p.ParseProgram()
+	if len(p.Errors()) != 0 {
+		t.Fatalf("parser errors: %v", p.Errors())
+	}
+
+	index, ok := prog.Functions[0].Body.(*ast.IndexExpression)
+	if !ok || infix(index.Index) != "n" {
+		t.Fatalf("expected the array to be indexed by n, got %T", prog.Functions[0].Body)
+	}
+
+	array, ok := index.Array.(*ast.ArrayExpression)
+	if !ok || infix(array.Length) != "(n + 1)" {
+		t.Fatalf("expected an array of length (n + 1), got %T", index.Array)
+	}
+
+	if _, ok := array.Function.(*ast.Lambda); !ok {
+		t.Fatalf("expected the elements to be given by a lambda, got %T", array.Function)
+	}
+}
+
+func TestImports(t *testing.T) {
+	p := New(lexer.New("import \"math.simp\"\nimport \"../lib/digits.simp\"\nlet main x = x end"))
+	prog := p.ParseProgram()
+	if len(p.Errors()) != 0 {
+		t.Fatalf("parser errors: %v", p.Errors())
+	}
+
+	if len(prog.Imports) != 2 || prog.Imports[0].Path != "math.simp" || prog.Imports[1].Path != "../lib/digits.simp" {
+		t.Fatalf("imports wrong. got=%v", prog.Imports)
+	}
+
+	for _, input := range []string{"import math\nlet main x = x end", "let main x = x end\nimport \"math.simp\"", "import \"\"\nlet main x = x end"} {
+		p := New(lexer.New(input))
+		p.ParseProgram()
+		if len(p.Errors()) == 0 {
+			t.Fatalf("expected an error for %q", input)
+		}
+	}
+}
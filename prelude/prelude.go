@@ -0,0 +1,71 @@
+package prelude
+
+import (
+	_ "embed"
+	"fmt"
+
+	"github.com/simplang/ast"
+	"github.com/simplang/callgraph"
+	"github.com/simplang/lexer"
+	"github.com/simplang/parser"
+)
+
+// Source is the simplang source of the prelude
+//
+//go:embed prelude.simp
+var Source string
+
+// Program returns a freshly parsed prelude, so it can be modified by the caller
+func Program() *ast.Program {
+	p := parser.New(lexer.New(Source))
+	prog := p.ParseProgram()
+
+	// the prelude is part of the binary, an error in it can't be fixed by the user
+	if len(p.Errors()) != 0 {
+		panic(fmt.Sprintf("prelude: %v", p.Errors()))
+	}
+
+	return prog
+}
+
+// Add appends the prelude functions prog uses, directly or through other prelude
+// functions, to prog. A function of prog replaces the prelude function of the
+// same name, also where other prelude functions call it.
+func Add(prog *ast.Program) {
+	defined := map[string]bool{}
+	for _, f := range prog.Functions {
+		defined[f.Name.Name] = true
+	}
+
+	library := []*ast.Function{}
+	for _, f := range Program().Functions {
+		if !defined[f.Name.Name] {
+			library = append(library, f)
+		}
+	}
+
+	// the call graph of the program and the library together also finds prelude
+	// functions that are only used as values
+	g := callgraph.New(&ast.Program{Functions: append(append([]*ast.Function{}, prog.Functions...), library...)})
+
+	used := map[string]bool{}
+	var visit func(name string)
+	visit = func(name string) {
+		for _, callee := range g[name] {
+			if !used[callee] && !defined[callee] {
+				used[callee] = true
+				visit(callee)
+			}
+		}
+	}
+
+	for _, f := range prog.Functions {
+		visit(f.Name.Name)
+	}
+
+	for _, f := range library {
+		if used[f.Name.Name] {
+			prog.Functions = append(prog.Functions, f)
+		}
+	}
+}
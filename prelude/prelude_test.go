@@ -0,0 +1,93 @@
+package prelude
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/simplang/ast"
+	"github.com/simplang/checker"
+	"github.com/simplang/interpreter"
+	"github.com/simplang/lexer"
+	"github.com/simplang/parser"
+)
+
+func parse(t *testing.T, input string) *ast.Program {
+	p := parser.New(lexer.New(input))
+	prog := p.ParseProgram()
+	if len(p.Errors()) != 0 {
+		t.Fatalf("parser errors: %v", p.Errors())
+	}
+	return prog
+}
+
+func names(prog *ast.Program) []string {
+	res := []string{}
+	for _, f := range prog.Functions {
+		res = append(res, f.Name.Name)
+	}
+	return res
+}
+
+func TestPreludeTypes(t *testing.T) {
+	if _, errs := checker.Types(Program()); len(errs) != 0 {
+		t.Fatalf("type errors in the prelude: %v", errs)
+	}
+
+	if warnings := checker.Check(Program()); len(warnings) != 0 {
+		t.Fatalf("warnings in the prelude: %v", warnings)
+	}
+}
+
+func TestAdd(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected []string
+	}{
+		{"let main x = x end", []string{"main"}},
+		{"let main x = gcd (x) (12) end", []string{"main", "abs", "gcd"}},
+		{"let main x = len (digits (x)) end", []string{"main", "abs", "numdigits", "nthdigit", "digits"}},
+		{"let abs x = x end let main x = gcd (x) (12) end", []string{"abs", "main", "gcd"}},
+		{"let main x = (fn f -> f (x) end) (sign) end", []string{"main", "sign"}},
+		{"let main abs = abs (1) end", []string{"main"}},
+	}
+
+	for i, tt := range tests {
+		prog := parse(t, tt.input)
+		Add(prog)
+
+		if got := names(prog); !reflect.DeepEqual(got, tt.expected) {
+			t.Fatalf("tests[%d] - functions wrong. expected=%v, got=%v", i, tt.expected, got)
+		}
+	}
+}
+
+func TestFunctions(t *testing.T) {
+	tests := []struct {
+		main     string
+		expected int64
+	}{
+		{"abs (-5) + abs (5)", 10},
+		{"sign (-5) * 100 + sign (0) * 10 + sign (7)", -99},
+		{"min (3) (-4) * 10 + max (3) (-4)", -37},
+		{"pow (3) (4) + pow (2) (0) + pow (2) (-1)", 83},
+		{"pow (2) (62)", 1 << 62},
+		{"gcd (-12) (18) * 100 + gcd (0) (7)", 607},
+		{"numdigits (0) * 100 + numdigits (-99) * 10 + numdigits (100)", 123},
+		{"nthdigit (-9876) (0) * 100 + nthdigit (9876) (3) * 10 + nthdigit (9876) (4)", 690},
+		{"let d = digits (1230) in d[0] * 1000 + d[1] * 100 + d[3] * 10 + len (d) end", 314},
+		{"reversedigits (1230) + reversedigits (-45)", 267},
+	}
+
+	for i, tt := range tests {
+		prog := parse(t, "let main = "+tt.main+" end")
+		Add(prog)
+
+		if _, errs := checker.Types(prog); len(errs) != 0 {
+			t.Fatalf("tests[%d] - type errors: %v", i, errs)
+		}
+
+		if got := interpreter.Interprete(prog, nil); got != tt.expected {
+			t.Fatalf("tests[%d] - %s wrong. expected=%d, got=%d", i, tt.main, tt.expected, got)
+		}
+	}
+}
@@ -0,0 +1,249 @@
+package simplang
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"math/big"
+	"strings"
+
+	"github.com/simplang/arith"
+	"github.com/simplang/ast"
+	"github.com/simplang/checker"
+	"github.com/simplang/interpreter"
+	"github.com/simplang/lexer"
+	"github.com/simplang/limits"
+	"github.com/simplang/parser"
+	"github.com/simplang/prelude"
+)
+
+// Program is a compiled program whose functions can be called any number of
+// times. Calls don't share any state, so they may run concurrently.
+type Program struct {
+	in        *interpreter.Interpreter
+	functions []Function
+	types     map[string]checker.Type
+}
+
+// Function describes a top-level function of a program
+type Function struct {
+	Name   string
+	Params []string
+	Type   string
+}
+
+// CompileError holds all errors found while compiling a program
+type CompileError struct {
+	Errors []string
+}
+
+func (e *CompileError) Error() string {
+	return strings.Join(e.Errors, "\n")
+}
+
+// Option changes how a program is compiled and run
+type Option func(*config)
+
+type config struct {
+	prelude    bool
+	output     io.Writer
+	builtins   []*interpreter.Builtin
+	limits     limits.Limits
+	arithmetic arith.Mode
+	intType    arith.Int
+	errors     []string
+}
+
+// WithoutPrelude leaves out the functions of the standard prelude
+func WithoutPrelude() Option {
+	return func(c *config) {
+		c.prelude = false
+	}
+}
+
+// WithOutput sets the writer print writes to, it is os.Stdout by default
+func WithOutput(w io.Writer) Option {
+	return func(c *config) {
+		c.output = w
+	}
+}
+
+// WithMaxSteps stops a call after n function calls and loop iterations with an
+// error wrapping limits.ErrSteps
+func WithMaxSteps(n int64) Option {
+	return func(c *config) {
+		c.limits.MaxSteps = n
+	}
+}
+
+// WithMaxDepth stops a call once more than n function calls are nested with an
+// error wrapping limits.ErrDepth. The limit is limits.DefaultMaxDepth unless this
+// option is given, WithMaxDepth(0) removes it.
+func WithMaxDepth(n int) Option {
+	return func(c *config) {
+		c.limits.MaxDepth = n
+	}
+}
+
+// WithArithmetic sets what arithmetic operations do on overflow, they wrap by
+// default. In checked mode an overflow fails with an error wrapping
+// arith.ErrOverflow. In big mode integer literals and results may have any size,
+// use CallBig to get the ones not fitting into 64 bits.
+func WithArithmetic(m arith.Mode) Option {
+	return func(c *config) {
+		c.arithmetic = m
+	}
+}
+
+// WithIntType sets the type of all integers, they are int64 by default. Literals
+// have to fit into it and every operation computes its results in it. Values of
+// type uint64 are passed to and returned by Call as their bits. It can't be
+// combined with big arithmetic.
+func WithIntType(t arith.Int) Option {
+	return func(c *config) {
+		c.intType = t
+	}
+}
+
+// WithBuiltin makes fn callable as name with arity arguments in this program. It
+// hides a registered builtin of the same name.
+func WithBuiltin(name string, arity int, fn func(args []int64) (int64, error)) Option {
+	return func(c *config) {
+		b, err := interpreter.NewBuiltin(name, arity, fn)
+		if err != nil {
+			c.errors = append(c.errors, err.Error())
+			return
+		}
+		c.builtins = append(c.builtins, b)
+	}
+}
+
+// Compile parses and type checks src. Programs compiled this way can't import
+// other files. The errors found are returned as *CompileError.
+func Compile(src string, opts ...Option) (*Program, error) {
+	c := &config{prelude: true, limits: limits.Limits{MaxDepth: limits.DefaultMaxDepth}, errors: []string{}}
+	for _, opt := range opts {
+		opt(c)
+	}
+	if c.arithmetic == arith.Big && !c.intType.Is64() {
+		c.errors = append(c.errors, fmt.Sprintf("big arithmetic can't be used with %s", c.intType))
+	}
+	if len(c.errors) != 0 {
+		return nil, &CompileError{Errors: c.errors}
+	}
+
+	p := parser.NewWithOptions(lexer.New(src), parser.Options{BigIntegers: c.arithmetic == arith.Big, Int: c.intType})
+	prog := p.ParseProgram()
+	if len(p.Errors()) != 0 {
+		return nil, &CompileError{Errors: p.Errors()}
+	}
+
+	for _, imp := range prog.Imports {
+		c.errors = append(c.errors, fmt.Sprintf("could not import %q: imports are not supported (line %d.%d)", imp.Path, imp.Token.Line, imp.Token.Column))
+	}
+	if len(c.errors) != 0 {
+		return nil, &CompileError{Errors: c.errors}
+	}
+
+	if c.prelude {
+		prelude.Add(prog)
+	}
+
+	in, err := interpreter.New(prog)
+	if err != nil {
+		return nil, &CompileError{Errors: []string{err.Error()}}
+	}
+	if c.output != nil {
+		in.SetOutput(c.output)
+	}
+	for _, b := range c.builtins {
+		in.AddBuiltin(b)
+	}
+	in.SetLimits(c.limits)
+	in.SetArithmetic(c.arithmetic)
+	in.SetIntType(c.intType)
+
+	types, errs := checker.TypesWith(prog, in.Builtin)
+	if len(errs) != 0 {
+		return nil, &CompileError{Errors: errs}
+	}
+
+	return &Program{in: in, functions: functions(prog, types), types: types}, nil
+}
+
+func functions(prog *ast.Program, types map[string]checker.Type) []Function {
+	res := make([]Function, len(prog.Functions))
+
+	for i, f := range prog.Functions {
+		params := make([]string, len(f.Params))
+		for j, p := range f.Params {
+			params[j] = p.Name
+		}
+		res[i] = Function{Name: f.Name.Name, Params: params, Type: types[f.Name.Name].String()}
+	}
+
+	return res
+}
+
+// Functions returns the top-level functions of the program, including the ones
+// of the prelude it uses
+func (p *Program) Functions() []Function {
+	return append([]Function{}, p.functions...)
+}
+
+// Call calls the top-level function name with the arguments. The function has to
+// take integers and return an integer. Runtime errors are returned as
+// *interpreter.Error.
+func (p *Program) Call(name string, args ...int64) (int64, error) {
+	return p.CallContext(context.Background(), name, args...)
+}
+
+// CallContext is like Call, but the call is stopped with the error of ctx once ctx
+// is done
+func (p *Program) CallContext(ctx context.Context, name string, args ...int64) (int64, error) {
+	if err := p.checkCall(name); err != nil {
+		return 0, err
+	}
+	return p.in.CallContext(ctx, name, args)
+}
+
+// CallBig is like CallContext for integers of any size. Only programs compiled
+// with WithArithmetic(arith.Big) compute integers beyond 64 bits.
+func (p *Program) CallBig(ctx context.Context, name string, args ...*big.Int) (*big.Int, error) {
+	if err := p.checkCall(name); err != nil {
+		return nil, err
+	}
+	return p.in.CallBig(ctx, name, args)
+}
+
+// checkCall reports an error unless name takes integers and returns an integer
+func (p *Program) checkCall(name string) error {
+	t, ok := p.types[name]
+	if !ok {
+		return fmt.Errorf("function '%s' could not be found", name)
+	}
+
+	result := t
+	if f, ok := checker.Resolve(t).(*checker.Func); ok {
+		for i, param := range f.Params {
+			if !isInt(param) {
+				return fmt.Errorf("parameter %d of '%s' is %s, not an integer", i+1, name, checker.Resolve(param))
+			}
+		}
+		result = f.Result
+	}
+
+	if !isInt(result) {
+		return fmt.Errorf("'%s' returns %s, not an integer", name, checker.Resolve(result))
+	}
+	return nil
+}
+
+// isInt reports whether an integer can have the type t
+func isInt(t checker.Type) bool {
+	t = checker.Resolve(t)
+	if _, ok := t.(*checker.Var); ok {
+		return true
+	}
+	return t == checker.Int
+}
@@ -0,0 +1,336 @@
+package simplang
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"math/big"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/simplang/arith"
+	"github.com/simplang/interpreter"
+	"github.com/simplang/limits"
+)
+
+const formulas = `let area w h = w * h end
+let scale = 3 end
+let scaled x = x * scale end
+let apply f x = f (x) end
+let even x = x % 2 == 0 end
+let div x y = x / y end
+let main x = abs (x) end`
+
+func TestCall(t *testing.T) {
+	prog, err := Compile(formulas)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	tests := []struct {
+		name     string
+		args     []int64
+		expected int64
+	}{
+		{"area", []int64{3, 4}, 12},
+		{"area", []int64{-2, 5}, -10},
+		{"scaled", []int64{7}, 21},
+		{"scale", nil, 3},
+		{"main", []int64{-8}, 8},
+		{"abs", []int64{-9}, 9},
+	}
+
+	for i, tt := range tests {
+		got, err := prog.Call(tt.name, tt.args...)
+		if err != nil {
+			t.Fatalf("tests[%d] - unexpected error: %s", i, err)
+		}
+		if got != tt.expected {
+			t.Fatalf("tests[%d] - %s%v wrong. expected=%d, got=%d", i, tt.name, tt.args, tt.expected, got)
+		}
+	}
+}
+
+func TestConcurrentCalls(t *testing.T) {
+	prog, err := Compile("let sum n = loop i = 0 and s = 0 in if i > n then s else recur (i + 1) (s + i) end end end")
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	var wg sync.WaitGroup
+	for n := int64(0); n < 20; n++ {
+		wg.Add(1)
+		go func(n int64) {
+			defer wg.Done()
+			if got, err := prog.Call("sum", n*100); err != nil || got != n*100*(n*100+1)/2 {
+				t.Errorf("sum (%d) wrong. got=%d, err=%v", n*100, got, err)
+			}
+		}(n)
+	}
+	wg.Wait()
+}
+
+func TestCallErrors(t *testing.T) {
+	prog, err := Compile(formulas)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	tests := []struct {
+		name     string
+		args     []int64
+		expected string
+	}{
+		{"missing", nil, "function 'missing' could not be found"},
+		{"area", []int64{1}, "function 'area' takes 2 argument(s), got 1"},
+		{"apply", []int64{1, 2}, "parameter 1 of 'apply' is fn(t"},
+		{"even", []int64{1}, "'even' returns bool, not an integer"},
+		{"div", []int64{1, 0}, "division by zero (line 6.17)"},
+	}
+
+	for i, tt := range tests {
+		_, err := prog.Call(tt.name, tt.args...)
+		if err == nil || !strings.Contains(err.Error(), tt.expected) {
+			t.Fatalf("tests[%d] - error wrong. expected it to contain %q, got %v", i, tt.expected, err)
+		}
+	}
+
+	_, err = prog.Call("div", 1, 0)
+	var runtimeErr *interpreter.Error
+	if !errors.As(err, &runtimeErr) || runtimeErr.Msg != "division by zero" {
+		t.Fatalf("expected a runtime error, got %#v", err)
+	}
+}
+
+func TestCompileErrors(t *testing.T) {
+	tests := []struct {
+		input    string
+		opts     []Option
+		expected string
+	}{
+		{"let f x = x +", nil, "expected"},
+		{"let f x = x + true end", nil, "bool"},
+		{"import \"lib.simp\"\nlet f x = x end", nil, "could not import \"lib.simp\": imports are not supported (line 1.0)"},
+		{"let f x = x end\nlet g x = x end\nlet f x = 2 end", nil, "function 'f' is already defined (line 3.1)"},
+		{"let f x = abs (x) end", []Option{WithoutPrelude()}, "'abs' is not defined"},
+		{"let f x = x end", []Option{WithBuiltin("a-b", 1, nil)}, "builtin name \"a-b\" is not an identifier"},
+	}
+
+	for i, tt := range tests {
+		prog, err := Compile(tt.input, tt.opts...)
+		if prog != nil || err == nil {
+			t.Fatalf("tests[%d] - expected an error, got none", i)
+		}
+
+		var compileErr *CompileError
+		if !errors.As(err, &compileErr) {
+			t.Fatalf("tests[%d] - expected a *CompileError, got %#v", i, err)
+		}
+		if !strings.Contains(err.Error(), tt.expected) {
+			t.Fatalf("tests[%d] - error wrong. expected it to contain %q, got %q", i, tt.expected, err.Error())
+		}
+	}
+}
+
+func TestFunctions(t *testing.T) {
+	prog, err := Compile("let area w h = w * h end\nlet ok = true end\nlet main x = area (x) (sign (x)) end")
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	expected := []Function{
+		{Name: "area", Params: []string{"w", "h"}, Type: "fn(int, int) -> int"},
+		{Name: "ok", Params: []string{}, Type: "bool"},
+		{Name: "main", Params: []string{"x"}, Type: "fn(int) -> int"},
+		{Name: "sign", Params: []string{"x"}, Type: "fn(int) -> int"},
+	}
+
+	if got := prog.Functions(); !reflect.DeepEqual(got, expected) {
+		t.Fatalf("functions wrong. expected=%v, got=%v", expected, got)
+	}
+}
+
+func TestOptions(t *testing.T) {
+	var buf bytes.Buffer
+	calls := 0
+
+	prog, err := Compile("let f x = print (\"x =\") (x); clamp (x) (0) (10) + isqrt (x) end",
+		WithOutput(&buf),
+		WithBuiltin("clamp", 3, func(args []int64) (int64, error) {
+			calls++
+			if args[0] < args[1] {
+				return args[1], nil
+			}
+			if args[0] > args[2] {
+				return args[2], nil
+			}
+			return args[0], nil
+		}),
+		WithBuiltin("isqrt", 1, func(args []int64) (int64, error) {
+			return 0, errors.New("not allowed")
+		}))
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	if _, err := prog.Call("f", 16); err == nil || err.Error() != "isqrt: not allowed (line 1.50)" {
+		t.Fatalf("expected the isqrt of the program to be called, got %v", err)
+	}
+
+	if calls != 1 || buf.String() != "x = 16\n" {
+		t.Fatalf("wrong calls=%d or output=%q", calls, buf.String())
+	}
+
+	// the builtin only exists in the program it was given to
+	if _, err := Compile("let f x = clamp (x) (0) (10) end"); err == nil {
+		t.Fatalf("expected clamp to be undefined in another program")
+	}
+}
+
+func TestLimits(t *testing.T) {
+	prog, err := Compile("let spin x = loop i = x in recur (i) end end\nlet down n = if n == 0 then 0 else 1 + down (n - 1) end end",
+		WithMaxSteps(10000), WithMaxDepth(50))
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	if _, err := prog.Call("spin", 1); !errors.Is(err, limits.ErrSteps) {
+		t.Fatalf("expected the step limit to stop spin, got %v", err)
+	}
+
+	if _, err := prog.Call("down", 50); !errors.Is(err, limits.ErrDepth) {
+		t.Fatalf("expected the depth limit to stop down, got %v", err)
+	}
+
+	if got, err := prog.Call("down", 49); err != nil || got != 49 {
+		t.Fatalf("down (49) wrong. got=%d, err=%v", got, err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := prog.CallContext(ctx, "down", 1); !errors.Is(err, context.Canceled) {
+		t.Fatalf("expected the canceled context to stop down, got %v", err)
+	}
+}
+
+func TestArithmetic(t *testing.T) {
+	src := "let double x = x * 2 end"
+
+	for _, tt := range []struct {
+		mode     arith.Mode
+		expected int64
+		err      error
+	}{
+		{arith.Wrapping, -2, nil},
+		{arith.Saturating, 9223372036854775807, nil},
+		{arith.Checked, 0, arith.ErrOverflow},
+	} {
+		prog, err := Compile(src, WithArithmetic(tt.mode))
+		if err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+
+		got, err := prog.Call("double", 9223372036854775807)
+		if got != tt.expected || !errors.Is(err, tt.err) {
+			t.Fatalf("double in %s mode wrong. expected=%d, %v, got=%d, %v", tt.mode, tt.expected, tt.err, got, err)
+		}
+	}
+}
+
+func TestBigIntegers(t *testing.T) {
+	prog, err := Compile("let fact n = if n < 2 then 1 else n * fact (n - 1) end end\nlet huge = 0x1_0000_0000_0000_0000 end",
+		WithArithmetic(arith.Big))
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	if got, err := prog.CallBig(context.Background(), "fact", big.NewInt(25)); err != nil || got.String() != "15511210043330985984000000" {
+		t.Fatalf("fact (25) wrong. got=%v, err=%v", got, err)
+	}
+
+	if got, err := prog.CallBig(context.Background(), "huge"); err != nil || got.String() != "18446744073709551616" {
+		t.Fatalf("huge wrong. got=%v, err=%v", got, err)
+	}
+
+	if got, err := prog.Call("fact", 20); err != nil || got != 2432902008176640000 {
+		t.Fatalf("fact (20) wrong. got=%d, err=%v", got, err)
+	}
+
+	if _, err := prog.Call("fact", 21); err == nil {
+		t.Fatalf("expected fact (21) not to fit into an int64")
+	}
+
+	if _, err := Compile("let huge = 0x1_0000_0000_0000_0000 end"); err == nil {
+		t.Fatalf("expected the literal to be refused without big integers")
+	}
+}
+
+func TestIntType(t *testing.T) {
+	prog, err := Compile("let double x = x * 2 end\nlet less x y = if x < y then 1 else 0 end end", WithIntType(arith.Int{Bits: 8, Unsigned: true}))
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	if got, err := prog.Call("double", 200); err != nil || got != 144 {
+		t.Fatalf("double (200) wrong. got=%d, err=%v", got, err)
+	}
+	if got, err := prog.Call("less", 1, 255); err != nil || got != 1 {
+		t.Fatalf("less 1 255 wrong. got=%d, err=%v", got, err)
+	}
+	if _, err := prog.Call("double", -1); err == nil {
+		t.Fatalf("expected -1 not to fit into a uint8")
+	}
+
+	if _, err := Compile("let f = 300 end", WithIntType(arith.Int{Bits: 8, Unsigned: true})); err == nil || err.Error() != "integer literal 300 does not fit into uint8 (line 1.8)" {
+		t.Fatalf("expected the literal to be refused, got %v", err)
+	}
+
+	if _, err := Compile("let f = 1 end", WithIntType(arith.Int{Bits: 32}), WithArithmetic(arith.Big)); err == nil || err.Error() != "big arithmetic can't be used with int32" {
+		t.Fatalf("expected big arithmetic to be refused, got %v", err)
+	}
+
+	// counting down to -1 never ends without negative values, unless the
+	// subtraction below 0 fails in checked mode
+	countdown := "let main n = loop i = n in if i < 0 then 0 else recur (i - 1) end end end"
+	prog, err = Compile(countdown, WithIntType(arith.Int{Bits: 16, Unsigned: true}), WithArithmetic(arith.Checked))
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if _, err := prog.Call("main", 3); !errors.Is(err, arith.ErrOverflow) || err.Error() != "integer overflow in 0 - 1 (line 1.57)" {
+		t.Fatalf("expected the countdown to overflow, got %v", err)
+	}
+
+	prog, err = Compile(countdown, WithIntType(arith.Int{Bits: 16, Unsigned: true}), WithMaxSteps(1000))
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if _, err := prog.Call("main", 3); !errors.Is(err, limits.ErrSteps) {
+		t.Fatalf("expected the countdown to run until the step limit, got %v", err)
+	}
+}
+
+func TestDefaultMaxDepth(t *testing.T) {
+	src := "let down n = if n == 0 then 0 else 1 + down (n - 1) end end"
+	n := int64(limits.DefaultMaxDepth)
+
+	prog, err := Compile(src)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if _, err := prog.Call("down", n); !errors.Is(err, limits.ErrDepth) {
+		t.Fatalf("expected the default depth limit to stop down, got %v", err)
+	}
+	if got, err := prog.Call("down", n-1); err != nil || got != n-1 {
+		t.Fatalf("down (%d) wrong. got=%d, err=%v", n-1, got, err)
+	}
+
+	prog, err = Compile(src, WithMaxDepth(0))
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if got, err := prog.Call("down", n); err != nil || got != n {
+		t.Fatalf("expected no depth limit, down (%d) got=%d, err=%v", n, got, err)
+	}
+}
@@ -1,10 +1,13 @@
 package token
 
 /*
-* Keywords are let, and, in, if, then, else, recur, loop, end.
-* Operators are (, ), =, &&, ||, !, <, ==, +, *, -.
+* Keywords are import, let, and, in, if, then, elif, else, recur, loop, fn, true, false, array, len, print, end.
+* Operators are (, ), [, ], ",", ;, =, &&, ||, !, <, >, <=, >=, ==, !=, +, *, /, %, -,
+* &, |, ^, ~, <<, >>, >>>, ->.
 * Identifiers can contain only letters, digits, and the underscore, but cannot start with a digit.
-* Integers are sequences of digits.
+* Integers are sequences of digits, optionally with a 0x, 0b or 0o prefix and _ separators.
+* Characters are single quoted and stand for their code point, e.g. 'a' or '\n'.
+* Strings are double quoted and may contain the same escapes, e.g. "x = \t".
  */
 
 const (
@@ -12,57 +15,97 @@ const (
 	EOF     = "EOF"
 
 	// identifiers and literals
-	IDENT = "IDENT"
-	INT   = "INT"
+	IDENT  = "IDENT"
+	INT    = "INT"
+	CHAR   = "CHAR"
+	STRING = "STRING"
 
 	// operators
 	ASSIGN = "="
 
-	LOG_AND = "&&"
-	LOG_OR  = "||"
-	NOT     = "!"
-	LESS    = "<"
-	EQUAL   = "=="
-
-	PLUS  = "+"
-	TIMES = "*"
-	MINUS = "-"
+	LOG_AND       = "&&"
+	LOG_OR        = "||"
+	NOT           = "!"
+	LESS          = "<"
+	GREATER       = ">"
+	LESS_EQUAL    = "<="
+	GREATER_EQUAL = ">="
+	EQUAL         = "=="
+	NOT_EQUAL     = "!="
+
+	PLUS    = "+"
+	TIMES   = "*"
+	SLASH   = "/"
+	PERCENT = "%"
+	MINUS   = "-"
+
+	BIT_AND             = "&"
+	BIT_OR              = "|"
+	BIT_XOR             = "^"
+	BIT_NOT             = "~"
+	SHIFT_LEFT          = "<<"
+	SHIFT_RIGHT         = ">>"  // arithmetic, keeps the sign
+	SHIFT_RIGHT_LOGICAL = ">>>" // fills with zeros
+
+	ARROW = "->"
 
 	// delimiters
-	LPAREN = "("
-	RPAREN = ")"
+	LPAREN   = "("
+	RPAREN   = ")"
+	LBRACKET = "["
+	RBRACKET = "]"
+	COMMA    = ","
+
+	// separates the expressions of a sequence, the value of the last one is the result
+	SEMICOLON = ";"
 
 	// keywords
-	LET   = "let"
-	AND   = "and"
-	IN    = "in"
-	IF    = "if"
-	THEN  = "then"
-	ELSE  = "else"
-	RECUR = "recur"
-	LOOP  = "loop"
-	END   = "end"
+	IMPORT = "import"
+	LET    = "let"
+	AND    = "and"
+	IN     = "in"
+	IF     = "if"
+	THEN   = "then"
+	ELIF   = "elif"
+	ELSE   = "else"
+	RECUR  = "recur"
+	LOOP   = "loop"
+	FN     = "fn"
+	TRUE   = "true"
+	FALSE  = "false"
+	ARRAY  = "array"
+	LEN    = "len"
+	PRINT  = "print"
+	END    = "end"
 )
 
 type TokenType string
 
 type Token struct {
-	Type    TokenType
-	Literal string
-	Line    int
-	Column  int
+	Type    TokenType `json:"type"`
+	Literal string    `json:"literal"`
+	Line    int       `json:"line"`
+	Column  int       `json:"column"`
 }
 
 var keywords = map[string]TokenType{
-	"let":   LET,
-	"and":   AND,
-	"in":    IN,
-	"if":    IF,
-	"then":  THEN,
-	"else":  ELSE,
-	"recur": RECUR,
-	"loop":  LOOP,
-	"end":   END,
+	"import": IMPORT,
+	"let":    LET,
+	"and":    AND,
+	"in":     IN,
+	"if":     IF,
+	"then":   THEN,
+	"elif":   ELIF,
+	"else":   ELSE,
+	"recur":  RECUR,
+	"loop":   LOOP,
+	"fn":     FN,
+	"true":   TRUE,
+	"false":  FALSE,
+	"array":  ARRAY,
+	"len":    LEN,
+	"print":  PRINT,
+	"end":    END,
 }
 
 func LookupIdent(ident string) TokenType {
@@ -77,17 +120,36 @@ const (
 	PREC_LOWEST = iota
 	PREC_LOGIC
 	PREC_LEEQ
+	PREC_BIT_OR
+	PREC_BIT_XOR
+	PREC_BIT_AND
+	PREC_SHIFT
 	PREC_PLUS
 	PREC_TIMES
+	PREC_PREFIX // operand of a unary operator, binds tighter than every binary operator
 )
 
 var binop = map[TokenType]int{
-	LOG_AND: PREC_LOGIC,
-	LOG_OR:  PREC_LOGIC,
-	LESS:    PREC_LEEQ,
-	EQUAL:   PREC_LEEQ,
-	PLUS:    PREC_PLUS,
-	TIMES:   PREC_TIMES,
+	LOG_AND:       PREC_LOGIC,
+	LOG_OR:        PREC_LOGIC,
+	LESS:          PREC_LEEQ,
+	GREATER:       PREC_LEEQ,
+	LESS_EQUAL:    PREC_LEEQ,
+	GREATER_EQUAL: PREC_LEEQ,
+	EQUAL:         PREC_LEEQ,
+	NOT_EQUAL:     PREC_LEEQ,
+	BIT_OR:        PREC_BIT_OR,
+	BIT_XOR:       PREC_BIT_XOR,
+	BIT_AND:       PREC_BIT_AND,
+	SHIFT_LEFT:    PREC_SHIFT,
+	SHIFT_RIGHT:   PREC_SHIFT,
+	PLUS:          PREC_PLUS,
+	MINUS:         PREC_PLUS,
+	TIMES:         PREC_TIMES,
+	SLASH:         PREC_TIMES,
+	PERCENT:       PREC_TIMES,
+
+	SHIFT_RIGHT_LOGICAL: PREC_SHIFT,
 }
 
 func IsBinaryOperator(token TokenType) bool {
@@ -1,6 +1,9 @@
 package vm
 
 import "github.com/simplang/vminstruction"
+import "github.com/simplang/limits"
+import "github.com/simplang/arith"
+import "context"
 import "strings"
 import "fmt"
 import "os"
@@ -12,10 +15,36 @@ type VirtualMachine struct {
 	CallStack      []call
 	Values         []int64
 	ValPointer     int64
+	Heap           []int64       // arrays, each one is its length followed by its elements
+	Limits         limits.Limits // a step is an instruction, the depth is the size of the call stack
+	Arithmetic     arith.Mode    // what Add, Subtract, Multiply, Divide and Negate do on overflow, arith.Big is not supported
+	Int            arith.Int     // the type of the values, the operations expect them to be kept like arith.Int describes
+	halted         bool
+	result         int64
+	arrays         map[int64]bool // addresses of the arrays allocated on the heap
 }
 
+// Error is an error raised by an instruction, Index is the index of the instruction.
+// Err is the cause if the run was stopped by a limit, its context or an overflow.
+type Error struct {
+	Msg   string
+	Index int64
+	Err   error
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("%s (index %d)", e.Msg, e.Index)
+}
+
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+// maxHeap is the number of values the heap may hold, arrays beyond it can't be allocated
+const maxHeap = 1 << 24
+
 type call struct {
-	pc  int64 // index of call instruction (program counter)
+	pc  int64 // index of the instruction after the call (program counter)
 	vp  int64 // original index of the value pointer
 	dst int64 // destination where to write the result
 }
@@ -28,6 +57,8 @@ func New(instr []*vminstruction.Instruction) *VirtualMachine {
 		CallStack:      []call{},
 		Values:         make([]int64, 1000000),
 		ValPointer:     0,
+		Heap:           []int64{},
+		arrays:         map[int64]bool{},
 	}
 
 	type tuple struct {
@@ -36,18 +67,36 @@ func New(instr []*vminstruction.Instruction) *VirtualMachine {
 	}
 
 	instrMap := map[string]tuple{
-		"move":       tuple{args: 2, f: vm.Move},
-		"set":        tuple{args: 2, f: vm.Set},
-		"add":        tuple{args: 3, f: vm.Add},
-		"multiply":   tuple{args: 3, f: vm.Multiply},
-		"negate":     tuple{args: 2, f: vm.Negate},
-		"not":        tuple{args: 2, f: vm.Not},
-		"jump":       tuple{args: 1, f: vm.Jump},
-		"jumpifzero": tuple{args: 2, f: vm.JumpIfZero},
-		"call":       tuple{args: 3, f: vm.Call},
-		"return":     tuple{args: 1, f: vm.Return},
-		"lessthan":   tuple{args: 3, f: vm.LessThan},
-		"equals":     tuple{args: 3, f: vm.Equals},
+		"move":              tuple{args: 2, f: vm.Move},
+		"set":               tuple{args: 2, f: vm.Set},
+		"add":               tuple{args: 3, f: vm.Add},
+		"subtract":          tuple{args: 3, f: vm.Subtract},
+		"multiply":          tuple{args: 3, f: vm.Multiply},
+		"divide":            tuple{args: 3, f: vm.Divide},
+		"modulo":            tuple{args: 3, f: vm.Modulo},
+		"negate":            tuple{args: 2, f: vm.Negate},
+		"not":               tuple{args: 2, f: vm.Not},
+		"bitand":            tuple{args: 3, f: vm.BitAnd},
+		"bitor":             tuple{args: 3, f: vm.BitOr},
+		"bitxor":            tuple{args: 3, f: vm.BitXor},
+		"bitnot":            tuple{args: 2, f: vm.BitNot},
+		"shiftleft":         tuple{args: 3, f: vm.ShiftLeft},
+		"shiftright":        tuple{args: 3, f: vm.ShiftRight},
+		"shiftrightlogical": tuple{args: 3, f: vm.ShiftRightLogical},
+		"jump":              tuple{args: 1, f: vm.Jump},
+		"jumpifzero":        tuple{args: 2, f: vm.JumpIfZero},
+		"call":              tuple{args: 3, f: vm.Call},
+		"return":            tuple{args: 1, f: vm.Return},
+		"lessthan":          tuple{args: 3, f: vm.LessThan},
+		"greaterthan":       tuple{args: 3, f: vm.GreaterThan},
+		"lessorequal":       tuple{args: 3, f: vm.LessOrEqual},
+		"greaterorequal":    tuple{args: 3, f: vm.GreaterOrEqual},
+		"equals":            tuple{args: 3, f: vm.Equals},
+		"notequals":         tuple{args: 3, f: vm.NotEquals},
+		"alloc":             tuple{args: 2, f: vm.Alloc},
+		"load":              tuple{args: 3, f: vm.Load},
+		"store":             tuple{args: 3, f: vm.Store},
+		"length":            tuple{args: 2, f: vm.Length},
 	}
 
 	valid := true
@@ -76,6 +125,63 @@ func New(instr []*vminstruction.Instruction) *VirtualMachine {
 	return vm
 }
 
+// Run executes the instructions from the program counter on until the outermost
+// Return and returns its value. Errors are returned as *Error.
+func (vm *VirtualMachine) Run(ctx context.Context) (res int64, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			e, ok := r.(*Error)
+			if !ok {
+				panic(r)
+			}
+			err = e
+		}
+	}()
+
+	// the registers hold int64 values
+	if vm.Arithmetic == arith.Big {
+		panic(&Error{Msg: "big integers are not supported by the vm", Index: vm.ProgramCounter})
+	}
+
+	counter := limits.NewCounter(ctx, vm.Limits)
+	vm.stop(counter.Start(), vm.ProgramCounter)
+
+	for !vm.halted {
+		pc := vm.ProgramCounter
+		vm.stop(counter.Step(), pc)
+
+		if pc < 0 || pc >= int64(len(vm.Instructions)) {
+			panic(&Error{Msg: "no instruction at this index", Index: pc})
+		}
+
+		// the program counter points to the next instruction while one is executed
+		vm.ProgramCounter++
+		depth := len(vm.CallStack)
+		vm.Funcs[pc](vm.Instructions[pc].Args...)
+
+		if len(vm.CallStack) > depth {
+			vm.stop(counter.Enter(), pc)
+		} else if len(vm.CallStack) < depth {
+			counter.Leave()
+		}
+	}
+
+	vm.halted = false
+	return vm.result, nil
+}
+
+// stop ends the run at the instruction at index if err is not nil
+func (vm *VirtualMachine) stop(err error, index int64) {
+	if err != nil {
+		panic(&Error{Msg: err.Error(), Index: index, Err: err})
+	}
+}
+
+// fail ends the run with an error of the instruction being executed
+func (vm *VirtualMachine) fail(msg string) {
+	panic(&Error{Msg: msg, Index: vm.ProgramCounter - 1})
+}
+
 func (vm *VirtualMachine) read(offset int64) int64 {
 	return vm.Values[vm.ValPointer+offset]
 }
@@ -104,17 +210,60 @@ func (vm *VirtualMachine) Set(args ...*vminstruction.Arg) {
 
 // Add DST SRC1 SRC2
 func (vm *VirtualMachine) Add(args ...*vminstruction.Arg) {
-	vm.write(args[0].Value, vm.getVal(args[1])+vm.getVal(args[2]))
+	vm.write(args[0].Value, vm.arithmetic(vm.Int.Add, "+", vm.getVal(args[1]), vm.getVal(args[2])))
+}
+
+// Subtract DST SRC1 SRC2
+func (vm *VirtualMachine) Subtract(args ...*vminstruction.Arg) {
+	vm.write(args[0].Value, vm.arithmetic(vm.Int.Sub, "-", vm.getVal(args[1]), vm.getVal(args[2])))
 }
 
 // Multiply DST SRC1 SRC2
 func (vm *VirtualMachine) Multiply(args ...*vminstruction.Arg) {
-	vm.write(args[0].Value, vm.getVal(args[1])*vm.getVal(args[2]))
+	vm.write(args[0].Value, vm.arithmetic(vm.Int.Mul, "*", vm.getVal(args[1]), vm.getVal(args[2])))
+}
+
+// Divide DST SRC1 SRC2
+// The quotient is truncated towards zero
+func (vm *VirtualMachine) Divide(args ...*vminstruction.Arg) {
+	divisor := vm.getVal(args[2])
+	if divisor == 0 {
+		vm.fail("division by zero")
+	}
+	vm.write(args[0].Value, vm.arithmetic(vm.Int.Div, "/", vm.getVal(args[1]), divisor))
+}
+
+// Modulo DST SRC1 SRC2
+// The remainder has the sign of SRC1
+func (vm *VirtualMachine) Modulo(args ...*vminstruction.Arg) {
+	divisor := vm.getVal(args[2])
+	if divisor == 0 {
+		vm.fail("modulo by zero")
+	}
+	vm.write(args[0].Value, vm.Int.Rem(vm.getVal(args[1]), divisor))
 }
 
 // Negate DST SRC
 func (vm *VirtualMachine) Negate(args ...*vminstruction.Arg) {
-	vm.write(args[0].Value, -vm.getVal(args[1]))
+	x := vm.getVal(args[1])
+	res, ok := vm.Int.Neg(vm.Arithmetic, x)
+	if !ok {
+		vm.overflow(fmt.Sprintf("-(%s)", vm.Int.Format(x)))
+	}
+	vm.write(args[0].Value, res)
+}
+
+// arithmetic returns "a op b" computed by f, which fails on overflow in checked mode
+func (vm *VirtualMachine) arithmetic(f func(arith.Mode, int64, int64) (int64, bool), op string, a int64, b int64) int64 {
+	res, ok := f(vm.Arithmetic, a, b)
+	if !ok {
+		vm.overflow(fmt.Sprintf("%s %s %s", vm.Int.Format(a), op, vm.Int.Format(b)))
+	}
+	return res
+}
+
+func (vm *VirtualMachine) overflow(operation string) {
+	panic(&Error{Msg: fmt.Sprintf("%s in %s", arith.ErrOverflow, operation), Index: vm.ProgramCounter - 1, Err: arith.ErrOverflow})
 }
 
 // Not DST SRC
@@ -126,6 +275,51 @@ func (vm *VirtualMachine) Not(args ...*vminstruction.Arg) {
 	}
 }
 
+// BitAnd DST SRC1 SRC2
+func (vm *VirtualMachine) BitAnd(args ...*vminstruction.Arg) {
+	vm.write(args[0].Value, vm.getVal(args[1])&vm.getVal(args[2]))
+}
+
+// BitOr DST SRC1 SRC2
+func (vm *VirtualMachine) BitOr(args ...*vminstruction.Arg) {
+	vm.write(args[0].Value, vm.getVal(args[1])|vm.getVal(args[2]))
+}
+
+// BitXor DST SRC1 SRC2
+func (vm *VirtualMachine) BitXor(args ...*vminstruction.Arg) {
+	vm.write(args[0].Value, vm.getVal(args[1])^vm.getVal(args[2]))
+}
+
+// BitNot DST SRC
+func (vm *VirtualMachine) BitNot(args ...*vminstruction.Arg) {
+	vm.write(args[0].Value, vm.Int.Not(vm.getVal(args[1])))
+}
+
+// ShiftLeft DST SRC1 SRC2
+func (vm *VirtualMachine) ShiftLeft(args ...*vminstruction.Arg) {
+	vm.write(args[0].Value, vm.Int.Shl(vm.getVal(args[1]), vm.shiftCount(args[2])))
+}
+
+// ShiftRight DST SRC1 SRC2
+// Arithmetic shift, the sign bit of a signed type is kept
+func (vm *VirtualMachine) ShiftRight(args ...*vminstruction.Arg) {
+	vm.write(args[0].Value, vm.Int.Shr(vm.getVal(args[1]), vm.shiftCount(args[2])))
+}
+
+// ShiftRightLogical DST SRC1 SRC2
+// Logical shift, the vacated bits are zero
+func (vm *VirtualMachine) ShiftRightLogical(args ...*vminstruction.Arg) {
+	vm.write(args[0].Value, vm.Int.Ushr(vm.getVal(args[1]), vm.shiftCount(args[2])))
+}
+
+func (vm *VirtualMachine) shiftCount(arg *vminstruction.Arg) uint64 {
+	count := vm.getVal(arg)
+	if count < 0 && !vm.Int.Unsigned {
+		vm.fail("negative shift count")
+	}
+	return uint64(count)
+}
+
 // Jump INS
 func (vm *VirtualMachine) Jump(args ...*vminstruction.Arg) {
 	vm.ProgramCounter = vm.getVal(args[0])
@@ -150,8 +344,9 @@ func (vm *VirtualMachine) Return(args ...*vminstruction.Arg) {
 	res := vm.getVal(args[0])
 
 	if len(vm.CallStack) == 0 {
-		fmt.Println(res)
-		os.Exit(0)
+		vm.halted = true
+		vm.result = res
+		return
 	}
 
 	// last index
@@ -166,7 +361,7 @@ func (vm *VirtualMachine) Return(args ...*vminstruction.Arg) {
 
 // LessThan DST SRC1 SRC2
 func (vm *VirtualMachine) LessThan(args ...*vminstruction.Arg) {
-	if vm.getVal(args[1]) < vm.getVal(args[2]) {
+	if vm.Int.Less(vm.getVal(args[1]), vm.getVal(args[2])) {
 		vm.write(args[0].Value, 1)
 	} else {
 		vm.write(args[0].Value, 0)
@@ -181,3 +376,92 @@ func (vm *VirtualMachine) Equals(args ...*vminstruction.Arg) {
 		vm.write(args[0].Value, 0)
 	}
 }
+
+// GreaterThan DST SRC1 SRC2
+func (vm *VirtualMachine) GreaterThan(args ...*vminstruction.Arg) {
+	if vm.Int.Less(vm.getVal(args[2]), vm.getVal(args[1])) {
+		vm.write(args[0].Value, 1)
+	} else {
+		vm.write(args[0].Value, 0)
+	}
+}
+
+// LessOrEqual DST SRC1 SRC2
+func (vm *VirtualMachine) LessOrEqual(args ...*vminstruction.Arg) {
+	if !vm.Int.Less(vm.getVal(args[2]), vm.getVal(args[1])) {
+		vm.write(args[0].Value, 1)
+	} else {
+		vm.write(args[0].Value, 0)
+	}
+}
+
+// GreaterOrEqual DST SRC1 SRC2
+func (vm *VirtualMachine) GreaterOrEqual(args ...*vminstruction.Arg) {
+	if !vm.Int.Less(vm.getVal(args[1]), vm.getVal(args[2])) {
+		vm.write(args[0].Value, 1)
+	} else {
+		vm.write(args[0].Value, 0)
+	}
+}
+
+// NotEquals DST SRC1 SRC2
+func (vm *VirtualMachine) NotEquals(args ...*vminstruction.Arg) {
+	if vm.getVal(args[1]) != vm.getVal(args[2]) {
+		vm.write(args[0].Value, 1)
+	} else {
+		vm.write(args[0].Value, 0)
+	}
+}
+
+// Alloc DST SIZE
+// Writes the address of a new array of SIZE zeros to DST
+func (vm *VirtualMachine) Alloc(args ...*vminstruction.Arg) {
+	size := vm.getVal(args[1])
+	if size < 0 {
+		vm.fail(fmt.Sprintf("negative array length %d", size))
+	}
+	if size >= maxHeap-int64(len(vm.Heap)) {
+		vm.fail(fmt.Sprintf("array length %d too large, the heap holds at most %d values", size, maxHeap))
+	}
+
+	addr := int64(len(vm.Heap))
+	vm.Heap = append(vm.Heap, size)
+	vm.Heap = append(vm.Heap, make([]int64, size)...)
+	vm.arrays[addr] = true
+	vm.write(args[0].Value, addr)
+}
+
+// Load DST ARRAY INDEX
+func (vm *VirtualMachine) Load(args ...*vminstruction.Arg) {
+	vm.write(args[0].Value, vm.Heap[vm.element(args[1], args[2])])
+}
+
+// Store ARRAY INDEX SRC
+func (vm *VirtualMachine) Store(args ...*vminstruction.Arg) {
+	vm.Heap[vm.element(args[0], args[1])] = vm.getVal(args[2])
+}
+
+// Length DST ARRAY
+func (vm *VirtualMachine) Length(args ...*vminstruction.Arg) {
+	vm.write(args[0].Value, vm.Heap[vm.address(args[1])])
+}
+
+// address returns the heap address held by arg, it must be the address of an array
+// returned by Alloc whose elements are all on the heap
+func (vm *VirtualMachine) address(arg *vminstruction.Arg) int64 {
+	addr := vm.getVal(arg)
+	if !vm.arrays[addr] || addr >= int64(len(vm.Heap)) || vm.Heap[addr] < 0 || vm.Heap[addr] > int64(len(vm.Heap))-addr-1 {
+		vm.fail(fmt.Sprintf("invalid array address %d", addr))
+	}
+	return addr
+}
+
+// element returns the heap position of the element at index of the array
+func (vm *VirtualMachine) element(array *vminstruction.Arg, index *vminstruction.Arg) int64 {
+	addr := vm.address(array)
+	i := vm.getVal(index)
+	if i < 0 || i >= vm.Heap[addr] {
+		vm.fail(fmt.Sprintf("index %d out of range for an array of length %d", i, vm.Heap[addr]))
+	}
+	return addr + 1 + i
+}
@@ -0,0 +1,224 @@
+package vm
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/simplang/arith"
+	"github.com/simplang/limits"
+	"github.com/simplang/vminstruction"
+)
+
+// sum calls itself n times and returns 0 + 1 + ... + n, $0 is n
+const sum = `0 Set $0, %d
+1 Call 3, 0, 1
+2 Return $1
+3 JumpIfZero $0, 8
+4 Subtract $1, $0, 1
+5 Call 3, 1, 2
+6 Add $0, $0, $2
+7 Return $0
+8 Return 0`
+
+func TestRun(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected int64
+	}{
+		{"0 Set $0, 7\n1 Multiply $0, $0, 6\n2 Return $0", 42},
+		{"0 Alloc $0, 3\n1 Store $0, 2, 5\n2 Load $1, $0, 2\n3 Length $2, $0\n4 Multiply $1, $1, $2\n5 Return $1", 15},
+		{"0 Set $0, 10\n1 JumpIfZero $0, 4\n2 Subtract $0, $0, 1\n3 Jump 1\n4 Return $0", 0},
+		{fmt.Sprintf(sum, 10), 55},
+	}
+
+	for i, tt := range tests {
+		res, err := New(vminstruction.ReadInstructions(tt.input)).Run(context.Background())
+		if err != nil {
+			t.Fatalf("tests[%d] - unexpected error: %s", i, err)
+		}
+		if res != tt.expected {
+			t.Fatalf("tests[%d] - result wrong. expected=%d, got=%d", i, tt.expected, res)
+		}
+	}
+}
+
+func TestRunOperators(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected int64
+		err      string
+	}{
+		{"0 Divide $0, 7, -2\n1 Return $0", -3, ""},
+		{"0 Modulo $0, -7, 2\n1 Return $0", -1, ""},
+		{"0 Modulo $0, 7, -2\n1 Return $0", 1, ""},
+		{"0 Divide $0, -9223372036854775808, -1\n1 Return $0", -9223372036854775808, ""},
+		{"0 Modulo $0, -9223372036854775808, -1\n1 Return $0", 0, ""},
+		{"0 Set $0, 0\n1 Divide $0, 1, $0\n2 Return $0", 0, "division by zero (index 1)"},
+		{"0 Modulo $0, 1, 0\n1 Return $0", 0, "modulo by zero (index 0)"},
+		{"0 Subtract $0, 3, 5\n1 Return $0", -2, ""},
+		{"0 GreaterThan $0, 2, 1\n1 Return $0", 1, ""},
+		{"0 GreaterThan $0, 1, 1\n1 Return $0", 0, ""},
+		{"0 LessOrEqual $0, 1, 1\n1 Return $0", 1, ""},
+		{"0 LessOrEqual $0, 2, 1\n1 Return $0", 0, ""},
+		{"0 GreaterOrEqual $0, 1, 1\n1 Return $0", 1, ""},
+		{"0 GreaterOrEqual $0, -1, 1\n1 Return $0", 0, ""},
+		{"0 NotEquals $0, 1, 2\n1 Return $0", 1, ""},
+		{"0 NotEquals $0, 2, 2\n1 Return $0", 0, ""},
+		{"0 BitAnd $0, 12, 10\n1 BitXor $1, 12, 10\n2 BitOr $0, $0, $1\n3 Return $0", 14, ""},
+		{"0 BitNot $0, 0\n1 Return $0", -1, ""},
+		{"0 ShiftLeft $0, 3, 4\n1 Return $0", 48, ""},
+		{"0 ShiftRight $0, -16, 2\n1 Return $0", -4, ""},
+		{"0 ShiftRightLogical $0, -1, 60\n1 Return $0", 15, ""},
+		{"0 ShiftLeft $0, 1, 64\n1 Return $0", 0, ""},
+		{"0 ShiftRight $0, -8, 70\n1 Return $0", -1, ""},
+		{"0 ShiftRightLogical $0, -1, 64\n1 Return $0", 0, ""},
+		{"0 ShiftLeft $0, 1, -1\n1 Return $0", 0, "negative shift count (index 0)"},
+		{"0 ShiftRight $0, 1, -1\n1 Return $0", 0, "negative shift count (index 0)"},
+		{"0 Alloc $0, 2\n1 Alloc $1, 3\n2 Store $1, 2, 7\n3 Load $2, $1, 2\n4 Length $3, $0\n5 Add $2, $2, $3\n6 Return $2", 9, ""},
+		{"0 Alloc $0, 0\n1 Length $0, $0\n2 Return $0", 0, ""},
+		{"0 Alloc $0, -1\n1 Return $0", 0, "negative array length -1 (index 0)"},
+		{"0 Alloc $0, 9223372036854775807\n1 Return $0", 0, "array length 9223372036854775807 too large, the heap holds at most 16777216 values (index 0)"},
+		{"0 Alloc $0, 2\n1 Load $1, $0, 2\n2 Return $1", 0, "index 2 out of range for an array of length 2 (index 1)"},
+		{"0 Alloc $0, 2\n1 Load $1, $0, -1\n2 Return $1", 0, "index -1 out of range for an array of length 2 (index 1)"},
+		{"0 Alloc $0, 2\n1 Store $0, 5, 1\n2 Return $0", 0, "index 5 out of range for an array of length 2 (index 1)"},
+		{"0 Alloc $0, 2\n1 Store 7, 0, 1\n2 Return $0", 0, "invalid array address 7 (index 1)"},
+		{"0 Length $0, 0\n1 Return $0", 0, "invalid array address 0 (index 0)"},
+		{"0 Alloc $0, 2\n1 Alloc $1, 1\n2 Length $2, 1\n3 Return $2", 0, "invalid array address 1 (index 2)"},
+		{"0 Alloc $0, 2\n1 Store $0, 0, 100\n2 Load $1, 1, 50\n3 Return $1", 0, "invalid array address 1 (index 2)"},
+		{"0 Alloc $0, 1\n1 Store $0, 0, 9\n2 Store 1, 5, 0\n3 Return $0", 0, "invalid array address 1 (index 2)"},
+		{"0 Alloc $0, 1\n1 Load $1, -1, 0\n2 Return $1", 0, "invalid array address -1 (index 1)"},
+	}
+
+	for i, tt := range tests {
+		res, err := New(vminstruction.ReadInstructions(tt.input)).Run(context.Background())
+		if tt.err != "" {
+			if err == nil || err.Error() != tt.err {
+				t.Fatalf("tests[%d] - expected the error %q, got %v", i, tt.err, err)
+			}
+			continue
+		}
+		if err != nil || res != tt.expected {
+			t.Fatalf("tests[%d] - result wrong. expected=%d, got=%d, err=%v", i, tt.expected, res, err)
+		}
+	}
+}
+
+func TestRunErrors(t *testing.T) {
+	canceled, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	tests := []struct {
+		input    string
+		ctx      context.Context
+		limits   limits.Limits
+		expected error
+		msg      string
+	}{
+		{"0 Jump 0", context.Background(), limits.Limits{MaxSteps: 100}, limits.ErrSteps, "step limit exceeded, the limit is 100 (index 0)"},
+		{fmt.Sprintf(sum, 10), context.Background(), limits.Limits{MaxDepth: 10}, limits.ErrDepth, "call depth limit exceeded, the limit is 10 (index 5)"},
+		{"0 Jump 0", canceled, limits.Limits{}, context.Canceled, "context canceled (index 0)"},
+		{"0 Divide $0, 1, 0\n1 Return $0", context.Background(), limits.Limits{}, nil, "division by zero (index 0)"},
+		{"0 Set $0, 1\n1 Jump 5", context.Background(), limits.Limits{}, nil, "no instruction at this index (index 5)"},
+	}
+
+	for i, tt := range tests {
+		m := New(vminstruction.ReadInstructions(tt.input))
+		m.Limits = tt.limits
+
+		_, err := m.Run(tt.ctx)
+		var vmErr *Error
+		if !errors.As(err, &vmErr) {
+			t.Fatalf("tests[%d] - expected a *Error, got %v", i, err)
+		}
+		if !errors.Is(errors.Unwrap(err), tt.expected) {
+			t.Fatalf("tests[%d] - cause wrong. expected=%v, got=%v", i, tt.expected, errors.Unwrap(err))
+		}
+		if err.Error() != tt.msg {
+			t.Fatalf("tests[%d] - message wrong. expected=%q, got=%q", i, tt.msg, err.Error())
+		}
+	}
+}
+
+func TestRunArithmetic(t *testing.T) {
+	tests := []struct {
+		input     string
+		wrapped   int64
+		saturated int64
+		checked   string
+	}{
+		{"0 Add $0, 9223372036854775807, 1\n1 Return $0", -9223372036854775808, 9223372036854775807, "integer overflow in 9223372036854775807 + 1 (index 0)"},
+		{"0 Set $0, 1\n1 Subtract $0, -9223372036854775808, $0\n2 Return $0", 9223372036854775807, -9223372036854775808, "integer overflow in -9223372036854775808 - 1 (index 1)"},
+		{"0 Multiply $0, 4611686018427387904, 2\n1 Return $0", -9223372036854775808, 9223372036854775807, "integer overflow in 4611686018427387904 * 2 (index 0)"},
+		{"0 Divide $0, -9223372036854775808, -1\n1 Return $0", -9223372036854775808, 9223372036854775807, "integer overflow in -9223372036854775808 / -1 (index 0)"},
+		{"0 Negate $0, -9223372036854775808\n1 Return $0", -9223372036854775808, 9223372036854775807, "integer overflow in -(-9223372036854775808) (index 0)"},
+	}
+
+	for i, tt := range tests {
+		for _, mode := range []arith.Mode{arith.Wrapping, arith.Saturating, arith.Checked} {
+			m := New(vminstruction.ReadInstructions(tt.input))
+			m.Arithmetic = mode
+			res, err := m.Run(context.Background())
+
+			if mode == arith.Checked {
+				if !errors.Is(err, arith.ErrOverflow) || err.Error() != tt.checked {
+					t.Fatalf("tests[%d] - expected the overflow %q, got %v", i, tt.checked, err)
+				}
+				continue
+			}
+
+			expected := tt.wrapped
+			if mode == arith.Saturating {
+				expected = tt.saturated
+			}
+			if err != nil || res != expected {
+				t.Fatalf("tests[%d] - result in %s mode wrong. expected=%d, got=%d, err=%v", i, mode, expected, res, err)
+			}
+		}
+	}
+	m := New(vminstruction.ReadInstructions("0 Return 1"))
+	m.Arithmetic = arith.Big
+	if _, err := m.Run(context.Background()); err == nil || err.Error() != "big integers are not supported by the vm (index 0)" {
+		t.Fatalf("expected big mode to be refused, got %v", err)
+	}
+}
+
+func TestRunIntTypes(t *testing.T) {
+	int16, uint8 := arith.Int{Bits: 16}, arith.Int{Bits: 8, Unsigned: true}
+
+	tests := []struct {
+		typ      arith.Int
+		input    string
+		expected int64
+		checked  string
+	}{
+		{int16, "0 Add $0, 32767, 1\n1 Return $0", -32768, "integer overflow in 32767 + 1 (index 0)"},
+		{int16, "0 Multiply $0, 256, 256\n1 Return $0", 0, "integer overflow in 256 * 256 (index 0)"},
+		{int16, "0 ShiftLeft $0, 1, 15\n1 Return $0", -32768, ""},
+		{int16, "0 ShiftRightLogical $0, -1, 8\n1 Return $0", 255, ""},
+		{uint8, "0 Subtract $0, 0, 1\n1 Return $0", 255, "integer overflow in 0 - 1 (index 0)"},
+		{uint8, "0 LessThan $0, 200, 100\n1 Return $0", 0, ""},
+		{uint8, "0 BitNot $0, 15\n1 Return $0", 240, ""},
+		{uint8, "0 Divide $0, 255, 16\n1 Modulo $1, 255, 16\n2 Add $0, $0, $1\n3 Return $0", 30, ""},
+	}
+
+	for i, tt := range tests {
+		for _, mode := range []arith.Mode{arith.Wrapping, arith.Checked} {
+			m := New(vminstruction.ReadInstructions(tt.input))
+			m.Arithmetic = mode
+			m.Int = tt.typ
+			res, err := m.Run(context.Background())
+
+			if mode == arith.Checked && tt.checked != "" {
+				if !errors.Is(err, arith.ErrOverflow) || err.Error() != tt.checked {
+					t.Fatalf("tests[%d] - expected the overflow %q, got %v", i, tt.checked, err)
+				}
+				continue
+			}
+			if err != nil || res != tt.expected {
+				t.Fatalf("tests[%d] - result as %s in %s mode wrong. expected=%d, got=%d, err=%v", i, tt.typ, mode, tt.expected, res, err)
+			}
+		}
+	}
+}